@@ -0,0 +1,44 @@
+// rgnes-gamedb generates the built-in game database nes/gamedb.txt from the XML export of NesCartDB.
+//
+//	rgnes-gamedb -o nes/gamedb.txt nescartdb.xml
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ichirin2501/rgnes/nes"
+)
+
+func main() {
+	if err := realMain(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func realMain() error {
+	var out string
+	flag.StringVar(&out, "o", "", "output filepath (default: stdout)")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		return fmt.Errorf("usage: rgnes-gamedb [-o gamedb.txt] nescartdb.xml")
+	}
+
+	in, err := os.Open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var buf bytes.Buffer
+	if err := nes.WriteGameDB(&buf, in); err != nil {
+		return fmt.Errorf("%s: %w", flag.Arg(0), err)
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(out, buf.Bytes(), 0644)
+}
@@ -0,0 +1,126 @@
+// rgnes-test runs a test rom without the window, and reports the result written by the rom.
+//
+// The blargg's test roms report the result in the cartridge RAM:
+//
+//	$6000      status: $80 while running, $81 when the reset button needs to be pressed, $00-$7F the result code
+//	$6001-6003 signature $DE $B0 $61, the other bytes are valid only if it's present
+//	$6004-     zero-terminated text of the result
+//
+// The text is printed as the rom writes it, and the reset button is pressed 100 msec after the rom asks.
+// The exit status is 0 if the rom passed, 1 if it failed, 2 on timeout and 3 if the rom can't be run.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ichirin2501/rgnes/nes"
+)
+
+const (
+	exitPassed  = 0
+	exitFailed  = 1
+	exitTimeout = 2
+	exitError   = 3
+)
+
+type nopRenderer struct{}
+
+func (nopRenderer) Render(x, y int, c color.Color) {}
+func (nopRenderer) Refresh()                       {}
+
+type nopPlayer struct{}
+
+func (nopPlayer) Sample(v float32)    {}
+func (nopPlayer) SampleRate() float64 { return 44100 }
+
+func main() {
+	os.Exit(realMain())
+}
+
+func realMain() int {
+	var (
+		rom     string
+		entry   string
+		timeout time.Duration
+		cycle   bool
+		ram     string
+		seed    int64
+		gamedb  string
+	)
+	flag.StringVar(&rom, "rom", "", "rom filepath (.nes, .unf, or compressed in .zip/.gz)")
+	flag.StringVar(&entry, "entry", "", "rom file name in the zip archive, required if it contains several roms")
+	flag.DurationVar(&timeout, "timeout", 60*time.Second, "limit of the emulated time")
+	flag.BoolVar(&cycle, "cycle", false, "run the cycle-stepped cpu core")
+	flag.StringVar(&ram, "ram", "zero", "ram content at power-up ("+strings.Join(nes.RAMPatterns, ", ")+")")
+	flag.Int64Var(&seed, "ram-seed", 0, "seed of the random ram content")
+	flag.StringVar(&gamedb, "gamedb", "", "game database to correct the ines headers (gamedb.txt format, or the xml export of NesCartDB)")
+	flag.Parse()
+
+	n, err := newNES(rom, entry, cycle, ram, seed, gamedb)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return run(n, os.Stdout, cyclesOf(timeout))
+}
+
+func newNES(rom, entry string, cycle bool, ram string, seed int64, gamedb string) (*nes.NES, error) {
+	ramPattern, err := nes.ParseRAMPattern(ram)
+	if err != nil {
+		return nil, err
+	}
+	if gamedb != "" {
+		f, err := os.Open(gamedb)
+		if err != nil {
+			return nil, err
+		}
+		err = nes.LoadGameDB(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", gamedb, err)
+		}
+	}
+	file, err := nes.OpenROMFile(rom, entry)
+	if err != nil {
+		return nil, err
+	}
+	if file.Type != nes.FileTypeINES && file.Type != nes.FileTypeUNIF {
+		return nil, fmt.Errorf("%s: %s is not supported", file.Name, file.Type)
+	}
+	mapper, err := nes.NewMapper(bytes.NewReader(file.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []nes.Option{nes.WithRAMPattern(ramPattern, seed)}
+	if cycle {
+		opts = append(opts, nes.WithCycleStepCPU())
+	}
+	n := nes.New(mapper, nopRenderer{}, nopPlayer{}, opts...)
+	n.PowerUp()
+	return n, nil
+}
+
+// cyclesOf converts the emulated time to the CPU cycles
+func cyclesOf(d time.Duration) int {
+	return int(d.Seconds() * nes.CPUClockFrequency)
+}
+
+// run runs the rom until it reports the result or the timeout, and streams the text of the rom to w
+func run(n *nes.NES, w io.Writer, timeout int) int {
+	switch nes.RunBlarggTest(n, w, timeout) {
+	case nes.BlarggPassed:
+		return exitPassed
+	case nes.BlarggFailed:
+		return exitFailed
+	default:
+		return exitTimeout
+	}
+}
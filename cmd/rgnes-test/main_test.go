@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ichirin2501/rgnes/nes"
+	"github.com/stretchr/testify/assert"
+)
+
+// store returns the code storing the bytes from addr by LDA #imm and STA abs
+func store(addr uint16, data ...byte) []byte {
+	var code []byte
+	for i, b := range data {
+		a := addr + uint16(i)
+		code = append(code, 0xA9, b, 0x8D, byte(a), byte(a>>8))
+	}
+	return code
+}
+
+// loop returns JMP to itself, the code is placed at addr
+func loop(addr uint16) []byte {
+	return []byte{0x4C, byte(addr), byte(addr >> 8)}
+}
+
+var signatureCode = store(0x6001, 0xDE, 0xB0, 0x61)
+
+// text returns the code writing the zero-terminated text to $6004
+func text(s string) []byte {
+	return store(0x6004, append([]byte(s), 0)...)
+}
+
+// programAt joins the code placed at addr, and appends the infinite loop
+func programAt(addr uint16, codes ...[]byte) []byte {
+	var p []byte
+	for _, c := range codes {
+		p = append(p, c...)
+	}
+	return append(p, loop(addr+uint16(len(p)))...)
+}
+
+// program joins the code placed at $8000, and appends the infinite loop
+func program(codes ...[]byte) []byte {
+	return programAt(0x8000, codes...)
+}
+
+// resetProgram asks for the reset, and passes after it.
+// The text is rewritten after the reset.
+func resetProgram() []byte {
+	check := []byte{
+		0xAD, 0x00, 0x60, // LDA $6000
+		0xC9, 0x81, // CMP #$81
+		0xF0, 0x00, // BEQ second
+	}
+	first := programAt(0x8000+uint16(len(check)), signatureCode, text("A"), store(0x6000, 0x81))
+	check[len(check)-1] = byte(len(first))
+	second := programAt(0x8000+uint16(len(check)+len(first)), text("B\n"), store(0x6000, 0x00))
+	return append(append(check, first...), second...)
+}
+
+// newTestNES returns NROM running the program at $8000
+func newTestNES(t *testing.T, program []byte) *nes.NES {
+	t.Helper()
+	prg := make([]byte, 0x4000)
+	copy(prg, program)
+	// NMI and IRQ run RTI at $FFF0, RESET starts at $8000
+	prg[0x3FF0] = 0x40
+	copy(prg[0x3FFA:], []byte{0xF0, 0xFF, 0x00, 0x80, 0xF0, 0xFF})
+	rom := append([]byte{'N', 'E', 'S', 0x1A, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, prg...)
+	rom = append(rom, make([]byte, 0x2000)...)
+
+	mapper, err := nes.NewMapper(bytes.NewReader(rom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := nes.New(mapper, nopRenderer{}, nopPlayer{})
+	n.PowerUp()
+	return n
+}
+
+func Test_Run(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		program  []byte
+		timeout  int
+		want     int
+		wantText string
+	}{
+		{
+			"1",
+			program(signatureCode, store(0x6000, 0x80), text("ok\n"), store(0x6000, 0x00)),
+			nes.CPUClockFrequency,
+			exitPassed,
+			"ok\npassed\n",
+		},
+		// the failure message of the rom is printed
+		{
+			"2",
+			program(signatureCode, store(0x6000, 0x80), text("bad"), store(0x6000, 0x02)),
+			nes.CPUClockFrequency,
+			exitFailed,
+			"bad\nfailed: result code 2\n",
+		},
+		// the reset button is pressed, and the text is rewritten
+		{
+			"3",
+			resetProgram(),
+			nes.CPUClockFrequency,
+			exitPassed,
+			"A\nB\npassed\n",
+		},
+		{
+			"4",
+			program(signatureCode, store(0x6000, 0x80), text("running")),
+			nes.CPUClockFrequency / 10,
+			exitTimeout,
+			"running\ntimeout: the rom didn't finish\n",
+		},
+		// the status is ignored without the signature, or before the test starts
+		{
+			"5",
+			program(store(0x6000, 0x00)),
+			nes.CPUClockFrequency / 10,
+			exitTimeout,
+			"timeout: the rom didn't report the status\n",
+		},
+		{
+			"6",
+			program(signatureCode, store(0x6000, 0x00)),
+			nes.CPUClockFrequency / 10,
+			exitTimeout,
+			"timeout: the rom didn't report the status\n",
+		},
+		{
+			"7",
+			[]byte{0x02},
+			nes.CPUClockFrequency,
+			exitFailed,
+			"cpu jammed by KIL ($02) at $8000\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			n := newTestNES(t, tt.program)
+			var out bytes.Buffer
+			got := run(n, &out, tt.timeout)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantText, out.String())
+		})
+	}
+}
+
+func Test_Run_ResetDelay(t *testing.T) {
+	t.Parallel()
+	n := newTestNES(t, resetProgram())
+	var out bytes.Buffer
+	// the rom doesn't finish before the reset button is pressed
+	assert.Equal(t, exitTimeout, run(n, &out, nes.BlarggResetDelay))
+
+	n = newTestNES(t, resetProgram())
+	out.Reset()
+	assert.Equal(t, exitPassed, run(n, &out, nes.CPUClockFrequency))
+	assert.GreaterOrEqual(t, n.Clock(), nes.BlarggResetDelay)
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 
 	"github.com/gordonklaus/portaudio"
@@ -135,28 +139,135 @@ func realMain() error {
 		rom    string
 		scale  int
 		volume float64
+		bios   string
 		debug  bool
+		track  int
+		wav    string
+		secs   int
+		entry  string
+		patch  string
+		cycle  bool
+		ram    string
+		seed   int64
+		quirks = nes.DefaultPPUQuirks
+		warmUp bool
+		reset  string
+		gamedb string
 	)
-	flag.StringVar(&rom, "rom", "", "rome filepath")
+	flag.StringVar(&rom, "rom", "", "rom filepath (.nes, .unf, .fds, .nsf, .nsfe, or compressed in .zip/.gz)")
+	flag.StringVar(&entry, "entry", "", "rom file name in the zip archive, required if it contains several roms")
+	flag.StringVar(&patch, "patch", "", "ips, bps or ups patch filepath (default: <rom>.ips/.bps/.ups next to the rom; the fds disk writes are saved in <rom>.ips and applied after the patch)")
 	flag.IntVar(&scale, "scale", 2, "window scale size")
 	flag.Float64Var(&volume, "volume", 0.5, "volume scale size")
+	flag.StringVar(&gamedb, "gamedb", "", "game database to correct the ines headers (gamedb.txt format, or the xml export of NesCartDB)")
+	flag.StringVar(&bios, "bios", "", "famicom disk system bios filepath")
 	flag.BoolVar(&debug, "debug", false, "debug mode")
+	flag.BoolVar(&cycle, "cycle", false, "run the cycle-stepped cpu core (the debug trace is not available)")
+	flag.StringVar(&ram, "ram", "zero", "ram content at power-up ("+strings.Join(nes.RAMPatterns, ", ")+")")
+	flag.Int64Var(&seed, "ram-seed", 0, "seed of the random ram content")
+	flag.BoolVar(&quirks.SpriteOverflowBug, "sprite-overflow-bug", quirks.SpriteOverflowBug, "emulate the diagonal oam scan of the sprite overflow")
+	flag.BoolVar(&quirks.OAMRowCorruption, "oam-corruption", quirks.OAMRowCorruption, "emulate the oam row corruption by disabling the rendering mid-frame")
+	flag.BoolVar(&warmUp, "ppu-warmup", true, "ignore the ppu register writes until the first vblank ends after power-up")
+	flag.StringVar(&reset, "ppu-reset", "nes", "how the reset button reaches the ppu ("+strings.Join(nes.PPUResets, ", ")+"); nes restarts the warm-up, famicom leaves the ppu running")
+	flag.BoolVar(&quirks.OAMDecay, "oam-decay", quirks.OAMDecay, "emulate the oam decay while the rendering is disabled")
+	flag.BoolVar(&quirks.PaletteCorruption, "palette-corruption", quirks.PaletteCorruption, "emulate the palette corruption by disabling the rendering mid-scanline")
+	flag.IntVar(&track, "track", 0, "nsf track number to start (default: the start track of the file)")
+	flag.StringVar(&wav, "wav", "", "render the nsf track to the wav filepath without the window")
+	flag.IntVar(&secs, "seconds", 120, "length of the wav file in seconds")
 	flag.Parse()
 
-	f, err := os.Open(rom)
+	ramPattern, err := nes.ParseRAMPattern(ram)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	ppuReset, err := nes.ParsePPUReset(reset)
+	if err != nil {
+		return err
+	}
+
+	if gamedb != "" {
+		if err := loadGameDB(gamedb); err != nil {
+			return err
+		}
+	}
 
-	mapper, err := nes.NewMapper(f)
+	file, err := nes.OpenROMFile(rom, entry)
 	if err != nil {
 		return err
 	}
+	// The save files are put next to the rom, named after the rom in the archive
+	base := filepath.Join(filepath.Dir(rom), strings.TrimSuffix(filepath.Base(file.Name), filepath.Ext(file.Name)))
+
+	if patch == "" {
+		patch = findPatch(base, file.Type)
+	}
+	if file.Type == nes.FileTypeFDS && patch == diskSavePath(base) {
+		// the saved writes are applied by loadDiskSystem
+		patch = ""
+	}
+	if patch != "" {
+		p, err := os.ReadFile(patch)
+		if err != nil {
+			return err
+		}
+		if err := file.ApplyPatch(p); err != nil {
+			return fmt.Errorf("%s: %w", patch, err)
+		}
+		fmt.Printf("patched: %s\n", patch)
+	}
+
+	var mapper nes.Mapper
+	switch file.Type {
+	case nes.FileTypeNSF, nes.FileTypeNSFe:
+		return runNSF(file.Data, nsfOptions{
+			scale:   scale,
+			volume:  volume,
+			track:   track,
+			wav:     wav,
+			seconds: secs,
+		})
+	case nes.FileTypeFDS:
+		m, save, err := loadDiskSystem(file.Data, base, bios)
+		if err != nil {
+			return err
+		}
+		defer save()
+		mapper = m
+	case nes.FileTypeINES, nes.FileTypeUNIF:
+		cassette, err := nes.NewCassette(bytes.NewReader(file.Data))
+		if err != nil {
+			return err
+		}
+		if cassette.Title != "" {
+			fmt.Println(cassette.Title)
+		}
+		for _, c := range cassette.Corrections {
+			fmt.Printf("header corrected: %s\n", c)
+		}
+		mapper, err = nes.NewMapperFromCassette(cassette)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s: %s is not supported", file.Name, file.Type)
+	}
+	if b, ok := mapper.(nes.BatteryBackedMapper); ok {
+		savePath := base + ".sav"
+		if data, err := os.ReadFile(savePath); err == nil {
+			b.LoadSaveData(data)
+		}
+		defer func() {
+			if data := b.SaveData(); data != nil {
+				if err := os.WriteFile(savePath, data, 0644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+		}()
+	}
 
 	portaudio.Initialize()
 	defer portaudio.Terminate()
-	player, nil := newPlayer(float32(volume))
+	player, err := newPlayer(float32(volume))
 	if err != nil {
 		return err
 	}
@@ -169,10 +280,13 @@ func realMain() error {
 	oddImg := image.NewRGBA(image.Rect(0, 0, nes.ScreenWidth, nes.ScreenHeight))
 	renderer := newRenderer(evenImg, oddImg)
 
-	nesOpts := make([]nes.Option, 0)
+	nesOpts := []nes.Option{nes.WithRAMPattern(ramPattern, seed), nes.WithPPUQuirks(quirks), nes.WithPPUWarmUp(warmUp), nes.WithPPUReset(ppuReset)}
 	if debug {
 		nesOpts = append(nesOpts, nes.WithDebug())
 	}
+	if cycle {
+		nesOpts = append(nesOpts, nes.WithCycleStepCPU())
+	}
 
 	n := nes.New(mapper, renderer, player, nesOpts...)
 
@@ -202,6 +316,13 @@ func realMain() error {
 		if rl.IsKeyDown(rl.KeyR) {
 			n.Reset()
 		}
+		if rl.IsKeyPressed(rl.KeyS) && n.DiskSides() > 0 {
+			// next disk side
+			side := (n.DiskSide() + 1) % n.DiskSides()
+			if err := n.InsertDisk(side); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
 
 		if rl.IsKeyDown(rl.KeyUp) {
 			n.SetButtonStatus(nes.ButtonUP, true)
@@ -257,9 +378,79 @@ func realMain() error {
 
 		rl.ClearBackground(rl.RayWhite)
 		rl.DrawTextureEx(texture, rl.NewVector2(0, 0), 0, float32(scale), rl.White)
+		if err := n.Halted(); err != nil {
+			// R resets the jammed CPU
+			rl.DrawText(err.Error(), 5*int32(scale), 5*int32(scale), 10*int32(scale), rl.Red)
+		}
 
 		rl.EndDrawing()
 	}
 
 	return nil
 }
+
+// findPatch returns the patch file next to the rom, empty if not found.
+// The ips file of the disk image is not a patch, it's the saved writes.
+func findPatch(base string, t nes.FileType) string {
+	for _, ext := range nes.PatchExtensions {
+		if t == nes.FileTypeFDS && base+ext == diskSavePath(base) {
+			continue
+		}
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+// diskSavePath returns the ips file that keeps the writes to the disk image
+func diskSavePath(base string) string {
+	return base + ".ips"
+}
+
+// loadDiskSystem loads the disk image, and applies the saved writes over it.
+// The image has been patched by -patch already, so the saved writes follow the patch.
+// save writes the patch file of the writes from the image, that is applied next time.
+func loadDiskSystem(orig []byte, base, bios string) (nes.Mapper, func(), error) {
+	if bios == "" {
+		return nil, nil, errors.New("the famicom disk system requires -bios")
+	}
+	biosData, err := os.ReadFile(bios)
+	if err != nil {
+		return nil, nil, err
+	}
+	savePath := diskSavePath(base)
+	disk := orig
+	if patch, err := os.ReadFile(savePath); err == nil {
+		if disk, err = nes.ApplyIPS(orig, patch); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", savePath, err)
+		}
+	}
+	mapper, err := nes.NewDiskSystem(disk, biosData)
+	if err != nil {
+		return nil, nil, err
+	}
+	save := func() {
+		d := mapper.(nes.DiskSystemMapper)
+		patch, err := nes.CreateIPS(orig, d.DiskImage())
+		if err == nil {
+			err = os.WriteFile(savePath, patch, 0644)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	return mapper, save, nil
+}
+
+func loadGameDB(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := nes.LoadGameDB(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
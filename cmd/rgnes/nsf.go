@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/ichirin2501/rgnes/nes"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+type nsfOptions struct {
+	scale   int
+	volume  float64
+	track   int
+	wav     string
+	seconds int
+}
+
+// runNSF plays the NSF/NSFe file, or renders it to the WAV file if opts.wav is set
+func runNSF(data []byte, opts nsfOptions) error {
+	nsf, err := nes.NewNSF(data)
+	if err != nil {
+		return err
+	}
+
+	if opts.wav != "" {
+		rec := newWAVRecorder(44100, float32(opts.volume))
+		p := nes.NewNSFPlayer(nsf, rec)
+		if opts.track > 0 {
+			p.SelectSong(opts.track)
+		}
+		p.RunFor(time.Duration(opts.seconds) * time.Second)
+		return rec.WriteFile(opts.wav)
+	}
+
+	portaudio.Initialize()
+	defer portaudio.Terminate()
+	player, err := newPlayer(float32(opts.volume))
+	if err != nil {
+		return err
+	}
+	if err := player.Start(); err != nil {
+		return err
+	}
+	defer player.Stop()
+
+	p := nes.NewNSFPlayer(nsf, player)
+	if opts.track > 0 {
+		p.SelectSong(opts.track)
+	}
+
+	rl.SetTraceLogLevel(rl.LogWarning)
+	rl.InitWindow(nes.ScreenWidth*int32(opts.scale), nes.ScreenHeight*int32(opts.scale), "rgnes")
+	defer rl.CloseWindow()
+	rl.SetTargetFPS(60)
+
+	go p.Run()
+	defer p.Close()
+
+	size := 10 * int32(opts.scale)
+	for !rl.WindowShouldClose() {
+		if rl.IsKeyPressed(rl.KeyRight) || rl.IsKeyPressed(rl.KeyN) {
+			p.Next()
+		}
+		if rl.IsKeyPressed(rl.KeyLeft) || rl.IsKeyPressed(rl.KeyP) {
+			p.Prev()
+		}
+
+		rl.BeginDrawing()
+		rl.ClearBackground(rl.Black)
+		for i, line := range nsfInfoLines(nsf, p.Song()) {
+			rl.DrawText(line, size, size*int32(1+2*i), size, rl.RayWhite)
+		}
+		rl.EndDrawing()
+	}
+	return nil
+}
+
+func nsfInfoLines(nsf *nes.NSF, song int) []string {
+	lines := []string{
+		nsf.Title,
+		nsf.Artist,
+		nsf.Copyright,
+		fmt.Sprintf("Track %d / %d", song, nsf.Songs),
+	}
+	if song <= len(nsf.TrackLabels) && nsf.TrackLabels[song-1] != "" {
+		lines = append(lines, nsf.TrackLabels[song-1])
+	}
+	if song <= len(nsf.TrackTimes) && nsf.TrackTimes[song-1] >= 0 {
+		d := time.Duration(nsf.TrackTimes[song-1]) * time.Millisecond
+		lines = append(lines, fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60))
+	}
+	if chips := nsf.ChipNames(); len(chips) > 0 {
+		lines = append(lines, strings.Join(chips, ", "))
+	}
+	return append(lines, "<- / P: prev  -> / N: next")
+}
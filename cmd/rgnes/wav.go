@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+
+	"github.com/ichirin2501/rgnes/nes"
+)
+
+// wavRecorder collects the samples and writes them as a 16-bit mono WAV file
+type wavRecorder struct {
+	sampleRate float64
+	volume     float32
+	samples    []int16
+}
+
+func newWAVRecorder(sampleRate float64, volume float32) *wavRecorder {
+	return &wavRecorder{
+		sampleRate: sampleRate,
+		volume:     volume,
+	}
+}
+
+func (r *wavRecorder) Sample(v float32) {
+	v *= r.volume
+	if v > 1 {
+		v = 1
+	} else if v < -1 {
+		v = -1
+	}
+	r.samples = append(r.samples, int16(v*32767))
+}
+
+func (r *wavRecorder) SampleRate() float64 {
+	return r.sampleRate
+}
+
+// ref: http://soundfile.sapp.org/doc/WaveFormat/
+func (r *wavRecorder) WriteFile(path string) error {
+	// The APU outputs a sample every int(CPUClockFrequency/sampleRate) cycles, so the actual rate is slightly different
+	rate := uint32(nes.CPUClockFrequency / int(nes.CPUClockFrequency/r.sampleRate))
+	dataSize := uint32(len(r.samples) * 2)
+	header := struct {
+		ChunkID       [4]byte
+		ChunkSize     uint32
+		Format        [4]byte
+		Subchunk1ID   [4]byte
+		Subchunk1Size uint32
+		AudioFormat   uint16
+		NumChannels   uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Subchunk2ID   [4]byte
+		Subchunk2Size uint32
+	}{
+		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     36 + dataSize,
+		Format:        [4]byte{'W', 'A', 'V', 'E'},
+		Subchunk1ID:   [4]byte{'f', 'm', 't', ' '},
+		Subchunk1Size: 16,
+		AudioFormat:   1, // PCM
+		NumChannels:   1,
+		SampleRate:    rate,
+		ByteRate:      rate * 2,
+		BlockAlign:    2,
+		BitsPerSample: 16,
+		Subchunk2ID:   [4]byte{'d', 'a', 't', 'a'},
+		Subchunk2Size: dataSize,
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, r.samples); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
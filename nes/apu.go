@@ -48,6 +48,9 @@ type apu struct {
 	noise        *noise
 	dmc          *dmc
 
+	// expansion is nil if the cartridge doesn't have an expansion sound chip
+	expansion audioMapper
+
 	// frame counter
 	frameMode              byte // Sequencer mode: 0 selects 4-step sequence, 1 selects 5-step sequence
 	frameInterruptInhibit  bool
@@ -55,6 +58,8 @@ type apu struct {
 	frameStep              int
 	newFrameCounterVal     int // for delay
 	writeDelayFrameCounter byte
+	// frameCounterVal is the last value written to $4017
+	frameCounterVal byte
 }
 
 func newAPU(irqLine *irqInterruptLine, p Player, dma *dma) *apu {
@@ -86,9 +91,32 @@ func (apu *apu) powerUp() {
 	apu.writeDMCSampleLength(0)
 }
 
+// reset is called before the CPU runs the reset sequence
 func (apu *apu) reset() {
 	apu.writeStatus(0)
 	apu.tnd.seqPos = 0
+	apu.restartFrameCounter()
+}
+
+// restartFrameCounter restarts the frame counter with the last value written to $4017.
+// https://www.nesdev.org/wiki/CPU_power_up_state
+// At power-up the frame counter starts as if $00 were written to $4017 a few cycles before the first instruction,
+// and reset does the same with the last written value.
+// Unlike the write, there is no delay, so that the timing after reset matches the timing after power-up.
+func (apu *apu) restartFrameCounter() {
+	apu.newFrameCounterVal = -1
+	apu.writeDelayFrameCounter = 0
+	apu.frameStep = -1
+	apu.frameMode = apu.frameCounterVal >> 7
+	apu.frameInterruptInhibit = (apu.frameCounterVal & 0x40) == 0x40
+	if apu.frameInterruptInhibit {
+		apu.frameInterruptFlag = false
+		apu.irqLine.setHigh(irqSourceFrameCounter)
+	}
+	if apu.frameMode == 1 {
+		apu.tickHalfFrameCounter()
+		apu.tickQuarterFrameCounter()
+	}
 }
 
 func (apu *apu) step() {
@@ -99,6 +127,10 @@ func (apu *apu) step() {
 	// For example, in Pulse, timer depends on sweep, so my understanding is that the value of sweep must be determined before timer.
 	// So, call tickFrameCounter() before tickTimers()
 	apu.tickFrameCounter()
+	apu.pulse1.lc.applyWrites()
+	apu.pulse2.lc.applyWrites()
+	apu.tnd.lc.applyWrites()
+	apu.noise.lc.applyWrites()
 	apu.tickTimers()
 
 	if apu.clock%apu.sampleTiming == 0 {
@@ -110,7 +142,7 @@ func (apu *apu) step() {
 // DDLC VVVV	Duty (D), envelope loop / length counter halt (L), constant volume (C), volume/envelope (V)
 func writePulseController(p *pulse, val byte) {
 	p.duty = (val >> 6) & 0b11
-	p.lc.halt = (val & 0x20) == 0x20
+	p.lc.setHalt((val & 0x20) == 0x20)
 	p.el.loop = (val & 0x20) == 0x20
 	p.el.constantVolume = (val & 0x10) == 0x10
 	p.el.divider.period = uint16(val & 0x0F)
@@ -192,7 +224,7 @@ func (apu *apu) writePulse2LengthAndTimerHigh(val byte) {
 // $4008
 // CRRR RRRR	Length counter halt / linear counter control (C), linear counter load (R)
 func (apu *apu) writeTriangleController(val byte) {
-	apu.tnd.lc.halt = (val & 0x80) == 0x80
+	apu.tnd.lc.setHalt((val & 0x80) == 0x80)
 	apu.tnd.linearCounterCtrl = (val & 0x80) == 0x80
 	apu.tnd.linearCounterPeriod = val & 0x7F
 }
@@ -215,7 +247,7 @@ func (apu *apu) writeTriangleLengthAndTimerHigh(val byte) {
 // $400C
 // --LC VVVV	el loop / length counter halt (L), constant volume (C), volume/envelope (V)
 func (apu *apu) writeNoiseController(val byte) {
-	apu.noise.lc.halt = (val & 0x20) == 0x20
+	apu.noise.lc.setHalt((val & 0x20) == 0x20)
 	apu.noise.el.loop = (val & 0x20) == 0x20
 	apu.noise.el.constantVolume = (val & 0x10) == 0x10
 	apu.noise.el.divider.period = uint16(val & 0x0F)
@@ -359,6 +391,7 @@ func (apu *apu) writeFrameCounter(val byte) {
 	// And, the frame counter(sequencer) is clocked on every other CPU cycle(2 CPU cycles = 1 APU cycle, ^ trigger row in the above table).
 	// I decided to adjust it according to the above timing of the trigger.
 
+	apu.frameCounterVal = val
 	apu.newFrameCounterVal = int(val)
 	if apu.clock%2 == 0 {
 		apu.writeDelayFrameCounter = 2
@@ -383,6 +416,9 @@ func (apu *apu) writeFrameCounter(val byte) {
 func (apu *apu) output() float32 {
 	pout := pulseTable[apu.pulse1.output()+apu.pulse2.output()]
 	tout := tndTable[3*apu.tnd.output()+2*apu.noise.output()+apu.dmc.output()]
+	if apu.expansion != nil {
+		return pout + tout + apu.expansion.audioOutput()
+	}
 	return pout + tout
 }
 
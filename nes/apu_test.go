@@ -110,3 +110,76 @@ func Test_APU_TickFrameCounter(t *testing.T) {
 		})
 	}
 }
+
+func Test_LengthCounter_WriteTiming(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		lc       lengthCounter
+		write    func(lc *lengthCounter)
+		clocked  bool
+		want     byte
+		wantHalt bool
+	}{
+		// the halt flag is changed after clocking
+		{"1", lengthCounter{enabled: true, value: 5}, func(lc *lengthCounter) { lc.setHalt(true) }, true, 4, true},
+		{"2", lengthCounter{enabled: true, value: 5, halt: true, newHalt: true}, func(lc *lengthCounter) { lc.setHalt(false) }, true, 5, false},
+		// the reload is ignored when the length counter is clocked and it's not zero
+		{"3", lengthCounter{enabled: true, value: 5}, func(lc *lengthCounter) { lc.load(0) }, true, 4, false},
+		{"4", lengthCounter{enabled: true, value: 0}, func(lc *lengthCounter) { lc.load(0) }, true, 10, false},
+		{"5", lengthCounter{enabled: true, value: 5}, func(lc *lengthCounter) { lc.load(0) }, false, 10, false},
+		{"6", lengthCounter{enabled: true, value: 5, halt: true, newHalt: true}, func(lc *lengthCounter) { lc.load(0) }, true, 10, true},
+		// disabled
+		{"7", lengthCounter{value: 0}, func(lc *lengthCounter) { lc.load(0) }, false, 0, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			lc := tt.lc
+			tt.write(&lc)
+			if tt.clocked {
+				lc.tick()
+			}
+			lc.applyWrites()
+			assert.Equal(t, tt.want, lc.value)
+			assert.Equal(t, tt.wantHalt, lc.halt)
+		})
+	}
+}
+
+func Test_APU_Reset(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		val           byte
+		steps         int // APU steps between the $4017 write and reset
+		wantMode      byte
+		wantInhibit   bool
+		wantFrameStep int
+	}{
+		{"1", 0x00, 100, 0, false, -1},
+		{"2", 0x80, 100, 1, false, -1},
+		{"3", 0xC0, 100, 1, true, -1},
+		// reset restarts the frame counter with the value, that is still delayed
+		{"4", 0xC0, 0, 1, true, -1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			n := newTestCPU(nil)
+			n.bus.write(0x4017, tt.val)
+			for i := 0; i < tt.steps; i++ {
+				n.apu.step()
+			}
+			n.apu.reset()
+			assert.Equal(t, tt.wantMode, n.apu.frameMode)
+			assert.Equal(t, tt.wantInhibit, n.apu.frameInterruptInhibit)
+			assert.Equal(t, tt.wantFrameStep, n.apu.frameStep)
+			assert.Equal(t, -1, n.apu.newFrameCounterVal)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package nes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// The blargg's test roms report the result in the cartridge RAM:
+//
+//	$6000      status: $80 while running, $81 when the reset button needs to be pressed, $00-$7F the result code
+//	$6001-6003 signature $DE $B0 $61, the other bytes are valid only if it's present
+//	$6004-     zero-terminated text of the result
+const (
+	blarggStatusAddr = 0x6000
+	blarggTextAddr   = 0x6004
+
+	blarggStatusRunning       = 0x80
+	blarggStatusResetRequired = 0x81
+)
+
+var blarggSignature = []byte{0xDE, 0xB0, 0x61}
+
+// BlarggResetDelay is the CPU cycles to wait before pressing the reset button, the rom requires 100 msec at least
+const BlarggResetDelay = CPUClockFrequency / 10
+
+// blarggTextInterval is the CPU cycles between the checks of the text, that is about a frame
+const blarggTextInterval = 29781
+
+// BlarggResult is how a blargg's test rom ended
+type BlarggResult int
+
+const (
+	BlarggPassed BlarggResult = iota
+	BlarggFailed
+	BlarggTimeout
+)
+
+func (r BlarggResult) String() string {
+	switch r {
+	case BlarggPassed:
+		return "passed"
+	case BlarggFailed:
+		return "failed"
+	case BlarggTimeout:
+		return "timeout"
+	default:
+		return "unknown"
+	}
+}
+
+// RunBlarggTest runs the blargg's test rom until it reports the result, or the clock reaches timeout in CPU cycles.
+// The text of the rom is streamed to w as it grows, followed by the line of the result.
+// The reset button is pressed BlarggResetDelay after the rom asks for it.
+func RunBlarggTest(n *NES, w io.Writer, timeout int) BlarggResult {
+	running := false
+	resetClock := -1
+	textClock := 0
+	text := &blarggText{w: w}
+
+	for n.Clock() < timeout {
+		n.Step()
+		if err := n.Halted(); err != nil {
+			text.update(n)
+			text.finish()
+			fmt.Fprintln(w, err)
+			return BlarggFailed
+		}
+		if n.Clock()-textClock >= blarggTextInterval {
+			text.update(n)
+			textClock = n.Clock()
+		}
+		if !hasBlarggSignature(n) {
+			continue
+		}
+
+		switch status := n.PeekMemory(blarggStatusAddr); status {
+		case blarggStatusRunning:
+			running = true
+			resetClock = -1
+		case blarggStatusResetRequired:
+			running = true
+			if resetClock < 0 {
+				resetClock = n.Clock()
+			} else if n.Clock()-resetClock >= BlarggResetDelay {
+				n.Reset()
+				resetClock = -1
+			}
+		default:
+			if !running {
+				continue
+			}
+			text.update(n)
+			text.finish()
+			if status != 0 {
+				fmt.Fprintf(w, "failed: result code %d\n", status)
+				return BlarggFailed
+			}
+			fmt.Fprintln(w, "passed")
+			return BlarggPassed
+		}
+	}
+
+	text.update(n)
+	text.finish()
+	if running {
+		fmt.Fprintln(w, "timeout: the rom didn't finish")
+	} else {
+		fmt.Fprintln(w, "timeout: the rom didn't report the status")
+	}
+	return BlarggTimeout
+}
+
+func hasBlarggSignature(n *NES) bool {
+	for i, b := range blarggSignature {
+		if n.PeekMemory(blarggStatusAddr+1+uint16(i)) != b {
+			return false
+		}
+	}
+	return true
+}
+
+// readBlarggText reads the zero-terminated text from $6004
+func readBlarggText(n *NES) []byte {
+	var text []byte
+	for addr := uint16(blarggTextAddr); addr < 0x8000; addr++ {
+		b := n.PeekMemory(addr)
+		if b == 0 {
+			break
+		}
+		text = append(text, b)
+	}
+	return text
+}
+
+// blarggText writes the text of the rom as it grows
+type blarggText struct {
+	w       io.Writer
+	written []byte
+}
+
+func (s *blarggText) update(n *NES) {
+	if !hasBlarggSignature(n) {
+		return
+	}
+	text := readBlarggText(n)
+	if !bytes.HasPrefix(text, s.written) {
+		// the rom rewrote the text, e.g. after reset
+		s.finish()
+		s.written = nil
+	}
+	s.w.Write(text[len(s.written):])
+	s.written = text
+}
+
+// finish ends the last line of the text
+func (s *blarggText) finish() {
+	if len(s.written) > 0 && s.written[len(s.written)-1] != '\n' {
+		fmt.Fprintln(s.w)
+	}
+}
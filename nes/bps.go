@@ -0,0 +1,146 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// BPS patch format
+// "BPS1", source size, target size, metadata size, metadata, actions, source CRC32, target CRC32, patch CRC32
+// The sizes and the action commands are variable-length numbers.
+var bpsHeader = []byte("BPS1")
+
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+// patchReader reads the variable-length numbers used by BPS and UPS
+type patchReader struct {
+	data []byte
+	pos  int
+	// end is the position of the footer
+	end int
+	err error
+}
+
+func (r *patchReader) readByte() byte {
+	if r.pos >= r.end {
+		r.err = errors.New("unexpected end of patch")
+		return 0
+	}
+	v := r.data[r.pos]
+	r.pos++
+	return v
+}
+
+func (r *patchReader) readNumber() int {
+	n, shift := 0, 1
+	for r.err == nil {
+		x := r.readByte()
+		n += int(x&0x7F) * shift
+		if (x & 0x80) != 0 {
+			break
+		}
+		shift <<= 7
+		n += shift
+	}
+	return n
+}
+
+func (r *patchReader) readBytes(n int) []byte {
+	if n < 0 || r.pos+n > r.end {
+		r.err = errors.New("unexpected end of patch")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// checkPatchCRC validates the footer of BPS and UPS, the CRC32 of the source, the target and the patch itself
+func checkPatchCRC(source, target, patch []byte) error {
+	footer := patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(footer[8:]) {
+		return errors.New("the patch is broken")
+	}
+	if crc32.ChecksumIEEE(source) != binary.LittleEndian.Uint32(footer[0:]) {
+		return errors.New("the patch is not for this rom")
+	}
+	if crc32.ChecksumIEEE(target) != binary.LittleEndian.Uint32(footer[4:]) {
+		return errors.New("the patched rom is broken")
+	}
+	return nil
+}
+
+// ApplyBPS returns the data patched by the BPS patch
+func ApplyBPS(source, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, bpsHeader) || len(patch) < len(bpsHeader)+12 {
+		return nil, errors.New("bps: invalid header")
+	}
+	r := &patchReader{data: patch, pos: len(bpsHeader), end: len(patch) - 12}
+	sourceSize := r.readNumber()
+	targetSize := r.readNumber()
+	r.readBytes(r.readNumber()) // metadata
+	if r.err != nil {
+		return nil, errors.New("bps: " + r.err.Error())
+	}
+	if sourceSize != len(source) {
+		return nil, errors.New("bps: the patch is not for this rom")
+	}
+
+	target := make([]byte, targetSize)
+	out, sourceOffset, targetOffset := 0, 0, 0
+	for r.pos < r.end && r.err == nil {
+		cmd := r.readNumber()
+		length := (cmd >> 2) + 1
+		if out+length > len(target) {
+			return nil, errors.New("bps: the target overflows")
+		}
+		switch cmd & 0x03 {
+		case bpsSourceRead:
+			if out+length > len(source) {
+				return nil, errors.New("bps: the source overflows")
+			}
+			copy(target[out:], source[out:out+length])
+		case bpsTargetRead:
+			copy(target[out:], r.readBytes(length))
+		case bpsSourceCopy, bpsTargetCopy:
+			d := r.readNumber()
+			delta := d >> 1
+			if (d & 0x01) == 0x01 {
+				delta = -delta
+			}
+			if (cmd & 0x03) == bpsSourceCopy {
+				sourceOffset += delta
+				if sourceOffset < 0 || sourceOffset+length > len(source) {
+					return nil, errors.New("bps: the source overflows")
+				}
+				copy(target[out:], source[sourceOffset:sourceOffset+length])
+				sourceOffset += length
+			} else {
+				targetOffset += delta
+				if targetOffset < 0 || targetOffset >= out {
+					return nil, errors.New("bps: invalid target copy")
+				}
+				// byte by byte, the ranges can overlap to repeat a pattern
+				for i := 0; i < length; i++ {
+					target[out+i] = target[targetOffset]
+					targetOffset++
+				}
+			}
+		}
+		out += length
+	}
+	if r.err != nil {
+		return nil, errors.New("bps: " + r.err.Error())
+	}
+	if err := checkPatchCRC(source, target, patch); err != nil {
+		return nil, errors.New("bps: " + err.Error())
+	}
+	return target, nil
+}
@@ -12,6 +12,20 @@ type cpuBus struct {
 	joypad *joypad
 	dma    *dma
 
+	// cpuClockMapper is nil if the mapper doesn't need M2
+	cpuClockMapper cpuClockMapper
+	// ppuRegisterMapper is nil if the mapper doesn't snoop the PPU registers
+	ppuRegisterMapper ppuRegisterMapper
+
+	// openBus is the last value on the data bus, the reads from the unmapped addresses return it
+	openBus byte
+	// joypadReadClock is the clock of the last $4016 read
+	joypadReadClock int
+
+	// the content of the RAM at power-up
+	ramPattern RAMPattern
+	ramSeed    int64
+
 	// This clock is used to adjust the clock difference for each instruction,
 	// so keep the state separate from the $4014 dma stall.
 	clock int
@@ -19,17 +33,43 @@ type cpuBus struct {
 }
 
 func newCPUBus(ppu *ppu, apu *apu, mapper Mapper, joypad *joypad, dma *dma) *cpuBus {
-	return &cpuBus{
+	bus := &cpuBus{
 		ram:    make([]byte, 2048),
 		ppu:    ppu,
 		apu:    apu,
 		mapper: mapper,
 		joypad: joypad,
 		dma:    dma,
+
+		joypadReadClock: -1,
 	}
+	if m, ok := mapper.(cpuClockMapper); ok {
+		bus.cpuClockMapper = m
+	}
+	if m, ok := mapper.(ppuRegisterMapper); ok {
+		bus.ppuRegisterMapper = m
+	}
+	if m, ok := mapper.(openBusMapper); ok {
+		m.connectOpenBus(&bus.openBus)
+	}
+	return bus
+}
+
+// powerUp initializes the RAM, that is kept by reset
+func (bus *cpuBus) powerUp() {
+	bus.ramPattern.fill(bus.ram, bus.ramSeed)
 }
 
 func (bus *cpuBus) read(addr uint16) byte {
+	v := bus.readMemory(addr)
+	// $4015 is read inside the CPU, it doesn't drive the external data bus
+	if addr != 0x4015 {
+		bus.openBus = v
+	}
+	return v
+}
+
+func (bus *cpuBus) readMemory(addr uint16) byte {
 	switch {
 	// 2KB internal RAM
 	case 0x0000 <= addr && addr <= 0x1FFF:
@@ -41,22 +81,32 @@ func (bus *cpuBus) read(addr uint16) byte {
 
 	// NES APU and I/O registers
 	case 0x4000 <= addr && addr <= 0x4017:
+		// https://www.nesdev.org/wiki/Open_bus_behavior
 		switch {
 		case addr == 0x4015:
-			return bus.apu.readStatus()
+			// bit 5 is not driven
+			return bus.apu.readStatus() | (bus.openBus & 0x20)
 		case addr == 0x4016:
-			return bus.joypad.read()
+			// The controller port drives only the lower bits, the upper 3 bits are open bus (usually $40 from the address)
+			// The controller is clocked when /OE is asserted, and /OE stays asserted while $4016 is read on consecutive cycles.
+			// So the repeated reads of the CPU halted by DMA clock it only once.
+			consecutive := bus.joypadReadClock == bus.realClock()-1
+			bus.joypadReadClock = bus.realClock()
+			if consecutive {
+				return bus.joypad.peek() | (bus.openBus & 0xE0)
+			}
+			return bus.joypad.read() | (bus.openBus & 0xE0)
 		case addr == 0x4017: // TODO: 2p
 			//panic("unimplemented Bus.Read 0x4017(2p keypad)")
-			return 0
+			return bus.openBus & 0xE0
 		default:
-			// basically, ignore
-			return 0
+			// The other APU registers are write-only
+			return bus.openBus
 		}
 
 	// APU and I/O functionality that is normally disabled.
 	case 0x4018 <= addr && addr <= 0x401F:
-		return 0
+		return bus.openBus
 
 	// Cartridge space
 	case 0x4020 <= addr && addr <= 0xFFFF:
@@ -67,6 +117,7 @@ func (bus *cpuBus) read(addr uint16) byte {
 }
 
 func (bus *cpuBus) write(addr uint16, val byte) {
+	bus.openBus = val
 	switch {
 	// 2KB internal RAM
 	case 0x0000 <= addr && addr <= 0x1FFF:
@@ -75,6 +126,9 @@ func (bus *cpuBus) write(addr uint16, val byte) {
 	// NES PPU registers
 	case 0x2000 <= addr && addr <= 0x3FFF:
 		bus.ppu.writeRegister(addr, val)
+		if bus.ppuRegisterMapper != nil {
+			bus.ppuRegisterMapper.notifyPPURegisterWrite(addr, val)
+		}
 
 	// NES APU and I/O registers
 	case addr == 0x4000:
@@ -142,10 +196,18 @@ func (bus *cpuBus) realClock() int {
 	return bus.clock + bus.stall
 }
 
+// tickMapper clocks the mapper once per CPU cycle
+func (bus *cpuBus) tickMapper() {
+	if bus.cpuClockMapper != nil {
+		bus.cpuClockMapper.tickCPU()
+	}
+}
+
 func (bus *cpuBus) tick(cpuCycle int) {
 	bus.clock += cpuCycle
 	for i := 0; i < cpuCycle; i++ {
 		bus.apu.step()
+		bus.tickMapper()
 
 		bus.ppu.step()
 		bus.ppu.step()
@@ -157,6 +219,7 @@ func (bus *cpuBus) tickStall(cpuCycle int) {
 	bus.stall += cpuCycle
 	for i := 0; i < cpuCycle; i++ {
 		bus.apu.step()
+		bus.tickMapper()
 
 		bus.ppu.step()
 		bus.ppu.step()
@@ -177,18 +240,18 @@ func (bus *cpuBus) peek(addr uint16) byte {
 
 	// NES APU and I/O registers
 	case 0x4000 <= addr && addr <= 0x4017:
-		// todo
 		switch {
 		case addr == 0x4015:
-			return bus.apu.peekStatus()
+			return bus.apu.peekStatus() | (bus.openBus & 0x20)
 		case addr == 0x4016:
-			return bus.joypad.peek()
+			return bus.joypad.peek() | (bus.openBus & 0xE0)
+		case addr == 0x4017:
+			return bus.openBus & 0xE0
 		default:
-			return 0
+			return bus.openBus
 		}
 	case 0x4018 <= addr && addr <= 0x401F:
-		// todo
-		return 0
+		return bus.openBus
 	case 0x4020 <= addr && addr <= 0xFFFF:
 		return bus.mapper.Read(addr)
 	default:
@@ -198,85 +261,98 @@ func (bus *cpuBus) peek(addr uint16) byte {
 
 }
 
-func (bus *cpuBus) runDMAIfOccurred(readCycle bool) {
-	for {
-		if bus.dma.dmcDelay > 0 {
-			bus.dma.dmcDelay--
-			if bus.dma.dmcDelay == 0 {
-				if bus.dma.dmcState == dmcDMANoneState {
-					bus.dma.dmcState = dmcDMAHaltState
-				}
+// stepDMA runs a DMA cycle, and returns true if DMA took the CPU cycle.
+// DMA halts the CPU only on a read cycle, the CPU retries the read after DMA until this returns false.
+// addr is the address of the CPU cycle, the CPU halted on a read cycle keeps reading it while DMA doesn't use the bus.
+func (bus *cpuBus) stepDMA(addr uint16, readCycle bool) bool {
+	if bus.dma.dmcDelay > 0 {
+		bus.dma.dmcDelay--
+		if bus.dma.dmcDelay == 0 {
+			if bus.dma.dmcState == dmcDMANoneState {
+				bus.dma.dmcState = dmcDMAHaltState
 			}
 		}
-		if readCycle == false {
-			return
+	}
+	if readCycle == false {
+		return false
+	}
+	if bus.dma.oamState == oamDMANoneState && bus.dma.dmcState == dmcDMANoneState {
+		return false
+	}
+	bus.tickStall(1)
+	// true if DMA reads or writes in this cycle
+	accessed := false
+
+	// https://www.nesdev.org/wiki/DMA#Behavior
+	// > Get and put cycles are aligned to the first and second halves of the APU clock, respectively (called apu_clk1 and apu_clk2 in Visual2A03).
+	// > While these cycles are sometimes described as even and odd CPU cycles, this is not accurate because the CPU and APU randomly power into either of 2 alignments relative to each other.
+	// > Therefore, get and put may occur on different CPU cycle parities across different power cycles.
+	// Since get/put cycles are randomly determined when the power is turned on, this emulator implementation fixes it as follows.
+	// get = CPU even cycles
+	// put = CPU odd cycles
+	// And I don't know why, but it passed the cpu_interrupts_v2/4-irq_and_dma.nes test...
+
+	// OAM
+	switch bus.dma.oamState {
+	case oamDMAHaltState:
+		// init
+		bus.dma.oamCount = 0
+
+		if bus.realClock()%2 == 0 { // get cycle
+			bus.dma.oamState = oamDMAAlignmentState
+			bus.dma.oamSaveState = oamDMAReadState
+		} else {
+			bus.dma.oamState = oamDMAReadState
 		}
-		if bus.dma.oamState == oamDMANoneState && bus.dma.dmcState == dmcDMANoneState {
-			break
+	case oamDMAAlignmentState:
+		bus.dma.oamState = bus.dma.oamSaveState
+	case oamDMAReadState:
+		if bus.dma.dmcState == dmcDMARunState {
+			bus.dma.oamSaveState = oamDMAReadState
+			bus.dma.oamState = oamDMAAlignmentState
+		} else {
+			bus.dma.oamTempByte = bus.read(bus.dma.oamTargetAddr + bus.dma.oamCount)
+			bus.dma.oamState = oamDMAWriteState
+			accessed = true
 		}
-		bus.tickStall(1)
-
-		// https://www.nesdev.org/wiki/DMA#Behavior
-		// > Get and put cycles are aligned to the first and second halves of the APU clock, respectively (called apu_clk1 and apu_clk2 in Visual2A03).
-		// > While these cycles are sometimes described as even and odd CPU cycles, this is not accurate because the CPU and APU randomly power into either of 2 alignments relative to each other.
-		// > Therefore, get and put may occur on different CPU cycle parities across different power cycles.
-		// Since get/put cycles are randomly determined when the power is turned on, this emulator implementation fixes it as follows.
-		// get = CPU even cycles
-		// put = CPU odd cycles
-		// And I don't know why, but it passed the cpu_interrupts_v2/4-irq_and_dma.nes test...
-
-		// OAM
-		switch bus.dma.oamState {
-		case oamDMAHaltState:
-			// init
-			bus.dma.oamCount = 0
-
-			if bus.realClock()%2 == 0 { // get cycle
-				bus.dma.oamState = oamDMAAlignmentState
-				bus.dma.oamSaveState = oamDMAReadState
-			} else {
+	case oamDMAWriteState:
+		if bus.dma.dmcState == dmcDMARunState {
+			bus.dma.oamSaveState = oamDMAWriteState
+			bus.dma.oamState = oamDMAAlignmentState
+		} else {
+			bus.ppu.writeOAMData(bus.dma.oamTempByte)
+			accessed = true
+			bus.dma.oamCount++
+			if bus.dma.oamCount < 256 {
 				bus.dma.oamState = oamDMAReadState
-			}
-		case oamDMAAlignmentState:
-			bus.dma.oamState = bus.dma.oamSaveState
-		case oamDMAReadState:
-			if bus.dma.dmcState == dmcDMARunState {
-				bus.dma.oamSaveState = oamDMAReadState
-				bus.dma.oamState = oamDMAAlignmentState
-			} else {
-				bus.dma.oamTempByte = bus.read(bus.dma.oamTargetAddr + bus.dma.oamCount)
-				bus.dma.oamState = oamDMAWriteState
-			}
-		case oamDMAWriteState:
-			if bus.dma.dmcState == dmcDMARunState {
-				bus.dma.oamSaveState = oamDMAWriteState
-				bus.dma.oamState = oamDMAAlignmentState
 			} else {
-				bus.ppu.writeOAMData(bus.dma.oamTempByte)
-				bus.dma.oamCount++
-				if bus.dma.oamCount < 256 {
-					bus.dma.oamState = oamDMAReadState
-				} else {
-					bus.dma.oamState = oamDMANoneState
-				}
+				bus.dma.oamState = oamDMANoneState
 			}
 		}
-		// DMC
-		switch bus.dma.dmcState {
-		case dmcDMAHaltState:
-			bus.dma.dmcState = dmcDMADummyState
-		case dmcDMADummyState:
-			if bus.realClock()%2 == 0 { // get cycle
-				bus.dma.dmcState = dmcDMAAlignmentState
-			} else {
-				bus.dma.dmcState = dmcDMARunState
-			}
-		case dmcDMAAlignmentState:
+	}
+	// DMC
+	switch bus.dma.dmcState {
+	case dmcDMAHaltState:
+		bus.dma.dmcState = dmcDMADummyState
+	case dmcDMADummyState:
+		if bus.realClock()%2 == 0 { // get cycle
+			bus.dma.dmcState = dmcDMAAlignmentState
+		} else {
 			bus.dma.dmcState = dmcDMARunState
-		case dmcDMARunState:
-			val := bus.read(bus.dma.dmcTargetAddr)
-			bus.apu.dmc.setSampleBuffer(val)
-			bus.dma.dmcState = dmcDMANoneState
 		}
+	case dmcDMAAlignmentState:
+		bus.dma.dmcState = dmcDMARunState
+	case dmcDMARunState:
+		val := bus.read(bus.dma.dmcTargetAddr)
+		bus.apu.dmc.setSampleBuffer(val)
+		bus.dma.dmcState = dmcDMANoneState
+		accessed = true
+	}
+
+	// https://www.nesdev.org/wiki/DMA
+	// The halted CPU repeats the read, so that the reads with side effects ($2007, $4016, etc.) are done several times.
+	if !accessed {
+		bus.read(addr)
 	}
+	return true
 }
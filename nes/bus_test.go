@@ -0,0 +1,95 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CPUBus_OpenBus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		last     byte
+		addr     uint16
+		want     byte
+		wantLast byte
+	}{
+		{"1", 0x40, 0x4000, 0x40, 0x40},
+		{"2", 0x5A, 0x4014, 0x5A, 0x5A},
+		{"3", 0x5A, 0x401F, 0x5A, 0x5A},
+		// bit 5 of $4015, and the read doesn't change the data bus
+		{"4", 0xFF, 0x4015, 0x20, 0xFF},
+		{"5", 0xDF, 0x4015, 0x00, 0xDF},
+		// the upper 3 bits of the controller ports, A is not pressed
+		{"6", 0x41, 0x4016, 0x40, 0x40},
+		{"7", 0xFF, 0x4017, 0xE0, 0xE0},
+		// NROM without PRG RAM
+		{"8", 0x60, 0x6000, 0x60, 0x60},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := newMapper0(&Cassette{
+				PRG: make([]byte, 0x4000),
+				CHR: make([]byte, 0x2000),
+			})
+			n := New(m, nopRenderer{}, &fakePlayer{})
+			n.bus.write(0x0000, tt.last)
+			assert.Equal(t, tt.want, n.bus.read(tt.addr))
+			assert.Equal(t, tt.wantLast, n.bus.openBus)
+		})
+	}
+}
+
+func Test_CPUBus_DMCDMARepeatedReads(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		addr uint16
+		// clock decides whether DMC DMA has the alignment cycle
+		clock       int
+		want        byte
+		wantButton  byte
+		wantPPUAddr uint16
+	}{
+		// The halt cycle clocks the controller, the other repeated reads don't. The bit of A is lost.
+		{"1", 0x4016, 0, 0x00, 2, 0x2000},
+		{"2", 0x4016, 1, 0x00, 2, 0x2000},
+		// The repeated $2007 reads in the next CPU cycle are ignored.
+		// halt, dummy(ignored), get, read
+		{"3", 0x2007, 1, 0x11, 0, 0x2002},
+		// halt, dummy(ignored), alignment, get, read
+		{"4", 0x2007, 0, 0x22, 0, 0x2003},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := newMapper0(&Cassette{
+				PRG:    make([]byte, 0x4000),
+				CHR:    make([]byte, 0x2000),
+				Mirror: MirroringVertical,
+			})
+			n := New(m, nopRenderer{}, &fakePlayer{})
+			n.joypad.setButtonStatus(ButtonA, true)
+			for i, v := range []byte{0x11, 0x22, 0x33, 0x44} {
+				n.ppu.bus.write(0x2000+uint16(i), v)
+			}
+			n.ppu.v = 0x2000
+			n.ppu.clock = 100
+
+			n.bus.clock = tt.clock
+			n.bus.dma.dmcState = dmcDMAHaltState
+			n.bus.dma.dmcTargetAddr = 0x8000
+			got := n.cpu.read(tt.addr)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantButton, n.joypad.buttonIndex)
+			assert.Equal(t, tt.wantPPUAddr, n.ppu.v)
+			assert.Equal(t, dmcDMANoneState, n.bus.dma.dmcState)
+		})
+	}
+}
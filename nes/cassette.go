@@ -1,8 +1,11 @@
 package nes
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -16,6 +19,8 @@ const (
 	MirroringVertical MirroringType = iota
 	MirroringHorizontal
 	MirroringFourScreen
+	MirroringSingleScreenLow
+	MirroringSingleScreenHigh
 )
 
 func (m *MirroringType) IsVertical() bool {
@@ -27,6 +32,55 @@ func (m *MirroringType) IsHorizontal() bool {
 func (m *MirroringType) IsFourScreen() bool {
 	return *m == MirroringFourScreen
 }
+func (m *MirroringType) IsSingleScreenLow() bool {
+	return *m == MirroringSingleScreenLow
+}
+func (m *MirroringType) IsSingleScreenHigh() bool {
+	return *m == MirroringSingleScreenHigh
+}
+
+func (m MirroringType) String() string {
+	switch m {
+	case MirroringVertical:
+		return "vertical"
+	case MirroringHorizontal:
+		return "horizontal"
+	case MirroringFourScreen:
+		return "four-screen"
+	case MirroringSingleScreenLow:
+		return "single-screen low"
+	case MirroringSingleScreenHigh:
+		return "single-screen high"
+	default:
+		return "unknown"
+	}
+}
+
+// Region is the TV system that the game is made for.
+// The emulator always runs at the NTSC timing, this is for the information.
+type Region int
+
+const (
+	RegionNTSC Region = iota
+	RegionPAL
+	RegionMulti
+	RegionDendy
+)
+
+func (r Region) String() string {
+	switch r {
+	case RegionNTSC:
+		return "NTSC"
+	case RegionPAL:
+		return "PAL"
+	case RegionMulti:
+		return "multi-region"
+	case RegionDendy:
+		return "Dendy"
+	default:
+		return "unknown"
+	}
+}
 
 type iNESHeader struct {
 	Magic      uint32
@@ -34,22 +88,69 @@ type iNESHeader struct {
 	CHRROMSize byte // Size of CHR ROM in 8 KB units (value 0 means the board uses CHR RAM)
 	Flags6     byte // Mapper, mirroring, battery, trainer
 	Flags7     byte // Mapper, VS/Playchoice, NES 2.0
-	Flags8     byte // PRG-RAM size (rarely used extension)
-	Flags9     byte // TV system (rarely used extension)
-	Flags10    byte // TV system, PRG-RAM presence (unofficial, rarely used extension)
-	_          [5]byte
+	Flags8     byte // PRG-RAM size (rarely used extension), NES 2.0: Mapper MSB/Submapper
+	Flags9     byte // TV system (rarely used extension), NES 2.0: PRG-ROM/CHR-ROM size MSB
+	Flags10    byte // TV system, PRG-RAM presence (unofficial, rarely used extension), NES 2.0: PRG-RAM/EEPROM size
+	Flags11    byte // NES 2.0: CHR-RAM size
+	Flags12    byte // NES 2.0: CPU/PPU timing
+	Reserved   [3]byte
+}
+
+// The trainer is 512 bytes for $7000-$71FF, it was used by the copier devices
+const trainerSize = 512
+
+// https://www.nesdev.org/wiki/NES_2.0
+func (h *iNESHeader) isNES20() bool {
+	return (h.Flags7 & 0x0C) == 0x08
+}
+
+// nes20ROMSize returns the ROM size in bytes from the LSB in the header and the MSB nibble in Flags9.
+// If the MSB nibble is $F, the LSB is EEEE EEMM in the exponent-multiplier notation, the size is 2^E * (MM*2+1) bytes.
+func nes20ROMSize(lsb, msb byte, unit int) int {
+	if msb == 0x0F {
+		return (1 << (lsb >> 2)) * (int(lsb&0x03)*2 + 1)
+	}
+	return (int(msb)<<8 | int(lsb)) * unit
 }
 
 type Cassette struct {
 	PRG    []byte
 	CHR    []byte
-	Mapper byte
-	Mirror MirroringType
+	Mapper uint16
+	// Submapper is available only in NES 2.0, 0 means the default behavior of the mapper
+	Submapper byte
+	Mirror    MirroringType
+	Battery   bool // Cartridge contains battery-backed PRG RAM ($6000-7FFF) or other persistent memory
+	// PRGRAMSize is the size of PRG RAM including the battery-backed one in bytes, 0 if the cartridge has none
+	PRGRAMSize int
+	// Trainer is the 512-byte trainer that is loaded at $7000-$71FF, nil if none
+	Trainer []byte
+	Region  Region
+	// Title is available if the game is found in the database
+	Title string
+	// Corrections describe the header fields corrected by the database
+	Corrections []string
 
-	chrROMSize byte
+	// chrROMSize is the CHR ROM size in bytes, 0 means CHR RAM
+	chrROMSize int
+	// trainerLoaded is set if the mapper has loaded the trainer into PRG RAM
+	trainerLoaded bool
+	// openBus is the CPU data bus, that is read from the addresses the cartridge doesn't drive
+	openBus *byte
 }
 
+// NewCassette loads an iNES (including NES 2.0) or UNIF file
 func NewCassette(r io.Reader) (*Cassette, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(unifMagic)); bytes.Equal(magic, unifMagic) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		return newUNIFCassette(data)
+	}
+	r = br
+
 	header := &iNESHeader{}
 	if err := binary.Read(r, binary.LittleEndian, header); err != nil {
 		return nil, err
@@ -58,9 +159,6 @@ func NewCassette(r io.Reader) (*Cassette, error) {
 	if header.Magic != iNESMagicNumber {
 		return nil, errors.New("invalid ines file")
 	}
-	if ((header.Flags7 >> 2) & 0x02) == 2 {
-		return nil, errors.New("NES2.0 format is not supported yet")
-	}
 
 	var mirroringType MirroringType
 	mirrorFourScreenFlag := false
@@ -79,42 +177,136 @@ func NewCassette(r io.Reader) (*Cassette, error) {
 		mirroringType = MirroringHorizontal
 	}
 
-	mapper := (header.Flags7 & 0xF0) | (header.Flags6&0xF0)>>4
+	var corrections []string
+	mapper := uint16(header.Flags7&0xF0) | uint16(header.Flags6&0xF0)>>4
+	// Old dumping tools wrote a signature like "DiskDude!" from byte 7, which breaks the upper nibble of the mapper.
+	// The bytes 12-15 are always 0 in a clean iNES header.
+	if !header.isNES20() && (header.Flags12 != 0 || header.Reserved != [3]byte{}) && header.Flags7&0xF0 != 0 {
+		orig := mapper
+		mapper &= 0x0F
+		corrections = append(corrections, fmt.Sprintf("mapper: %d -> %d (garbage in the header)", orig, mapper))
+	}
+	submapper := byte(0)
+	// > Size of PRG RAM in 8 KB units (Value 0 infers 8 KB for compatibility; see PRG RAM circuit)
+	prgRAMSize := int(header.Flags8) * 0x2000
+	if prgRAMSize == 0 || len(corrections) > 0 {
+		prgRAMSize = 0x2000
+	}
+	region := RegionNTSC
+	if (header.Flags9 & 0x01) == 0x01 {
+		region = RegionPAL
+	}
+	prgSize := int(header.PRGROMSize) * programROMUnit
+	chrSize := int(header.CHRROMSize) * characterROMUnit
+	chrRAMSize := 8192
+	if header.isNES20() {
+		mapper |= uint16(header.Flags8&0x0F) << 8
+		submapper = header.Flags8 >> 4
+		prgRAMSize = 0
+		prgSize = nes20ROMSize(header.PRGROMSize, header.Flags9&0x0F, programROMUnit)
+		chrSize = nes20ROMSize(header.CHRROMSize, header.Flags9>>4, characterROMUnit)
+		// The CHR-RAM size is 64 << shift count bytes, 0 means no CHR-RAM (the default 8 KiB is kept)
+		if shift := header.Flags11 & 0x0F; shift != 0 {
+			chrRAMSize = 64 << shift
+		}
+		// PRG-RAM and PRG-NVRAM (EEPROM) sizes in the same way
+		for _, shift := range []byte{header.Flags10 & 0x0F, header.Flags10 >> 4} {
+			if shift != 0 {
+				prgRAMSize += 64 << shift
+			}
+		}
+		region = Region(header.Flags12 & 0x03)
+	}
+
+	var trainer []byte
+	if (header.Flags6 & 0x04) == 0x04 {
+		// The trainer is placed between the header and PRG ROM
+		trainer = make([]byte, trainerSize)
+		if _, err := io.ReadFull(r, trainer); err != nil {
+			return nil, err
+		}
+	}
 
-	prgRom := make([]byte, int(header.PRGROMSize)*programROMUnit)
+	prgRom := make([]byte, prgSize)
 	if _, err := io.ReadFull(r, prgRom); err != nil {
 		return nil, err
 	}
 
-	chrRom := make([]byte, int(header.CHRROMSize)*characterROMUnit)
+	chrRom := make([]byte, chrSize)
 	if _, err := io.ReadFull(r, chrRom); err != nil {
 		return nil, err
 	}
-	if header.CHRROMSize == 0 {
-		chrRom = make([]byte, 8192)
+	if chrSize == 0 {
+		chrRom = make([]byte, chrRAMSize)
 	}
 
-	return &Cassette{
-		PRG:    prgRom,
-		CHR:    chrRom,
-		Mapper: mapper,
-		Mirror: mirroringType,
+	c := &Cassette{
+		PRG:         prgRom,
+		CHR:         chrRom,
+		Mapper:      mapper,
+		Submapper:   submapper,
+		Mirror:      mirroringType,
+		Battery:     (header.Flags6 & 0x02) == 0x02,
+		PRGRAMSize:  prgRAMSize,
+		Trainer:     trainer,
+		Region:      region,
+		Corrections: corrections,
 
-		chrROMSize: header.CHRROMSize,
-	}, nil
+		chrROMSize: chrSize,
+	}
+	if e := lookupGameDB(prgRom, chrRom[:chrSize]); e != nil {
+		c.applyGameDBEntry(e)
+	}
+	return c, nil
 }
 
 func (c *Cassette) MirroingType() MirroringType {
 	return c.Mirror
 }
 
-func (c *Cassette) readCHR(index uint16) byte {
+func (c *Cassette) region() Region {
+	return c.Region
+}
+
+func (c *Cassette) readCHR(index int) byte {
 	return c.CHR[index]
 }
 
-func (c *Cassette) writeCHR(index uint16, val byte) {
+func (c *Cassette) writeCHR(index int, val byte) {
 	if c.chrROMSize == 0 {
 		// CHR RAM
 		c.CHR[index] = val
 	}
 }
+
+// newPRGRAM allocates PRG RAM of the header size for $6000-$7FFF, and loads the trainer at $7000.
+// It returns nil if the cartridge doesn't have PRG RAM.
+func (c *Cassette) newPRGRAM() []byte {
+	size := c.PRGRAMSize
+	if c.Trainer != nil && size < 0x2000 {
+		// The trainer needs the RAM at $7000-$71FF
+		size = 0x2000
+	}
+	if size == 0 {
+		return nil
+	}
+	ram := make([]byte, size)
+	if c.Trainer != nil {
+		copy(ram[0x1000:], c.Trainer)
+		c.trainerLoaded = true
+	}
+	return ram
+}
+
+// connectOpenBus passes the CPU data bus
+func (c *Cassette) connectOpenBus(openBus *byte) {
+	c.openBus = openBus
+}
+
+// readOpenBus returns the last value on the CPU data bus, for the reads that the cartridge doesn't respond to
+func (c *Cassette) readOpenBus() byte {
+	if c.openBus == nil {
+		return 0
+	}
+	return *c.openBus
+}
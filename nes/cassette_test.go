@@ -0,0 +1,95 @@
+package nes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestINES(flags6, flags7, flags8, flags10 byte, trainer []byte) []byte {
+	b := []byte{'N', 'E', 'S', 0x1A, 1, 1, flags6, flags7, flags8, 0, flags10, 0, 0, 0, 0, 0}
+	b = append(b, trainer...)
+	b = append(b, bytes.Repeat([]byte{0xEA}, 0x4000)...)
+	return append(b, make([]byte, 0x2000)...)
+}
+
+func Test_NewMapperFromCassette_Trainer(t *testing.T) {
+	t.Parallel()
+	trainer := bytes.Repeat([]byte{0x5A}, trainerSize)
+	tests := []struct {
+		name    string
+		mapper  uint16
+		trainer []byte
+		wantErr bool
+	}{
+		{"1", 0, trainer, false},
+		{"2", 2, nil, false},
+		// UxROM doesn't have PRG RAM to load the trainer
+		{"3", 2, trainer, true},
+		// unsupported mapper
+		{"4", 255, nil, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewMapperFromCassette(&Cassette{
+				PRG:        make([]byte, 0x8000),
+				CHR:        make([]byte, 0x2000),
+				Mapper:     tt.mapper,
+				PRGRAMSize: 0x2000,
+				Trainer:    tt.trainer,
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_NewCassette_PRGRAM(t *testing.T) {
+	t.Parallel()
+	trainer := bytes.Repeat([]byte{0x5A}, trainerSize)
+	tests := []struct {
+		name        string
+		data        []byte
+		wantSize    int
+		wantTrainer []byte
+		// the values at $6000 and $7000 after writing $6000 = 0x11, PRG ROM is filled with 0xEA
+		want6000 byte
+		want7000 byte
+	}{
+		{"1", newTestINES(0x00, 0x00, 0x00, 0x00, nil), 0x2000, nil, 0x11, 0x00},
+		{"2", newTestINES(0x04, 0x00, 0x00, 0x00, trainer), 0x2000, trainer, 0x11, 0x5A},
+		{"3", newTestINES(0x00, 0x00, 0x02, 0x00, nil), 0x4000, nil, 0x11, 0x00},
+		// NES 2.0 without PRG RAM reads the open bus, the last value read from PRG ROM
+		{"4", newTestINES(0x00, 0x08, 0x00, 0x00, nil), 0, nil, 0xEA, 0xEA},
+		// NES 2.0 with 2 KiB PRG RAM, that is mirrored
+		{"5", newTestINES(0x00, 0x08, 0x00, 0x05, nil), 0x800, nil, 0x11, 0x11},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := NewCassette(bytes.NewReader(tt.data))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSize, c.PRGRAMSize)
+			assert.Equal(t, tt.wantTrainer, c.Trainer)
+			assert.Equal(t, bytes.Repeat([]byte{0xEA}, 0x4000), c.PRG)
+
+			m, err := NewMapperFromCassette(c)
+			assert.NoError(t, err)
+			n := New(m, nopRenderer{}, &fakePlayer{})
+			n.bus.write(0x6000, 0x11)
+			n.bus.read(0x8000)
+			assert.Equal(t, tt.want6000, n.bus.read(0x6000))
+			n.bus.read(0x8000)
+			assert.Equal(t, tt.want7000, n.bus.read(0x7000))
+		})
+	}
+}
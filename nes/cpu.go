@@ -11,6 +11,33 @@ const (
 	CPUClockFrequency = 1789773
 )
 
+// UnstableOpcodeProfile is the behavior of the unstable unofficial opcodes, that differs between the chips.
+// XAA and LAX #imm mix A with a "magic" constant, some bits of A leak into the result depending on the chip and the temperature.
+// https://www.nesdev.org/wiki/Visual6502wiki/6502_Opcode_8B_(XAA,_ANE)
+type UnstableOpcodeProfile struct {
+	// XAAMagic is the constant of XAA #imm: A = (A | magic) & X & imm
+	XAAMagic byte
+	// LAXMagic is the constant of LAX #imm: A = X = (A | magic) & imm
+	LAXMagic byte
+}
+
+// DefaultUnstableOpcodeProfile is the common behavior of the NES CPUs
+var DefaultUnstableOpcodeProfile = UnstableOpcodeProfile{
+	XAAMagic: 0xEE,
+	LAXMagic: 0xFF,
+}
+
+// HaltError is the reason why the CPU is halted.
+// The CPU doesn't execute any instruction until reset.
+type HaltError struct {
+	PC     uint16
+	Opcode byte
+}
+
+func (e *HaltError) Error() string {
+	return fmt.Sprintf("cpu jammed by KIL ($%02X) at $%04X", e.Opcode, e.PC)
+}
+
 type pendingInterruptType int
 
 const (
@@ -42,6 +69,18 @@ type cpu struct {
 
 	pendingInterrupt pendingInterruptType
 
+	unstable UnstableOpcodeProfile
+	// dmaHalted is true if DMA halted the CPU (RDY) on the last read cycle
+	dmaHalted bool
+	// halt is not nil while the CPU is jammed
+	halt *HaltError
+	// cycle is the instruction in progress of the cycle-stepped core
+	cycle cycleState
+	// retryDMA is set while the cycle-stepped core runs a cycle, DMA takes the read cycle and the CPU retries it in the next step
+	retryDMA bool
+	// dmaStolen is true if DMA took the last cycle of the cycle-stepped core
+	dmaStolen bool
+
 	// https://www.nesdev.org/wiki/CPU_interrupts#Detailed_interrupt_behavior
 	// > The interrupt sequences themselves do not perform interrupt polling,
 	// > meaning at least one instruction from the interrupt handler will execute before another interrupt is serviced.
@@ -55,6 +94,8 @@ func newCPU(bus *cpuBus, nmiLine *nmiInterruptLine, irqLine *irqInterruptLine, t
 		bus:     bus,
 		tracer:  tracer,
 		mu:      &sync.Mutex{},
+
+		unstable: DefaultUnstableOpcodeProfile,
 	}
 	return cpu
 }
@@ -90,6 +131,18 @@ func (cpu *cpu) reset() {
 	cpu.PC = cpu.read16(0xFFFC)
 	cpu.P.setInterruptDisable(true)
 	cpu.S -= 3
+	cpu.halt = nil
+	// the instruction in progress of the cycle-stepped core is abandoned
+	cpu.cycle = cycleState{}
+	cpu.dmaStolen = false
+	cpu.interrupting = false
+}
+
+// halted returns the reason why the CPU is jammed, nil while running
+func (cpu *cpu) halted() *HaltError {
+	cpu.mu.Lock()
+	defer cpu.mu.Unlock()
+	return cpu.halt
 }
 
 func (cpu *cpu) step() {
@@ -103,6 +156,13 @@ func (cpu *cpu) step() {
 		cpu.tracer.setCPURegisters(cpu)
 	}
 
+	if cpu.halt != nil {
+		// Only reset recovers the jammed CPU, the interrupts are ignored.
+		// The rest of the console keeps running.
+		cpu.bus.tick(1)
+		return
+	}
+
 	beforeClock := cpu.bus.clock
 
 	additionalCycle := 0
@@ -263,9 +323,8 @@ func (cpu *cpu) step() {
 		if opcode.mode != implied {
 			cpu.read(addr) // dummy read
 		}
-	// case KIL:
-	// 	// TODO
-	// 	cpu.kil()
+	case KIL:
+		cpu.kil(opcodeByte)
 	case SLO:
 		cpu.slo(addr)
 	case ANC:
@@ -282,16 +341,24 @@ func (cpu *cpu) step() {
 		cpu.arr(addr)
 	case SAX:
 		cpu.sax(addr)
-	// case XAA:
-	// case AHX:
-	// case TAS:
+	case XAA:
+		cpu.xaa(addr)
+	case AHX:
+		cpu.ahx(addr)
+	case TAS:
+		cpu.tas(addr)
 	case SHY:
 		cpu.shy(addr)
 	case SHX:
 		cpu.shx(addr)
 	case LAX:
-		cpu.lax(addr)
-	// case LAS:
+		if opcode.mode == immediate {
+			cpu.laxImm(addr)
+		} else {
+			cpu.lax(addr)
+		}
+	case LAS:
+		cpu.las(addr)
 	case DCP:
 		cpu.dcp(addr)
 	case AXS:
@@ -382,12 +449,25 @@ func (cpu *cpu) clearNMIInterruptState() {
 }
 
 func (cpu *cpu) read(addr uint16) byte {
-	cpu.bus.runDMAIfOccurred(true)
+	if cpu.retryDMA {
+		if cpu.bus.stepDMA(addr, true) {
+			cpu.dmaStolen = true
+			return 0
+		}
+		cpu.dmaHalted = cpu.dmaStolen
+		cpu.dmaStolen = false
+	} else {
+		cpu.dmaHalted = false
+		for cpu.bus.stepDMA(addr, true) {
+			cpu.dmaHalted = true
+		}
+	}
 	cpu.pollInterruptSignals()
 	cpu.bus.clock++
 	cpu.bus.ppu.step()
 	cpu.bus.ppu.step()
 	cpu.bus.apu.step()
+	cpu.bus.tickMapper()
 	ret := cpu.bus.read(addr)
 	cpu.bus.ppu.step()
 	cpu.pollInterrupts()
@@ -395,12 +475,13 @@ func (cpu *cpu) read(addr uint16) byte {
 }
 
 func (cpu *cpu) write(addr uint16, val byte) {
-	cpu.bus.runDMAIfOccurred(false)
+	cpu.bus.stepDMA(addr, false)
 	cpu.pollInterruptSignals()
 	cpu.bus.clock++
 	cpu.bus.ppu.step()
 	cpu.bus.ppu.step()
 	cpu.bus.apu.step()
+	cpu.bus.tickMapper()
 	cpu.bus.write(addr, val)
 	cpu.bus.ppu.step()
 	cpu.pollInterrupts()
@@ -552,7 +633,9 @@ func (cpu *cpu) addressingIndexedIndirect(_ *opcode) (addr uint16, pageCrossed b
 
 	a := uint16(k + cpu.X)
 	b := (a & 0xFF00) | uint16(byte(a)+1)
-	addr = uint16(cpu.read(b))<<8 | uint16(cpu.read(a))
+	// The low byte is read before the high byte, the order shows on the registers with side effects and the open bus
+	l := cpu.read(a)
+	addr = uint16(cpu.read(b))<<8 | uint16(l)
 
 	if cpu.tracer != nil {
 		cpu.tracer.addCPUByteCode(k)
@@ -566,7 +649,9 @@ func (cpu *cpu) addressingIndirect(_ *opcode) (addr uint16, pageCrossed bool) {
 	h := cpu.fetch()
 	a := uint16(h)<<8 | uint16(l)
 	b := (a & 0xFF00) | uint16(byte(a)+1)
-	addr = uint16(cpu.read(b))<<8 | uint16(cpu.read(a))
+	// The low byte is read before the high byte, the order shows on the registers with side effects and the open bus
+	pl := cpu.read(a)
+	addr = uint16(cpu.read(b))<<8 | uint16(pl)
 
 	if cpu.tracer != nil {
 		cpu.tracer.addCPUByteCode(l)
@@ -579,7 +664,9 @@ func (cpu *cpu) addressingIndirect(_ *opcode) (addr uint16, pageCrossed bool) {
 func (cpu *cpu) addressingIndirectIndexed(_ *opcode, forceDummyRead bool) (addr uint16, pageCrossed bool) {
 	a := uint16(cpu.fetch())
 	b := (a & 0xFF00) | uint16(byte(a)+1)
-	baseAddr := uint16(cpu.read(b))<<8 | uint16(cpu.read(a))
+	// The low byte is read before the high byte, the order shows on the registers with side effects and the open bus
+	bl := cpu.read(a)
+	baseAddr := uint16(cpu.read(b))<<8 | uint16(bl)
 	addr = baseAddr + uint16(cpu.Y)
 	pageCrossed = pagesCross(addr, addr-uint16(cpu.Y))
 	if pageCrossed || forceDummyRead {
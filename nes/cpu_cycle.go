@@ -0,0 +1,496 @@
+package nes
+
+// The cycle-stepped CPU core.
+// Unlike step, which executes a whole instruction at once, stepCycle advances exactly one bus cycle (one read or write),
+// so that the DMA halts, the interrupt polling and the register reads interleave with the instruction in the order of the real CPU.
+// Each instruction is a sequence of the micro-ops after the opcode fetch, they are generated from opcodeMap.
+//
+// The cycle by cycle behavior follows https://www.nesdev.org/6502_cpu.txt
+// The interrupt polling is the same as the instruction core, so that both cores run the same.
+// DMA takes a cycle at a time, the CPU halted on a read cycle retries it after DMA.
+// The tracer is not supported by this core.
+
+// microOp runs a cycle of the instruction
+type microOp func(cpu *cpu)
+
+// cycleState is the state of the instruction in progress
+type cycleState struct {
+	ops []microOp
+	// next is the index of the next micro-op, the instruction is done when it reaches len(ops)
+	next   int
+	opcode byte
+	// addr is the effective address, base is the address before adding the index register
+	addr uint16
+	base uint16
+	// val is the value read by a read-modify-write instruction
+	val byte
+}
+
+var cycleOps [256][]microOp
+
+func init() {
+	for i, op := range opcodeMap {
+		cycleOps[i] = newMicroOps(byte(i), op)
+	}
+}
+
+// The operations of the instructions, that access the bus only once at the effective address
+var (
+	cycleReadOps = map[Mnemonic]func(cpu *cpu, addr uint16){
+		LDA: (*cpu).lda,
+		LDX: (*cpu).ldx,
+		LDY: (*cpu).ldy,
+		EOR: (*cpu).eor,
+		AND: (*cpu).and,
+		ORA: (*cpu).ora,
+		ADC: (*cpu).adc,
+		SBC: (*cpu).sbc,
+		CMP: (*cpu).cmp,
+		CPX: (*cpu).cpx,
+		CPY: (*cpu).cpy,
+		BIT: (*cpu).bit,
+		LAX: (*cpu).lax,
+		LAS: (*cpu).las,
+		ANC: (*cpu).anc,
+		ALR: (*cpu).alr,
+		ARR: (*cpu).arr,
+		AXS: (*cpu).axs,
+		XAA: (*cpu).xaa,
+		NOP: func(cpu *cpu, addr uint16) {
+			cpu.read(addr) // dummy read
+		},
+	}
+	cycleWriteOps = map[Mnemonic]func(cpu *cpu, addr uint16){
+		STA: (*cpu).sta,
+		STX: (*cpu).stx,
+		STY: (*cpu).sty,
+		SAX: (*cpu).sax,
+		AHX: (*cpu).ahx,
+		TAS: (*cpu).tas,
+		SHX: (*cpu).shx,
+		SHY: (*cpu).shy,
+	}
+	cycleModifyOps = map[Mnemonic]func(cpu *cpu, v byte) byte{
+		ASL: (*cpu).aslValue,
+		LSR: (*cpu).lsrValue,
+		ROL: (*cpu).rolValue,
+		ROR: (*cpu).rorValue,
+		INC: (*cpu).incValue,
+		DEC: (*cpu).decValue,
+		SLO: (*cpu).sloValue,
+		SRE: (*cpu).sreValue,
+		RLA: (*cpu).rlaValue,
+		RRA: (*cpu).rraValue,
+		ISB: (*cpu).isbValue,
+		DCP: (*cpu).dcpValue,
+	}
+	cycleImpliedOps = map[Mnemonic]func(cpu *cpu){
+		TAX: (*cpu).tax,
+		TAY: (*cpu).tay,
+		TSX: (*cpu).tsx,
+		TXA: (*cpu).txa,
+		TXS: (*cpu).txs,
+		TYA: (*cpu).tya,
+		DEX: (*cpu).dex,
+		DEY: (*cpu).dey,
+		INX: (*cpu).inx,
+		INY: (*cpu).iny,
+		CLC: (*cpu).clc,
+		CLD: (*cpu).cld,
+		CLI: (*cpu).cli,
+		CLV: (*cpu).clv,
+		SEC: (*cpu).sec,
+		SED: (*cpu).sed,
+		SEI: (*cpu).sei,
+		NOP: func(cpu *cpu) {},
+	}
+	cycleAccumulatorOps = map[Mnemonic]func(cpu *cpu){
+		ASL: (*cpu).aslAcc,
+		LSR: (*cpu).lsrAcc,
+		ROL: (*cpu).rolAcc,
+		ROR: (*cpu).rorAcc,
+	}
+	cycleBranchConditions = map[Mnemonic]func(p processorStatus) bool{
+		BCC: func(p processorStatus) bool { return !p.isCarry() },
+		BCS: func(p processorStatus) bool { return p.isCarry() },
+		BEQ: func(p processorStatus) bool { return p.isZero() },
+		BMI: func(p processorStatus) bool { return p.isNegative() },
+		BNE: func(p processorStatus) bool { return !p.isZero() },
+		BPL: func(p processorStatus) bool { return !p.isNegative() },
+		BVC: func(p processorStatus) bool { return !p.isOverflow() },
+		BVS: func(p processorStatus) bool { return p.isOverflow() },
+	}
+)
+
+// newMicroOps returns the cycles after the opcode fetch
+func newMicroOps(opcodeByte byte, op *opcode) []microOp {
+	switch op.name {
+	case PHA:
+		return []microOp{readPC, func(cpu *cpu) { cpu.push(cpu.A) }}
+	case PHP:
+		return []microOp{readPC, func(cpu *cpu) { cpu.push(cpu.P.byte() | 0x30) }}
+	case PLA:
+		return []microOp{readPC, readStack, func(cpu *cpu) {
+			cpu.A = cpu.pop()
+			cpu.P.setZN(cpu.A)
+		}}
+	case PLP:
+		return []microOp{readPC, readStack, func(cpu *cpu) {
+			cpu.P = processorStatus((cpu.pop() & 0xEF) | (1 << 5))
+		}}
+	case JSR:
+		return []microOp{
+			fetchAddrLow,
+			readStack,
+			func(cpu *cpu) { cpu.push(byte(cpu.PC >> 8)) },
+			func(cpu *cpu) { cpu.push(byte(cpu.PC)) },
+			func(cpu *cpu) {
+				fetchAddrHigh(cpu)
+				cpu.PC = cpu.cycle.addr
+			},
+		}
+	case RTS:
+		return []microOp{
+			readPC,
+			readStack,
+			func(cpu *cpu) { cpu.PC = uint16(cpu.pop()) },
+			func(cpu *cpu) { cpu.PC |= uint16(cpu.pop()) << 8 },
+			func(cpu *cpu) {
+				cpu.read(cpu.PC) // dummy read
+				cpu.PC++
+			},
+		}
+	case RTI:
+		return []microOp{
+			readPC,
+			readStack,
+			func(cpu *cpu) { cpu.P = processorStatus((cpu.pop() & 0xEF) | (1 << 5)) },
+			func(cpu *cpu) { cpu.PC = uint16(cpu.pop()) },
+			func(cpu *cpu) { cpu.PC |= uint16(cpu.pop()) << 8 },
+		}
+	case BRK:
+		return []microOp{
+			func(cpu *cpu) {
+				cpu.fetch() // dummy read
+				cpu.interrupting = true
+			},
+			pushPCH,
+			pushPCL,
+			func(cpu *cpu) { pushStatus(cpu, 0x30, 0xFFFE) },
+			fetchVectorLow,
+			fetchVectorHigh,
+		}
+	case JMP:
+		if op.mode == indirect {
+			return []microOp{
+				fetchAddrLow,
+				fetchAddrHigh,
+				func(cpu *cpu) { cpu.PC = uint16(cpu.read(cpu.cycle.addr)) },
+				func(cpu *cpu) {
+					// The high byte doesn't cross the page
+					a := cpu.cycle.addr
+					cpu.PC |= uint16(cpu.read((a&0xFF00)|uint16(byte(a)+1))) << 8
+				},
+			}
+		}
+		return []microOp{
+			fetchAddrLow,
+			func(cpu *cpu) {
+				fetchAddrHigh(cpu)
+				cpu.PC = cpu.cycle.addr
+			},
+		}
+	case KIL:
+		return []microOp{func(cpu *cpu) {
+			readPC(cpu)
+			cpu.kil(opcodeByte)
+		}}
+	}
+
+	if cond, ok := cycleBranchConditions[op.name]; ok {
+		return newBranchMicroOps(cond)
+	}
+
+	switch op.mode {
+	case implied:
+		f := cycleImpliedOps[op.name]
+		return []microOp{func(cpu *cpu) {
+			readPC(cpu)
+			f(cpu)
+		}}
+	case accumulator:
+		f := cycleAccumulatorOps[op.name]
+		return []microOp{func(cpu *cpu) {
+			readPC(cpu)
+			f(cpu)
+		}}
+	case immediate:
+		f := cycleReadOps[op.name]
+		if op.name == LAX {
+			f = (*cpu).laxImm
+		}
+		return []microOp{func(cpu *cpu) {
+			addr := cpu.PC
+			cpu.PC++
+			f(cpu, addr)
+		}}
+	}
+
+	var ops []microOp
+	if f, ok := cycleReadOps[op.name]; ok {
+		ops = newAddressingMicroOps(op.mode, false)
+		ops = append(ops, func(cpu *cpu) { f(cpu, cpu.cycle.addr) })
+	} else if f, ok := cycleWriteOps[op.name]; ok {
+		ops = newAddressingMicroOps(op.mode, true)
+		ops = append(ops, func(cpu *cpu) { f(cpu, cpu.cycle.addr) })
+	} else if f, ok := cycleModifyOps[op.name]; ok {
+		ops = newAddressingMicroOps(op.mode, true)
+		ops = append(ops,
+			func(cpu *cpu) { cpu.cycle.val = cpu.read(cpu.cycle.addr) },
+			func(cpu *cpu) { cpu.write(cpu.cycle.addr, cpu.cycle.val) }, // dummy write
+			func(cpu *cpu) { cpu.write(cpu.cycle.addr, f(cpu, cpu.cycle.val)) },
+		)
+	} else {
+		panic("Unable to reach: opcode.Name:" + op.name.String())
+	}
+	return ops
+}
+
+// newAddressingMicroOps returns the cycles to calculate the effective address.
+// The indexed modes always read the address before fixing the high byte if fixup is true (write and read-modify-write instructions),
+// otherwise only when the page is crossed.
+func newAddressingMicroOps(mode addressingMode, fixup bool) []microOp {
+	switch mode {
+	case zeroPage:
+		return []microOp{fetchAddrLow}
+	case zeroPageX:
+		return []microOp{fetchAddrLow, func(cpu *cpu) {
+			cpu.read(cpu.cycle.addr) // dummy read
+			cpu.cycle.addr = uint16(byte(cpu.cycle.addr) + cpu.X)
+		}}
+	case zeroPageY:
+		return []microOp{fetchAddrLow, func(cpu *cpu) {
+			cpu.read(cpu.cycle.addr) // dummy read
+			cpu.cycle.addr = uint16(byte(cpu.cycle.addr) + cpu.Y)
+		}}
+	case absolute:
+		return []microOp{fetchAddrLow, fetchAddrHigh}
+	case absoluteX, absoluteX_D:
+		fixup = fixup || mode == absoluteX_D
+		return []microOp{fetchAddrLow, func(cpu *cpu) {
+			fetchAddrHigh(cpu)
+			addIndex(cpu, cpu.X, fixup)
+		}, readUnfixedAddr}
+	case absoluteY, absoluteY_D:
+		fixup = fixup || mode == absoluteY_D
+		return []microOp{fetchAddrLow, func(cpu *cpu) {
+			fetchAddrHigh(cpu)
+			addIndex(cpu, cpu.Y, fixup)
+		}, readUnfixedAddr}
+	case indexedIndirect:
+		return []microOp{
+			fetchAddrLow,
+			func(cpu *cpu) {
+				cpu.read(cpu.cycle.addr) // dummy read
+				cpu.cycle.base = uint16(byte(cpu.cycle.addr) + cpu.X)
+			},
+			func(cpu *cpu) { cpu.cycle.addr = uint16(cpu.read(cpu.cycle.base)) },
+			func(cpu *cpu) {
+				cpu.cycle.addr |= uint16(cpu.read(uint16(byte(cpu.cycle.base)+1))) << 8
+			},
+		}
+	case indirectIndexed, indirectIndexed_D:
+		fixup = fixup || mode == indirectIndexed_D
+		return []microOp{
+			func(cpu *cpu) { cpu.cycle.base = uint16(cpu.fetch()) },
+			func(cpu *cpu) { cpu.cycle.addr = uint16(cpu.read(cpu.cycle.base)) },
+			func(cpu *cpu) {
+				cpu.cycle.addr |= uint16(cpu.read(uint16(byte(cpu.cycle.base)+1))) << 8
+				addIndex(cpu, cpu.Y, fixup)
+			},
+			readUnfixedAddr,
+		}
+	default:
+		panic("unknown addressing mode")
+	}
+}
+
+/*
+Relative addressing (BCC, BCS, BNE, BEQ, BPL, BMI, BVC, BVS)
+
+	#   address  R/W description
+	--- --------- --- ---------------------------------------------
+	1     PC      R  fetch opcode, increment PC
+	2     PC      R  fetch operand, increment PC
+	3     PC      R  Fetch opcode of next instruction,
+	                 If branch is taken, add operand to PCL.
+	                 Otherwise increment PC.
+	4+    PC*     R  Fetch opcode of next instruction.
+	                 Fix PCH. If it did not change, increment PC.
+*/
+func newBranchMicroOps(cond func(p processorStatus) bool) []microOp {
+	return []microOp{
+		func(cpu *cpu) {
+			offset := cpu.fetch()
+			cpu.cycle.addr = cpu.PC + uint16(int8(offset))
+			if !cond(cpu.P) {
+				cpu.endInstruction()
+			}
+		},
+		func(cpu *cpu) {
+			pending := cpu.pendingInterrupt
+			cpu.read(cpu.PC) // dummy read
+			if !pagesCross(cpu.PC, cpu.cycle.addr) {
+				// The taken branch doesn't poll the interrupts in this cycle
+				cpu.pendingInterrupt = pending
+				cpu.PC = cpu.cycle.addr
+				cpu.endInstruction()
+			}
+		},
+		func(cpu *cpu) {
+			cpu.read((cpu.PC & 0xFF00) | (cpu.cycle.addr & 0x00FF)) // dummy read
+			cpu.PC = cpu.cycle.addr
+		},
+	}
+}
+
+// newInterruptMicroOps returns the cycles of NMI and IRQ after the first dummy read
+func newInterruptMicroOps(vector uint16) []microOp {
+	return []microOp{
+		readPC,
+		pushPCH,
+		pushPCL,
+		func(cpu *cpu) { pushStatus(cpu, 0x20, vector) },
+		fetchVectorLow,
+		fetchVectorHigh,
+	}
+}
+
+var (
+	nmiMicroOps = newInterruptMicroOps(0xFFFA)
+	irqMicroOps = newInterruptMicroOps(0xFFFE)
+)
+
+func readPC(cpu *cpu) {
+	cpu.read(cpu.PC) // dummy read
+}
+
+func readStack(cpu *cpu) {
+	cpu.read(0x100 | uint16(cpu.S)) // dummy read
+}
+
+func fetchAddrLow(cpu *cpu) {
+	cpu.cycle.addr = uint16(cpu.fetch())
+}
+
+func fetchAddrHigh(cpu *cpu) {
+	cpu.cycle.addr |= uint16(cpu.fetch()) << 8
+}
+
+// addIndex adds the index register to the address, and skips the dummy read of the next cycle if it's not needed
+func addIndex(cpu *cpu, index byte, fixup bool) {
+	cpu.cycle.base = cpu.cycle.addr
+	cpu.cycle.addr += uint16(index)
+	if !fixup && !pagesCross(cpu.cycle.base, cpu.cycle.addr) {
+		cpu.cycle.next++
+	}
+}
+
+// readUnfixedAddr reads the address whose high byte is not fixed yet
+func readUnfixedAddr(cpu *cpu) {
+	cpu.read((cpu.cycle.base & 0xFF00) | (cpu.cycle.addr & 0x00FF)) // dummy read
+}
+
+func pushPCH(cpu *cpu) {
+	cpu.push(byte(cpu.PC >> 8))
+}
+
+func pushPCL(cpu *cpu) {
+	cpu.push(byte(cpu.PC))
+}
+
+// pushStatus pushes P, and decides the vector.
+// NMI hijacks BRK and IRQ if it's detected before this cycle.
+func pushStatus(cpu *cpu, flags byte, vector uint16) {
+	nmi := vector == 0xFFFA
+	if cpu.nmiSignal || cpu.nmiTriggered {
+		vector = 0xFFFA
+		nmi = true
+	}
+	cpu.push(cpu.P.byte() | flags)
+	cpu.P.setInterruptDisable(true)
+	cpu.cycle.addr = vector
+	if nmi {
+		cpu.cycle.base = 1
+	} else {
+		cpu.cycle.base = 0
+	}
+}
+
+func fetchVectorLow(cpu *cpu) {
+	cpu.PC = uint16(cpu.read(cpu.cycle.addr))
+}
+
+func fetchVectorHigh(cpu *cpu) {
+	cpu.PC |= uint16(cpu.read(cpu.cycle.addr+1)) << 8
+	if cpu.cycle.base == 1 {
+		cpu.clearNMIInterruptState()
+	}
+	cpu.interrupting = false
+}
+
+func (cpu *cpu) endInstruction() {
+	cpu.cycle.next = len(cpu.cycle.ops)
+}
+
+// atInstructionBoundary returns true if the next cycle starts an instruction or an interrupt sequence
+func (cpu *cpu) atInstructionBoundary() bool {
+	return cpu.halt != nil || (!cpu.dmaStolen && cpu.cycle.next >= len(cpu.cycle.ops))
+}
+
+// stepCycle runs a CPU cycle
+func (cpu *cpu) stepCycle() {
+	cpu.mu.Lock()
+	defer cpu.mu.Unlock()
+
+	if cpu.halt != nil {
+		cpu.bus.tick(1)
+		return
+	}
+
+	// DMA halts the CPU on a read cycle and takes the cycle, then the CPU retries the read cycle in the next step.
+	// A micro-op changes the registers only around its bus access, so the state before the cycle undoes it.
+	saved := *cpu
+	cpu.retryDMA = true
+	cpu.runCycle()
+	cpu.retryDMA = false
+	if cpu.dmaStolen {
+		*cpu = saved
+		cpu.dmaStolen = true
+	}
+}
+
+func (cpu *cpu) runCycle() {
+	if cpu.cycle.next < len(cpu.cycle.ops) {
+		op := cpu.cycle.ops[cpu.cycle.next]
+		cpu.cycle.next++
+		op(cpu)
+		return
+	}
+
+	// the first cycle of an instruction or an interrupt
+	cpu.cycle.next = 0
+	switch cpu.pendingInterrupt {
+	case pendingInterruptNMI:
+		cpu.cycle.ops = nmiMicroOps
+		cpu.interrupting = true
+		readPC(cpu)
+	case pendingInterruptIRQ:
+		cpu.cycle.ops = irqMicroOps
+		cpu.interrupting = true
+		readPC(cpu)
+	default:
+		cpu.cycle.opcode = cpu.fetch()
+		cpu.cycle.ops = cycleOps[cpu.cycle.opcode]
+	}
+}
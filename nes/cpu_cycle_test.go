@@ -0,0 +1,178 @@
+package nes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cpuSnapshot struct {
+	A, X, Y, S byte
+	P          processorStatus
+	PC         uint16
+	clock      int
+	halted     bool
+}
+
+func takeCPUSnapshot(n *NES) cpuSnapshot {
+	return cpuSnapshot{
+		A:      n.cpu.A,
+		X:      n.cpu.X,
+		Y:      n.cpu.Y,
+		S:      n.cpu.S,
+		P:      n.cpu.P,
+		PC:     n.cpu.PC,
+		clock:  n.bus.realClock(),
+		halted: n.cpu.halt != nil,
+	}
+}
+
+// newCrossCheckCPUs returns the NES of the instruction core and the cycle-stepped core, that run the same program
+func newCrossCheckCPUs(ram []byte, program []byte, setup func(cpu *cpu)) (*NES, *NES) {
+	ns := []*NES{newTestCPU(nil), newTestCPU(nil, WithCycleStepCPU())}
+	for _, n := range ns {
+		copy(n.bus.ram, ram)
+		copy(n.bus.ram[0x0200:], program)
+		if setup != nil {
+			setup(n.cpu)
+		}
+	}
+	return ns[0], ns[1]
+}
+
+func Test_CPU_CycleStepCrossCheck_Opcodes(t *testing.T) {
+	t.Parallel()
+
+	ram := make([]byte, 0x800)
+	for i := range ram {
+		ram[i] = byte(i * 7)
+	}
+	// pointers of the indirect addressings
+	ram[0xF0] = 0xF0
+	ram[0xF1] = 0x03
+
+	setups := []func(cpu *cpu){
+		func(cpu *cpu) {
+			// the indexes cross the page
+			cpu.A, cpu.X, cpu.Y = 0x5A, 0x20, 0x30
+			cpu.P = processorStatus(0x24)
+		},
+		func(cpu *cpu) {
+			cpu.A, cpu.X, cpu.Y = 0xA5, 0x01, 0x02
+			cpu.P = processorStatus(0xE7)
+			cpu.S = 0x10
+		},
+	}
+
+	for op := 0; op < 256; op++ {
+		op := op
+		t.Run(fmt.Sprintf("%02X", op), func(t *testing.T) {
+			t.Parallel()
+			for i, setup := range setups {
+				want, got := newCrossCheckCPUs(ram, []byte{byte(op), 0xF0, 0x03}, setup)
+				for step := 0; step < 3; step++ {
+					want.Step()
+					got.Step()
+					assert.Equal(t, takeCPUSnapshot(want), takeCPUSnapshot(got), "setup:%d step:%d", i, step)
+				}
+				assert.Equal(t, want.bus.ram, got.bus.ram, "setup:%d", i)
+			}
+		})
+	}
+}
+
+func Test_CPU_CycleStepCrossCheck_InterruptsAndDMA(t *testing.T) {
+	t.Parallel()
+
+	ram := make([]byte, 0x800)
+	// The NMI and IRQ vectors point $0000 in the zero-filled PRG ROM.
+	// $0000: LDA $4015 ; acknowledge the frame IRQ
+	// $0003: RTI
+	copy(ram, []byte{0xAD, 0x15, 0x40, 0x40})
+
+	program := []byte{
+		0xA9, 0x80, // LDA #$80
+		0x8D, 0x00, 0x20, // STA $2000 ; enable NMI
+		0xA9, 0x00, // LDA #$00
+		0x8D, 0x17, 0x40, // STA $4017 ; enable the frame IRQ
+		0xA9, 0x4F, // LDA #$4F
+		0x8D, 0x10, 0x40, // STA $4010 ; DMC loop, the fastest rate
+		0xA9, 0x01, // LDA #$01
+		0x8D, 0x13, 0x40, // STA $4013
+		0xA9, 0x10, // LDA #$10
+		0x8D, 0x15, 0x40, // STA $4015 ; start DMC DMA
+		0x58, // CLI
+		// $021A: loop
+		0xBD, 0xF0, 0x03, // LDA $03F0,X
+		0x9D, 0x00, 0x03, // STA $0300,X
+		0xE8,       // INX
+		0xD0, 0xF7, // BNE loop
+		0xA9, 0x03, // LDA #$03
+		0x8D, 0x14, 0x40, // STA $4014 ; OAM DMA
+		0x4C, 0x1A, 0x02, // JMP loop
+	}
+
+	want, got := newCrossCheckCPUs(ram, program, nil)
+	for step := 0; step < 40000; step++ {
+		want.Step()
+		got.Step()
+		if !assert.Equal(t, takeCPUSnapshot(want), takeCPUSnapshot(got), "step:%d", step) {
+			return
+		}
+	}
+	assert.Equal(t, want.bus.ram, got.bus.ram)
+}
+
+func Test_CPU_CycleStep_BranchDelaysIRQ(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		program []byte
+		wantPC  []uint16 // PC after each instruction
+	}{
+		// BNE taken without the page crossing doesn't poll the IRQ in the last cycle, NOP runs before the IRQ
+		{"1", []byte{0xD0, 0x00, 0xEA, 0xEA}, []uint16{0x0202, 0x0203, 0x0000}},
+		// LDA $00 of the same cycles polls the IRQ in the last cycle
+		{"2", []byte{0xA5, 0x00, 0xEA, 0xEA}, []uint16{0x0202, 0x0000}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// The IRQ vector points $0000 in the zero-filled PRG ROM
+			n := newTestCPU(tt.program, WithCycleStepCPU())
+			n.cpu.P = processorStatus(0x20)
+			n.StepCycle()
+			// assert IRQ during the operand fetch
+			n.cpu.irqLine.setLow(irqSourceMapper)
+			for i, want := range tt.wantPC {
+				n.Step()
+				assert.Equal(t, want, n.cpu.PC, "instruction:%d", i)
+			}
+		})
+	}
+}
+
+func Test_CPU_CycleStep_DMA(t *testing.T) {
+	t.Parallel()
+	// LDA #$03, STA $4014, NOP
+	n := newTestCPU([]byte{0xA9, 0x03, 0x8D, 0x14, 0x40, 0xEA}, WithCycleStepCPU())
+	n.Step()
+	n.Step()
+	clock := n.Clock()
+
+	// OAM DMA takes a cycle at a time, the opcode fetch of NOP is retried after DMA
+	cycles := 0
+	for n.cpu.PC == 0x0205 {
+		n.StepCycle()
+		cycles++
+		assert.Equal(t, clock+cycles, n.Clock())
+	}
+	// 513 or 514 cycles of DMA and the opcode fetch
+	assert.Contains(t, []int{514, 515}, cycles)
+	n.Step()
+	assert.Equal(t, uint16(0x0206), n.cpu.PC)
+	assert.Equal(t, clock+cycles+1, n.Clock())
+}
@@ -1,5 +1,11 @@
 package nes
 
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
 type fakePPU struct {
 	ppu
 }
@@ -179,3 +185,111 @@ type fakeJoypad struct {
 // 		})
 // 	}
 // }
+
+func newTestCPU(program []byte, options ...Option) *NES {
+	m := newMapper0(&Cassette{
+		PRG: make([]byte, 0x4000),
+		CHR: make([]byte, 0x2000),
+	})
+	n := New(m, nopRenderer{}, &fakePlayer{}, options...)
+	copy(n.bus.ram[0x0200:], program)
+	n.cpu.PC = 0x0200
+	n.cpu.S = 0xFD
+	return n
+}
+
+func Test_CPU_UnstableOpcodes(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		program  []byte
+		profile  UnstableOpcodeProfile
+		a, x, y  byte
+		s        byte
+		dmcDelay byte
+		wantA    byte
+		wantX    byte
+		wantS    byte
+		wantAddr uint16
+		wantMem  byte
+	}{
+		// XAA #$FF
+		{"1", []byte{0x8B, 0xFF}, DefaultUnstableOpcodeProfile, 0x01, 0x03, 0, 0xFD, 0, 0x03, 0x03, 0xFD, 0, 0},
+		{"2", []byte{0x8B, 0xFF}, UnstableOpcodeProfile{}, 0x01, 0x03, 0, 0xFD, 0, 0x01, 0x03, 0xFD, 0, 0},
+		// LAX #$0F
+		{"3", []byte{0xAB, 0x0F}, DefaultUnstableOpcodeProfile, 0x10, 0, 0, 0xFD, 0, 0x0F, 0x0F, 0xFD, 0, 0},
+		{"4", []byte{0xAB, 0x0F}, UnstableOpcodeProfile{LAXMagic: 0xEE}, 0x10, 0, 0, 0xFD, 0, 0x0E, 0x0E, 0xFD, 0, 0},
+		// LAS $0280,Y
+		{"5", []byte{0xBB, 0x7F, 0x02}, DefaultUnstableOpcodeProfile, 0, 0, 0x01, 0x3C, 0, 0x30, 0x30, 0x30, 0, 0},
+		// SHX $0300,Y
+		{"6", []byte{0x9E, 0x00, 0x03}, DefaultUnstableOpcodeProfile, 0, 0xFF, 0x01, 0xFD, 0, 0, 0xFF, 0xFD, 0x0301, 0x04},
+		// SHY $02F0,X crosses the page, the value $01 & $03 replaces the high byte
+		{"7", []byte{0x9C, 0xF0, 0x02}, DefaultUnstableOpcodeProfile, 0, 0x20, 0x01, 0xFD, 0, 0, 0x20, 0xFD, 0x0110, 0x01},
+		// TAS $0300,Y
+		{"8", []byte{0x9B, 0x00, 0x03}, DefaultUnstableOpcodeProfile, 0xF7, 0x3F, 0x01, 0xFD, 0, 0xF7, 0x3F, 0x37, 0x0301, 0x04},
+		// AHX ($80),Y, $80 = $0300
+		{"9", []byte{0x93, 0x80}, DefaultUnstableOpcodeProfile, 0xFF, 0x0E, 0x01, 0xFD, 0, 0xFF, 0x0E, 0xFD, 0x0301, 0x04},
+		// SHX $0300,Y, DMC DMA halts the CPU on the dummy read, the & (H+1) drops off
+		{"10", []byte{0x9E, 0x00, 0x03}, DefaultUnstableOpcodeProfile, 0, 0xFF, 0x01, 0xFD, 4, 0, 0xFF, 0xFD, 0x0301, 0xFF},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			n := newTestCPU(tt.program, WithUnstableOpcodes(tt.profile))
+			n.bus.ram[0x80] = 0x00
+			n.bus.ram[0x81] = 0x03
+			n.bus.ram[0x0280] = 0xF0
+			n.cpu.A = tt.a
+			n.cpu.X = tt.x
+			n.cpu.Y = tt.y
+			n.cpu.S = tt.s
+			n.bus.dma.dmcDelay = tt.dmcDelay
+			n.Step()
+			assert.Equal(t, tt.wantA, n.cpu.A)
+			assert.Equal(t, tt.wantX, n.cpu.X)
+			assert.Equal(t, tt.wantS, n.cpu.S)
+			assert.Equal(t, tt.wantMem, n.bus.ram[tt.wantAddr])
+		})
+	}
+}
+
+func Test_CPU_IndirectReadOrder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{"1", nil},
+		{"2", []Option{WithCycleStepCPU()}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			// JMP ($2001), the low byte is the PPU open bus, and the high byte is PPUSTATUS that loads the open bus.
+			// If the high byte were read first, the low byte would be $80.
+			n := newTestCPU([]byte{0x6C, 0x01, 0x20}, tt.options...)
+			n.bus.ppu.status.setVBlankStarted()
+			n.Step()
+			assert.Equal(t, uint16(0x8000), n.cpu.PC)
+		})
+	}
+}
+
+func Test_CPU_KIL(t *testing.T) {
+	t.Parallel()
+	// KIL, LDA #$01
+	n := newTestCPU([]byte{0x02, 0xA9, 0x01})
+	n.Step()
+	assert.Equal(t, &HaltError{PC: 0x0200, Opcode: 0x02}, n.Halted())
+	clock := n.bus.realClock()
+	for i := 0; i < 10; i++ {
+		n.Step()
+	}
+	assert.Equal(t, byte(0x00), n.cpu.A)
+	assert.Equal(t, clock+10, n.bus.realClock())
+	assert.EqualError(t, n.Halted(), "cpu jammed by KIL ($02) at $0200")
+}
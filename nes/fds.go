@@ -0,0 +1,141 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+)
+
+// https://www.nesdev.org/wiki/FDS_file_format
+// The .FDS format (fwNES format) is a way to store Famicom Disk System disk data.
+// The header is 16 bytes: "FDS" followed by $1A, the number of disk sides and zero filled.
+// Each disk side is 65500 bytes, the data is stored without the gaps and the CRCs.
+const (
+	fdsHeaderSize = 16
+	fdsSideSize   = 65500
+)
+
+var fdsMagic = []byte("FDS\x1a")
+
+// https://www.nesdev.org/wiki/FDS_disk_format
+// The drive reads the raw data including the gaps and the CRCs,
+// so each side is converted to the raw data for the drive emulation and converted back when saving.
+const (
+	// The first block is preceded by a gap of 28300 bits
+	fdsLeadingGap = 28300 / 8
+	// The other blocks are preceded by a gap of 976 bits
+	fdsBlockGap = 976 / 8
+	// the gap marker (a bit 1 at the end of the gap) and the 2 bytes CRC
+	fdsGapEnd = 0x80
+	fdsCRCLen = 2
+	// the raw data of each side has the room for the gaps
+	fdsRawSideSize = fdsSideSize + fdsLeadingGap + 4096
+)
+
+type fdsDisk struct {
+	header []byte   // nil if the image doesn't have the fwNES header
+	sides  [][]byte // raw data of each side
+}
+
+func newFDSDisk(image []byte) (*fdsDisk, error) {
+	d := &fdsDisk{}
+	if bytes.HasPrefix(image, fdsMagic) {
+		if len(image) < fdsHeaderSize {
+			return nil, errors.New("invalid fds file")
+		}
+		d.header = append([]byte{}, image[:fdsHeaderSize]...)
+		image = image[fdsHeaderSize:]
+	}
+	if len(image) == 0 || len(image)%fdsSideSize != 0 {
+		return nil, errors.New("invalid fds file: the size is not a multiple of the disk side")
+	}
+	for i := 0; i < len(image); i += fdsSideSize {
+		d.sides = append(d.sides, toFDSRawSide(image[i:i+fdsSideSize]))
+	}
+	return d, nil
+}
+
+// fdsBlockLen returns the length of the block starting with data, 0 if it isn't a block.
+// fileSize is the size in the last file header block.
+func fdsBlockLen(data []byte, fileSize int) int {
+	if len(data) == 0 {
+		return 0
+	}
+	switch data[0] {
+	case 1:
+		// Disk info block, 56 bytes
+		return 56
+	case 2:
+		// File amount block, 2 bytes
+		return 2
+	case 3:
+		// File header block, 16 bytes
+		return 16
+	case 4:
+		// File data block, the block code and the file data
+		return 1 + fileSize
+	default:
+		return 0
+	}
+}
+
+func toFDSRawSide(side []byte) []byte {
+	raw := make([]byte, fdsLeadingGap, fdsRawSideSize)
+	fileSize := 0
+	for pos := 0; pos < len(side); {
+		n := fdsBlockLen(side[pos:], fileSize)
+		if n == 0 || pos+n > len(side) {
+			break
+		}
+		if side[pos] == 3 {
+			fileSize = int(side[pos+13]) | int(side[pos+14])<<8
+		}
+		if pos > 0 {
+			raw = append(raw, make([]byte, fdsBlockGap)...)
+		}
+		raw = append(raw, fdsGapEnd)
+		raw = append(raw, side[pos:pos+n]...)
+		// The CRC is not checked, put a dummy value
+		raw = append(raw, 0x4D, 0x62)
+		pos += n
+	}
+	if len(raw) < fdsRawSideSize {
+		raw = append(raw, make([]byte, fdsRawSideSize-len(raw))...)
+	}
+	return raw
+}
+
+func fromFDSRawSide(raw []byte) []byte {
+	side := make([]byte, 0, fdsSideSize)
+	fileSize := 0
+	pos := 0
+	for {
+		// skip the gap
+		for pos < len(raw) && raw[pos] == 0 {
+			pos++
+		}
+		if pos >= len(raw) || raw[pos] != fdsGapEnd {
+			break
+		}
+		pos++
+		n := fdsBlockLen(raw[pos:], fileSize)
+		if n == 0 || pos+n > len(raw) || len(side)+n > fdsSideSize {
+			break
+		}
+		if raw[pos] == 3 {
+			fileSize = int(raw[pos+13]) | int(raw[pos+14])<<8
+		}
+		side = append(side, raw[pos:pos+n]...)
+		pos += n + fdsCRCLen
+	}
+	return append(side, make([]byte, fdsSideSize-len(side))...)
+}
+
+// image returns the disk image in the same format as the loaded one
+func (d *fdsDisk) image() []byte {
+	res := make([]byte, 0, len(d.header)+len(d.sides)*fdsSideSize)
+	res = append(res, d.header...)
+	for _, raw := range d.sides {
+		res = append(res, fromFDSRawSide(raw)...)
+	}
+	return res
+}
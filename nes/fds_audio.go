@@ -0,0 +1,233 @@
+package nes
+
+// https://www.nesdev.org/wiki/FDS_audio
+// The FDS has a wavetable channel (64 steps of 6 bits) with a frequency modulator (32 steps of 3 bits).
+// Both have a volume envelope unit, the envelopes are clocked every 8 * (master speed + 1) * (speed + 1) CPU cycles.
+
+// fdsModTable is the modulator table value to the change of the mod counter, 4 resets it to 0
+var fdsModTable = [8]int{0, 1, 2, 4, 0, -4, -2, -1}
+
+// fdsMasterVolume is the volume multiplier by $4089 bits 0-1: 2/2, 2/3, 2/4, 2/5
+var fdsMasterVolume = [4]float32{2.0 / 2, 2.0 / 3, 2.0 / 4, 2.0 / 5}
+
+type fdsEnvelope struct {
+	disabled bool // direct mode
+	increase bool
+	speed    byte // 6 bits
+	gain     byte // 6 bits
+	counter  int
+}
+
+// $4080, $4084
+func (e *fdsEnvelope) write(val byte) {
+	// 7  bit  0
+	// MDVV VVVV
+	// |||| ||||
+	// ||++-++++- (M=0) Envelope speed, (M=1) Volume gain
+	// |+-------- Envelope direction (0: decrease, 1: increase)
+	// +--------- Envelope mode (0: on, 1: off)
+	e.disabled = (val & 0x80) == 0x80
+	e.increase = (val & 0x40) == 0x40
+	e.speed = val & 0x3F
+	if e.disabled {
+		e.gain = val & 0x3F
+	}
+	e.counter = 0
+}
+
+func (e *fdsEnvelope) tick(masterSpeed byte) {
+	if e.disabled {
+		return
+	}
+	e.counter++
+	if e.counter < 8*(int(masterSpeed)+1)*(int(e.speed)+1) {
+		return
+	}
+	e.counter = 0
+	if e.increase && e.gain < 32 {
+		e.gain++
+	} else if !e.increase && e.gain > 0 {
+		e.gain--
+	}
+}
+
+type fdsAudio struct {
+	wave         [64]byte
+	waveWritable bool // $4089 bit 7, it also halts the wave output
+	masterVolume byte
+
+	volEnv      fdsEnvelope
+	modEnv      fdsEnvelope
+	envHalted   bool // $4083 bit 6
+	masterSpeed byte // $408A
+
+	freq       uint16 // 12 bits
+	waveHalted bool   // $4083 bit 7
+	waveAcc    uint32 // 6.16 fixed point position of 64 steps
+	output     byte
+	outputGain byte
+
+	modTable   [32]byte
+	modPos     byte // 0 to 63
+	modFreq    uint16
+	modHalted  bool // $4087 bit 7
+	modAcc     uint32
+	modCounter int // 7 bits signed
+}
+
+func newFDSAudio() *fdsAudio {
+	return &fdsAudio{
+		// $408A is initialized to $E8 by the BIOS
+		masterSpeed: 0xE8,
+	}
+}
+
+// read returns the readable registers, the upper 2 bits of them and the other registers are open bus
+func (a *fdsAudio) read(addr uint16, openBus byte) byte {
+	switch {
+	case 0x4040 <= addr && addr <= 0x407F:
+		return (openBus & 0xC0) | a.wave[addr-0x4040]
+	case addr == 0x4090:
+		return (openBus & 0xC0) | a.volEnv.gain
+	case addr == 0x4092:
+		return (openBus & 0xC0) | a.modEnv.gain
+	}
+	return openBus
+}
+
+func (a *fdsAudio) write(addr uint16, val byte) {
+	switch {
+	case 0x4040 <= addr && addr <= 0x407F:
+		if a.waveWritable {
+			a.wave[addr-0x4040] = val & 0x3F
+		}
+	case addr == 0x4080:
+		a.volEnv.write(val)
+	case addr == 0x4082:
+		a.freq = (a.freq & 0x0F00) | uint16(val)
+	case addr == 0x4083:
+		// 7  bit  0
+		// MExx FFFF
+		// ||   ||||
+		// ||   ++++- Frequency high 4 bits
+		// |+-------- Disable volume and mod envelopes
+		// +--------- Halt the wave and reset its phase
+		a.freq = (a.freq & 0x00FF) | (uint16(val&0x0F) << 8)
+		a.envHalted = (val & 0x40) == 0x40
+		a.waveHalted = (val & 0x80) == 0x80
+		if a.waveHalted {
+			a.waveAcc = 0
+		}
+		if a.envHalted {
+			a.volEnv.counter = 0
+			a.modEnv.counter = 0
+		}
+	case addr == 0x4084:
+		a.modEnv.write(val)
+	case addr == 0x4085:
+		// 7-bit signed mod counter
+		a.modCounter = int(int8(val<<1) >> 1)
+	case addr == 0x4086:
+		a.modFreq = (a.modFreq & 0x0F00) | uint16(val)
+	case addr == 0x4087:
+		a.modFreq = (a.modFreq & 0x00FF) | (uint16(val&0x0F) << 8)
+		a.modHalted = (val & 0x80) == 0x80
+		if a.modHalted {
+			a.modAcc = 0
+		}
+	case addr == 0x4088:
+		// The mod table is written only while the mod is halted,
+		// each write fills 2 steps and advances the position.
+		if a.modHalted {
+			a.modTable[a.modPos/2] = val & 0x07
+			a.modPos = (a.modPos + 2) & 0x3F
+		}
+	case addr == 0x4089:
+		a.waveWritable = (val & 0x80) == 0x80
+		a.masterVolume = val & 0x03
+	case addr == 0x408A:
+		a.masterSpeed = val
+	}
+}
+
+func (a *fdsAudio) tick() {
+	if !a.envHalted && !a.waveHalted && a.masterSpeed != 0 {
+		a.volEnv.tick(a.masterSpeed)
+		a.modEnv.tick(a.masterSpeed)
+	}
+
+	if !a.modHalted && a.modFreq != 0 {
+		a.modAcc += uint32(a.modFreq)
+		if a.modAcc >= 0x10000 {
+			a.modAcc &= 0xFFFF
+			a.stepMod()
+		}
+	}
+
+	if a.waveHalted || a.waveWritable {
+		// the output holds the last value
+		return
+	}
+	prev := a.waveAcc
+	a.waveAcc = (a.waveAcc + uint32(a.pitch())) & 0x3FFFFF
+	if a.waveAcc < prev {
+		// the volume gain is latched at the start of each wave cycle
+		a.outputGain = a.volEnv.gain
+		if a.outputGain > 32 {
+			a.outputGain = 32
+		}
+	}
+	a.output = a.wave[a.waveAcc>>16]
+}
+
+func (a *fdsAudio) stepMod() {
+	v := a.modTable[a.modPos/2]
+	if v == 4 {
+		a.modCounter = 0
+	} else {
+		a.modCounter += fdsModTable[v]
+		// wrap in 7 bits signed
+		if a.modCounter >= 64 {
+			a.modCounter -= 128
+		} else if a.modCounter < -64 {
+			a.modCounter += 128
+		}
+	}
+	a.modPos = (a.modPos + 1) & 0x3F
+}
+
+// pitch returns the wave frequency modulated by the mod unit
+func (a *fdsAudio) pitch() int {
+	// https://www.nesdev.org/wiki/FDS_audio#Frequency_calculation
+	temp := a.modCounter * int(a.modEnv.gain)
+	remainder := temp & 0x0F
+	temp >>= 4
+	if remainder > 0 && (temp&0x80) == 0 {
+		if a.modCounter < 0 {
+			temp--
+		} else {
+			temp += 2
+		}
+	}
+	if temp >= 192 {
+		temp -= 256
+	} else if temp < -64 {
+		temp += 256
+	}
+	temp = int(a.freq) * temp
+	remainder = temp & 0x3F
+	temp >>= 6
+	if remainder >= 32 {
+		temp++
+	}
+	p := int(a.freq) + temp
+	if p < 0 {
+		return 0
+	}
+	return p
+}
+
+// sample returns the output in the range of 0.0 to 1.0
+func (a *fdsAudio) sample() float32 {
+	return float32(a.output) * float32(a.outputGain) / (63 * 32) * fdsMasterVolume[a.masterVolume]
+}
@@ -0,0 +1,112 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFDSSide() []byte {
+	side := make([]byte, fdsSideSize)
+	pos := 0
+	// disk info block
+	side[pos] = 0x01
+	copy(side[pos+1:], "*NINTENDO-HVC*")
+	pos += 56
+	// file amount block
+	side[pos] = 0x02
+	side[pos+1] = 1
+	pos += 2
+	// file header block, the file size is 4
+	side[pos] = 0x03
+	side[pos+13] = 4
+	pos += 16
+	// file data block
+	copy(side[pos:], []byte{0x04, 0xDE, 0xAD, 0xBE, 0xEF})
+	return side
+}
+
+func Test_FDSDisk_Image(t *testing.T) {
+	t.Parallel()
+	side := newTestFDSSide()
+	tests := []struct {
+		name  string
+		image []byte
+	}{
+		{"1", append(append([]byte{}, side...), side...)},
+		{"2", append(append([]byte("FDS\x1a\x01"), make([]byte, 11)...), side...)},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			d, err := newFDSDisk(tt.image)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.image, d.image())
+		})
+	}
+}
+
+func Test_Mapper20_ReadDisk(t *testing.T) {
+	t.Parallel()
+	m, err := NewDiskSystem(newTestFDSSide(), make([]byte, 0x2000))
+	assert.NoError(t, err)
+	var irqLine irqInterruptLine
+	m.(irqMapper).connectIRQLine(&irqLine)
+	// the high byte of the address is left on the data bus
+	openBus := byte(0x40)
+	m.(openBusMapper).connectOpenBus(&openBus)
+	fds := m.(*mapper20)
+
+	m.Write(0x4023, 0x01)
+	// disk IRQ, transfer enable, read mode, motor on
+	m.Write(0x4025, 0xC5)
+	got := []byte{}
+	for i := 0; i < fdsRewindCycles+fdsLeadingGap*(fdsByteCycles+1)+20*(fdsByteCycles+1) && len(got) < 15; i++ {
+		fds.tickCPU()
+		if irqLine.isLow() {
+			got = append(got, m.Read(0x4031))
+			assert.True(t, irqLine.isHigh())
+		}
+	}
+	assert.Equal(t, append([]byte{0x01}, "*NINTENDO-HVC*"...), got)
+	assert.Equal(t, byte(0x40), m.Read(0x4032))
+}
+
+func Test_Mapper20_OpenBus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		reg  byte // $4023
+		addr uint16
+		want byte
+	}{
+		// the disk and sound registers are disabled
+		{"1", 0x00, 0x4030, 0x5A},
+		{"2", 0x00, 0x4031, 0x5A},
+		{"3", 0x00, 0x4032, 0x5A},
+		{"4", 0x00, 0x4090, 0x5A},
+		// the bits that aren't driven, the head is at the end and the disk isn't ready
+		{"5", 0x01, 0x4030, 0x48},
+		{"6", 0x01, 0x4032, 0x5A},
+		{"7", 0x02, 0x4040, 0x40},
+		{"8", 0x02, 0x4091, 0x5A},
+		{"9", 0x00, 0x5000, 0x5A},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m, err := NewDiskSystem(newTestFDSSide(), make([]byte, 0x2000))
+			assert.NoError(t, err)
+			var irqLine irqInterruptLine
+			m.(irqMapper).connectIRQLine(&irqLine)
+			openBus := byte(0x5A)
+			m.(openBusMapper).connectOpenBus(&openBus)
+			m.Write(0x4023, tt.reg)
+			assert.Equal(t, tt.want, m.Read(tt.addr))
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package nes
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gamedb.txt is the built-in database, generated from the XML export of NesCartDB by WriteGameDB (`make gamedb`).
+// Another database can be loaded by LoadGameDB.
+//
+//go:embed gamedb.txt
+var gameDBText []byte
+
+// gameDBHeader is the comment at the top of gamedb.txt
+const gameDBHeader = `# The game database to correct the iNES headers.
+# Generated from the XML export of NesCartDB (https://nescartdb.com/) by ` + "`make gamedb NESCARTDB=<xml>`" + `.
+# A game is identified by CRC32 (and SHA-1 if given) of PRG ROM followed by CHR ROM, without the header.
+#
+# Fields are separated by spaces, "-" means unknown (the header value is kept):
+#   crc32 sha1 mapper submapper mirroring battery prgram chrram region title
+#
+#   mirroring: H (horizontal), V (vertical), 4 (four-screen), M (controlled by the mapper)
+#   battery:   0 or 1
+#   prgram:    PRG RAM size in bytes including the battery-backed one
+#   chrram:    CHR RAM size in bytes, used only if the game has no CHR ROM
+#   region:    NTSC, PAL, Multi or Dendy
+#   title:     the rest of the line
+`
+
+type gameDBEntry struct {
+	crc32     uint32
+	sha1      string // empty if unknown
+	mapper    int    // -1 if unknown
+	submapper int
+	mirroring string
+	battery   int
+	prgRAM    int
+	chrRAM    int
+	region    string
+	title     string
+}
+
+var (
+	gameDB     map[uint32][]*gameDBEntry
+	gameDBOnce sync.Once
+	gameDBMu   sync.RWMutex
+)
+
+func loadGameDB() {
+	db, err := parseGameDB(gameDBText)
+	if err != nil {
+		panic(err)
+	}
+	gameDB = db
+}
+
+func parseGameDB(text []byte) (map[uint32][]*gameDBEntry, error) {
+	db := make(map[uint32][]*gameDBEntry)
+	s := bufio.NewScanner(bytes.NewReader(text))
+	for line := 1; s.Scan(); line++ {
+		t := strings.TrimSpace(s.Text())
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		f := strings.Fields(t)
+		if len(f) < 10 {
+			return nil, fmt.Errorf("gamedb: line %d: too few fields", line)
+		}
+		crc, err := strconv.ParseUint(f[0], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("gamedb: line %d: %w", line, err)
+		}
+		e := &gameDBEntry{
+			crc32:     uint32(crc),
+			mirroring: f[4],
+			region:    f[8],
+			title:     strings.Join(f[9:], " "),
+		}
+		if f[1] != "-" {
+			e.sha1 = strings.ToLower(f[1])
+		}
+		for i, p := range []*int{&e.mapper, &e.submapper, nil, &e.battery, &e.prgRAM, &e.chrRAM} {
+			if p == nil {
+				continue
+			}
+			*p = -1
+			if v := f[2+i]; v != "-" {
+				if *p, err = strconv.Atoi(v); err != nil {
+					return nil, fmt.Errorf("gamedb: line %d: %w", line, err)
+				}
+			}
+		}
+		db[e.crc32] = append(db[e.crc32], e)
+	}
+	return db, s.Err()
+}
+
+// LoadGameDB adds the games of the database to correct the iNES headers, they take precedence over the built-in ones.
+// It accepts the text format of gamedb.txt, or the XML export of NesCartDB (https://nescartdb.com/).
+// Call it before loading the roms.
+func LoadGameDB(r io.Reader) error {
+	db, err := readGameDB(r)
+	if err != nil {
+		return err
+	}
+
+	gameDBOnce.Do(loadGameDB)
+	gameDBMu.Lock()
+	defer gameDBMu.Unlock()
+	for crc, entries := range db {
+		gameDB[crc] = append(entries, gameDB[crc]...)
+	}
+	return nil
+}
+
+// readGameDB reads the text format of gamedb.txt, or the XML export of NesCartDB
+func readGameDB(r io.Reader) (map[uint32][]*gameDBEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if t := bytes.TrimSpace(data); len(t) > 0 && t[0] == '<' {
+		return parseNesCartDB(data)
+	}
+	return parseGameDB(data)
+}
+
+// WriteGameDB converts the database read by LoadGameDB to the text format of gamedb.txt.
+// The games are sorted by CRC32, so that the regenerated file makes a small diff.
+func WriteGameDB(w io.Writer, r io.Reader) error {
+	db, err := readGameDB(r)
+	if err != nil {
+		return err
+	}
+	crcs := make([]uint32, 0, len(db))
+	for crc := range db {
+		crcs = append(crcs, crc)
+	}
+	sort.Slice(crcs, func(i, j int) bool { return crcs[i] < crcs[j] })
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(gameDBHeader)
+	for _, crc := range crcs {
+		for _, e := range db[crc] {
+			bw.WriteString(e.String())
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// String returns the line of gamedb.txt
+func (e *gameDBEntry) String() string {
+	field := func(v int) string {
+		if v < 0 {
+			return "-"
+		}
+		return strconv.Itoa(v)
+	}
+	sha1 := e.sha1
+	if sha1 == "" {
+		sha1 = "-"
+	}
+	// the title is the rest of the line
+	title := strings.Join(strings.Fields(e.title), " ")
+	if title == "" {
+		title = "-"
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("%08X", e.crc32),
+		sha1,
+		field(e.mapper),
+		field(e.submapper),
+		e.mirroring,
+		field(e.battery),
+		field(e.prgRAM),
+		field(e.chrRAM),
+		e.region,
+		title,
+	}, " ")
+}
+
+// nesCartDB is the XML export of NesCartDB:
+//
+//	<database>
+//	  <game name="...">
+//	    <cartridge system="NES-NTSC" crc="3337EC46" sha1="...">
+//	      <board type="NES-NROM-256" mapper="0">
+//	        <prg size="32k"/> <chr size="8k"/> <wram size="8k" battery="1"/> <vram size="8k"/>
+//	        <pad h="0" v="1"/>
+//	      </board>
+//	    </cartridge>
+//	  </game>
+//	</database>
+//
+// The CRC32 and SHA-1 of the cartridge cover the PRG ROM followed by the CHR ROM, same as gamedb.txt.
+type nesCartDB struct {
+	Games []struct {
+		Name       string `xml:"name,attr"`
+		Cartridges []struct {
+			System string `xml:"system,attr"`
+			CRC    string `xml:"crc,attr"`
+			SHA1   string `xml:"sha1,attr"`
+			Boards []struct {
+				Mapper string `xml:"mapper,attr"`
+				WRAM   []struct {
+					Size    string `xml:"size,attr"`
+					Battery string `xml:"battery,attr"`
+				} `xml:"wram"`
+				VRAM []struct {
+					Size string `xml:"size,attr"`
+				} `xml:"vram"`
+				Pads []struct {
+					H string `xml:"h,attr"`
+					V string `xml:"v,attr"`
+				} `xml:"pad"`
+			} `xml:"board"`
+		} `xml:"cartridge"`
+	} `xml:"game"`
+}
+
+func parseNesCartDB(data []byte) (map[uint32][]*gameDBEntry, error) {
+	var x nesCartDB
+	if err := xml.Unmarshal(data, &x); err != nil {
+		return nil, fmt.Errorf("gamedb: %w", err)
+	}
+	db := make(map[uint32][]*gameDBEntry)
+	for _, g := range x.Games {
+		for _, c := range g.Cartridges {
+			crc, err := strconv.ParseUint(c.CRC, 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("gamedb: %s: %w", g.Name, err)
+			}
+			e := &gameDBEntry{
+				crc32:     uint32(crc),
+				sha1:      strings.ToLower(c.SHA1),
+				mapper:    -1,
+				submapper: -1,
+				mirroring: "-",
+				chrRAM:    -1,
+				region:    nesCartDBRegion(c.System),
+				title:     g.Name,
+			}
+			if len(c.Boards) > 0 {
+				b := c.Boards[0]
+				if b.Mapper != "" {
+					if e.mapper, err = strconv.Atoi(b.Mapper); err != nil {
+						return nil, fmt.Errorf("gamedb: %s: %w", g.Name, err)
+					}
+				}
+				for _, w := range b.WRAM {
+					size, err := parseNesCartDBSize(w.Size)
+					if err != nil {
+						return nil, fmt.Errorf("gamedb: %s: %w", g.Name, err)
+					}
+					e.prgRAM += size
+					if w.Battery == "1" {
+						e.battery = 1
+					}
+				}
+				for _, v := range b.VRAM {
+					size, err := parseNesCartDBSize(v.Size)
+					if err != nil {
+						return nil, fmt.Errorf("gamedb: %s: %w", g.Name, err)
+					}
+					if e.chrRAM < 0 {
+						e.chrRAM = 0
+					}
+					e.chrRAM += size
+				}
+				// The solder pads name the mirroring, the boards without them leave it to the mapper
+				for _, p := range b.Pads {
+					if p.H == "1" {
+						e.mirroring = "H"
+					} else if p.V == "1" {
+						e.mirroring = "V"
+					}
+				}
+			}
+			db[e.crc32] = append(db[e.crc32], e)
+		}
+	}
+	return db, nil
+}
+
+// parseNesCartDBSize parses the size like "8k"
+func parseNesCartDBSize(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(s), "k"))
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasSuffix(strings.ToLower(s), "k") {
+		n *= 1024
+	}
+	return n, nil
+}
+
+func nesCartDBRegion(system string) string {
+	switch {
+	case system == "NES-NTSC" || system == "Famicom":
+		return "NTSC"
+	case strings.HasPrefix(system, "NES-PAL"):
+		return "PAL"
+	case system == "Dendy":
+		return "Dendy"
+	default:
+		return "-"
+	}
+}
+
+// lookupGameDB returns nil if the game is not in the database
+func lookupGameDB(prg, chr []byte) *gameDBEntry {
+	gameDBOnce.Do(loadGameDB)
+	h := crc32.NewIEEE()
+	h.Write(prg)
+	h.Write(chr)
+	gameDBMu.RLock()
+	entries := gameDB[h.Sum32()]
+	gameDBMu.RUnlock()
+	if len(entries) == 0 {
+		return nil
+	}
+	sh := sha1.New()
+	sh.Write(prg)
+	sh.Write(chr)
+	sum := hex.EncodeToString(sh.Sum(nil))
+	for _, e := range entries {
+		if e.sha1 == "" || e.sha1 == sum {
+			return e
+		}
+	}
+	return nil
+}
+
+// applyGameDBEntry corrects the header fields by the database, and records what was changed
+func (c *Cassette) applyGameDBEntry(e *gameDBEntry) {
+	c.Title = e.title
+	correct := func(field string, from, to any) {
+		c.Corrections = append(c.Corrections, fmt.Sprintf("%s: %v -> %v", field, from, to))
+	}
+	if e.mapper >= 0 && uint16(e.mapper) != c.Mapper {
+		correct("mapper", c.Mapper, e.mapper)
+		c.Mapper = uint16(e.mapper)
+	}
+	if e.submapper >= 0 && byte(e.submapper) != c.Submapper {
+		correct("submapper", c.Submapper, e.submapper)
+		c.Submapper = byte(e.submapper)
+	}
+	var mirror MirroringType
+	switch e.mirroring {
+	case "H":
+		mirror = MirroringHorizontal
+	case "V":
+		mirror = MirroringVertical
+	case "4":
+		mirror = MirroringFourScreen
+	default:
+		// unknown or controlled by the mapper
+		mirror = c.Mirror
+	}
+	if mirror != c.Mirror {
+		correct("mirroring", c.Mirror, mirror)
+		c.Mirror = mirror
+	}
+	if e.battery >= 0 && (e.battery == 1) != c.Battery {
+		correct("battery", c.Battery, e.battery == 1)
+		c.Battery = e.battery == 1
+	}
+	if e.prgRAM >= 0 && e.prgRAM != c.PRGRAMSize {
+		correct("PRG RAM size", c.PRGRAMSize, e.prgRAM)
+		c.PRGRAMSize = e.prgRAM
+	}
+	if e.chrRAM > 0 && c.chrROMSize == 0 && e.chrRAM != len(c.CHR) {
+		correct("CHR RAM size", len(c.CHR), e.chrRAM)
+		c.CHR = make([]byte, e.chrRAM)
+	}
+	region := c.Region
+	switch e.region {
+	case "NTSC":
+		region = RegionNTSC
+	case "PAL":
+		region = RegionPAL
+	case "Multi":
+		region = RegionMulti
+	case "Dendy":
+		region = RegionDendy
+	}
+	if region != c.Region {
+		correct("region", c.Region, region)
+		c.Region = region
+	}
+}
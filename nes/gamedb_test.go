@@ -0,0 +1,220 @@
+package nes
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseGameDB(t *testing.T) {
+	t.Parallel()
+	db, err := parseGameDB(gameDBText)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, db)
+
+	db, err = parseGameDB([]byte("# comment\n\n0000ABCD - 1 - M 1 8192 - PAL Some  Game\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, &gameDBEntry{
+		crc32:     0xABCD,
+		mapper:    1,
+		submapper: -1,
+		mirroring: "M",
+		battery:   1,
+		prgRAM:    8192,
+		chrRAM:    -1,
+		region:    "PAL",
+		title:     "Some Game",
+	}, db[0xABCD][0])
+
+	_, err = parseGameDB([]byte("0000ABCD - 1\n"))
+	assert.Error(t, err)
+}
+
+func Test_Cassette_ApplyGameDBEntry(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		entry *gameDBEntry
+		want  *Cassette
+	}{
+		{"1", &gameDBEntry{mapper: -1, submapper: -1, mirroring: "-", battery: -1, prgRAM: -1, chrRAM: -1, region: "-", title: "a"}, &Cassette{
+			Mapper: 4,
+			Mirror: MirroringHorizontal,
+			CHR:    make([]byte, 0x2000),
+			Title:  "a",
+		}},
+		{"2", &gameDBEntry{mapper: 1, submapper: 5, mirroring: "V", battery: 1, prgRAM: 0x2000, chrRAM: 0x4000, region: "PAL", title: "b"}, &Cassette{
+			Mapper:     1,
+			Submapper:  5,
+			Mirror:     MirroringVertical,
+			Battery:    true,
+			PRGRAMSize: 0x2000,
+			Region:     RegionPAL,
+			CHR:        make([]byte, 0x4000),
+			Title:      "b",
+			Corrections: []string{
+				"mapper: 4 -> 1",
+				"submapper: 0 -> 5",
+				"mirroring: horizontal -> vertical",
+				"battery: false -> true",
+				"PRG RAM size: 0 -> 8192",
+				"CHR RAM size: 8192 -> 16384",
+				"region: NTSC -> PAL",
+			},
+		}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := &Cassette{
+				Mapper: 4,
+				Mirror: MirroringHorizontal,
+				CHR:    make([]byte, 0x2000),
+			}
+			c.applyGameDBEntry(tt.entry)
+			assert.Equal(t, tt.want, c)
+		})
+	}
+}
+
+func Test_NewCassette_GarbageHeader(t *testing.T) {
+	t.Parallel()
+	header := append([]byte("NES\x1a\x01\x01\x01"), "DiskDude!"...)
+	rom := append(header, make([]byte, 0x4000+0x2000)...)
+	c, err := NewCassette(bytes.NewReader(rom))
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0), c.Mapper)
+	assert.Equal(t, []string{"mapper: 64 -> 0 (garbage in the header)"}, c.Corrections)
+}
+
+func Test_ParseNesCartDB(t *testing.T) {
+	t.Parallel()
+	db, err := parseNesCartDB([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<database version="1.0">
+<game name="Some Game" region="USA">
+<cartridge system="NES-NTSC" crc="0000ABCD" sha1="ABCDEF">
+<board type="NES-SNROM" mapper="1">
+<prg size="256k"/>
+<wram size="8k" battery="1"/>
+<vram size="8k"/>
+</board>
+</cartridge>
+<cartridge system="NES-PAL-B" crc="0000DCBA">
+<board type="NES-NROM-128" mapper="0">
+<prg size="16k"/>
+<chr size="8k"/>
+<pad h="0" v="1"/>
+</board>
+</cartridge>
+</game>
+</database>`))
+	assert.NoError(t, err)
+	assert.Equal(t, &gameDBEntry{
+		crc32:     0xABCD,
+		sha1:      "abcdef",
+		mapper:    1,
+		submapper: -1,
+		mirroring: "-",
+		battery:   1,
+		prgRAM:    8192,
+		chrRAM:    8192,
+		region:    "NTSC",
+		title:     "Some Game",
+	}, db[0xABCD][0])
+	assert.Equal(t, &gameDBEntry{
+		crc32:     0xDCBA,
+		mapper:    0,
+		submapper: -1,
+		mirroring: "V",
+		chrRAM:    -1,
+		region:    "PAL",
+		title:     "Some Game",
+	}, db[0xDCBA][0])
+
+	_, err = parseNesCartDB([]byte(`<database><game><cartridge crc="XYZ"/></game></database>`))
+	assert.Error(t, err)
+}
+
+func Test_LoadGameDB(t *testing.T) {
+	t.Parallel()
+	prg := make([]byte, 0x4000)
+	copy(prg, "Test_LoadGameDB")
+	chr := make([]byte, 0x2000)
+	h := crc32.NewIEEE()
+	h.Write(prg)
+	h.Write(chr)
+
+	err := LoadGameDB(strings.NewReader(fmt.Sprintf("%08X - 3 - V 0 0 - NTSC Loaded Game\n", h.Sum32())))
+	assert.NoError(t, err)
+
+	rom := append([]byte("NES\x1a\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), prg...)
+	rom = append(rom, chr...)
+	c, err := NewCassette(bytes.NewReader(rom))
+	assert.NoError(t, err)
+	assert.Equal(t, "Loaded Game", c.Title)
+	assert.Equal(t, uint16(3), c.Mapper)
+	assert.Equal(t, MirroringVertical, c.Mirror)
+}
+
+func Test_WriteGameDB(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		db   string
+		want string
+	}{
+		{
+			"1",
+			`<database>
+<game name="Second Game">
+<cartridge system="NES-PAL-B" crc="0000DCBA">
+<board mapper="0"><pad h="0" v="1"/></board>
+</cartridge>
+</game>
+<game name="First Game">
+<cartridge system="NES-NTSC" crc="0000ABCD" sha1="ABCDEF">
+<board mapper="1"><wram size="8k" battery="1"/><vram size="8k"/></board>
+</cartridge>
+</game>
+</database>`,
+			"0000ABCD abcdef 1 - - 1 8192 8192 NTSC First Game\n" +
+				"0000DCBA - 0 - V 0 0 - PAL Second Game\n",
+		},
+		{
+			"2",
+			"0000ABCD - 1 - M 1 8192 - PAL Some Game\n",
+			"0000ABCD - 1 - M 1 8192 - PAL Some Game\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var out bytes.Buffer
+			assert.NoError(t, WriteGameDB(&out, strings.NewReader(tt.db)))
+			assert.Equal(t, gameDBHeader+tt.want, out.String())
+
+			// the generated text is read back as the same database
+			want, err := readGameDB(strings.NewReader(tt.db))
+			assert.NoError(t, err)
+			got, err := parseGameDB(out.Bytes())
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func Test_GameDBText_Generated(t *testing.T) {
+	t.Parallel()
+	// gamedb.txt is kept in the output format of WriteGameDB
+	var out bytes.Buffer
+	assert.NoError(t, WriteGameDB(&out, bytes.NewReader(gameDBText)))
+	assert.Equal(t, string(gameDBText), out.String())
+}
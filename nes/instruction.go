@@ -60,8 +60,11 @@ func (cpu *cpu) tya() {
 }
 
 func (cpu *cpu) adc(addr uint16) {
+	cpu.adcValue(cpu.read(addr))
+}
+
+func (cpu *cpu) adcValue(b byte) {
 	a := cpu.A
-	b := cpu.read(addr)
 	c := byte(0)
 	if cpu.P.isCarry() {
 		c = 1
@@ -85,12 +88,14 @@ func (cpu *cpu) aslAcc() {
 }
 
 func (cpu *cpu) asl(addr uint16) {
-	v := cpu.read(addr)
+	cpu.rmw(addr, cpu.aslValue)
+}
+
+func (cpu *cpu) aslValue(v byte) byte {
 	cpu.P.setCarry((v & 0x80) == 0x80)
-	cpu.write(addr, v) // dummy write
 	v <<= 1
-	cpu.write(addr, v)
 	cpu.P.setZN(v)
+	return v
 }
 
 func (cpu *cpu) bit(addr uint16) {
@@ -116,11 +121,13 @@ func (cpu *cpu) cpy(addr uint16) {
 }
 
 func (cpu *cpu) dec(addr uint16) {
-	v := cpu.read(addr)
-	cpu.write(addr, v) // dummy write
+	cpu.rmw(addr, cpu.decValue)
+}
+
+func (cpu *cpu) decValue(v byte) byte {
 	v--
-	cpu.write(addr, v)
 	cpu.P.setZN(v)
+	return v
 }
 
 func (cpu *cpu) dex() {
@@ -139,11 +146,13 @@ func (cpu *cpu) eor(addr uint16) {
 }
 
 func (cpu *cpu) inc(addr uint16) {
-	v := cpu.read(addr)
-	cpu.write(addr, v) // dummy write
+	cpu.rmw(addr, cpu.incValue)
+}
+
+func (cpu *cpu) incValue(v byte) byte {
 	v++
-	cpu.write(addr, v)
 	cpu.P.setZN(v)
+	return v
 }
 
 func (cpu *cpu) inx() {
@@ -163,12 +172,14 @@ func (cpu *cpu) lsrAcc() {
 }
 
 func (cpu *cpu) lsr(addr uint16) {
-	v := cpu.read(addr)
-	cpu.write(addr, v) // dummy write
+	cpu.rmw(addr, cpu.lsrValue)
+}
+
+func (cpu *cpu) lsrValue(v byte) byte {
 	cpu.P.setCarry((v & 1) == 1)
 	v >>= 1
-	cpu.write(addr, v)
 	cpu.P.setZN(v)
+	return v
 }
 
 func (cpu *cpu) ora(addr uint16) {
@@ -187,16 +198,18 @@ func (cpu *cpu) rolAcc() {
 }
 
 func (cpu *cpu) rol(addr uint16) {
+	cpu.rmw(addr, cpu.rolValue)
+}
+
+func (cpu *cpu) rolValue(v byte) byte {
 	c := byte(0)
 	if cpu.P.isCarry() {
 		c = 1
 	}
-	v := cpu.read(addr)
-	cpu.write(addr, v) // dummy write
 	cpu.P.setCarry((v & 0x80) == 0x80)
 	v = (v << 1) | c
-	cpu.write(addr, v)
 	cpu.P.setZN(v)
+	return v
 }
 
 func (cpu *cpu) rorAcc() {
@@ -210,21 +223,26 @@ func (cpu *cpu) rorAcc() {
 }
 
 func (cpu *cpu) ror(addr uint16) {
+	cpu.rmw(addr, cpu.rorValue)
+}
+
+func (cpu *cpu) rorValue(v byte) byte {
 	c := byte(0)
 	if cpu.P.isCarry() {
 		c = 1
 	}
-	v := cpu.read(addr)
-	cpu.write(addr, v) // dummy write
 	cpu.P.setCarry((v & 1) == 1)
 	v = (v >> 1) | (c << 7)
-	cpu.write(addr, v)
 	cpu.P.setZN(v)
+	return v
 }
 
 func (cpu *cpu) sbc(addr uint16) {
+	cpu.sbcValue(cpu.read(addr))
+}
+
+func (cpu *cpu) sbcValue(b byte) {
 	a := cpu.A
-	b := cpu.read(addr)
 	c := byte(0)
 	if cpu.P.isCarry() {
 		c = 1
@@ -534,6 +552,13 @@ func (cpu *cpu) irq() {
 	cpu.interrupting = false
 }
 
+// rmw is a read-modify-write instruction, that writes the original value back while modifying it
+func (cpu *cpu) rmw(addr uint16, modify func(v byte) byte) {
+	v := cpu.read(addr)
+	cpu.write(addr, v) // dummy write
+	cpu.write(addr, modify(v))
+}
+
 func (cpu *cpu) compare(a byte, b byte) {
 	cpu.P.setZN(a - b)
 	cpu.P.setCarry(a >= b)
@@ -562,12 +587,21 @@ func (cpu *cpu) pop16() uint16 {
 	return uint16(h)<<8 | uint16(l)
 }
 
+// https://www.nesdev.org/wiki/CPU_interrupts#Branch_instructions_and_interrupts
+// > The branch instructions have more subtle interrupt polling behavior.
+// > Interrupts are always polled before the second CPU cycle (the operand fetch).
+// > Additionally, for taken branches that cross a page boundary, interrupts are polled before the PCH fixup cycle
+// So the taken branch without the page crossing keeps the interrupt polled in the operand fetch,
+// and the interrupt detected in the last cycle is delayed until after the next instruction.
 func (cpu *cpu) branch(addr uint16) int {
 	cycle := 1
+	pending := cpu.pendingInterrupt
 	cpu.read(cpu.PC) // dummy read
 	if pagesCross(cpu.PC, addr) {
 		cpu.read(cpu.PC) // dummy read
 		cycle++
+	} else {
+		cpu.pendingInterrupt = pending
 	}
 	cpu.PC = addr
 	return cycle
@@ -579,17 +613,23 @@ func pagesCross(a uint16, b uint16) bool {
 
 // undocumented opcode
 
-func (cpu *cpu) kil() {}
+// kil jams the CPU
+func (cpu *cpu) kil(opcode byte) {
+	cpu.halt = &HaltError{
+		PC:     cpu.PC - 1,
+		Opcode: opcode,
+	}
+}
 
 func (cpu *cpu) slo(addr uint16) {
-	v := cpu.read(addr)
-	cpu.write(addr, v) // dummy write
-	cpu.P.setCarry((v & 0x80) == 0x80)
-	v <<= 1
-	cpu.write(addr, v)
+	cpu.rmw(addr, cpu.sloValue)
+}
 
+func (cpu *cpu) sloValue(v byte) byte {
+	v = cpu.aslValue(v)
 	cpu.A |= v
 	cpu.P.setZN(cpu.A)
+	return v
 }
 
 func (cpu *cpu) anc(addr uint16) {
@@ -600,29 +640,25 @@ func (cpu *cpu) anc(addr uint16) {
 }
 
 func (cpu *cpu) rla(addr uint16) {
-	c := byte(0)
-	if cpu.P.isCarry() {
-		c = 1
-	}
-	v := cpu.read(addr)
-	cpu.write(addr, v) // dummy write
-	cpu.P.setCarry((v & 0x80) == 0x80)
-	v = (v << 1) | c
-	cpu.write(addr, v)
+	cpu.rmw(addr, cpu.rlaValue)
+}
 
+func (cpu *cpu) rlaValue(v byte) byte {
+	v = cpu.rolValue(v)
 	cpu.A &= v
 	cpu.P.setZN(cpu.A)
+	return v
 }
 
 func (cpu *cpu) sre(addr uint16) {
-	v := cpu.read(addr)
-	cpu.write(addr, v) // dummy write
-	cpu.P.setCarry((v & 1) == 1)
-	v >>= 1
-	cpu.write(addr, v)
+	cpu.rmw(addr, cpu.sreValue)
+}
 
+func (cpu *cpu) sreValue(v byte) byte {
+	v = cpu.lsrValue(v)
 	cpu.A ^= v
 	cpu.P.setZN(cpu.A)
+	return v
 }
 
 func (cpu *cpu) alr(addr uint16) {
@@ -636,27 +672,13 @@ func (cpu *cpu) alr(addr uint16) {
 }
 
 func (cpu *cpu) rra(addr uint16) {
-	c := byte(0)
-	if cpu.P.isCarry() {
-		c = 1
-	}
-	k := cpu.read(addr)
-	cpu.write(addr, k) // dummy write
-	cpu.P.setCarry((k & 1) == 1)
-	k = (k >> 1) | (c << 7)
-	cpu.write(addr, k)
+	cpu.rmw(addr, cpu.rraValue)
+}
 
-	a := cpu.A
-	b := k
-	c = byte(0)
-	if cpu.P.isCarry() {
-		c = 1
-	}
-	v := a + b + c
-	cpu.A = v
-	cpu.P.setZN(v)
-	cpu.P.setCarry(uint16(a)+uint16(b)+uint16(c) > 0xFF)
-	cpu.P.setOverflow((a^b)&0x80 == 0 && (a^v)&0x80 != 0)
+func (cpu *cpu) rraValue(v byte) byte {
+	v = cpu.rorValue(v)
+	cpu.adcValue(v)
+	return v
 }
 
 func (cpu *cpu) arr(addr uint16) {
@@ -678,26 +700,41 @@ func (cpu *cpu) sax(addr uint16) {
 	cpu.write(addr, cpu.A&cpu.X)
 }
 
-func (cpu *cpu) xaa() {}
+func (cpu *cpu) xaa(addr uint16) {
+	cpu.A = (cpu.A | cpu.unstable.XAAMagic) & cpu.X & cpu.read(addr)
+	cpu.P.setZN(cpu.A)
+}
+
+// storeHigh is the store of AHX, TAS, SHY and SHX, that writes the value & (H+1), H is the high byte of the base address.
+// When the index crosses the page, the written value also replaces the high byte of the address.
+// When DMA halts the CPU on the cycle before the write, the & (H+1) drops off.
+// https://www.nesdev.org/wiki/CPU_unofficial_opcodes
+func (cpu *cpu) storeHigh(addr uint16, index byte, v byte) {
+	base := addr - uint16(index)
+	if !cpu.dmaHalted {
+		v &= byte(base>>8) + 1
+	}
+	if pagesCross(addr, base) {
+		addr = uint16(v)<<8 | addr&0x00FF
+	}
+	cpu.write(addr, v)
+}
 
-func (cpu *cpu) ahx() {}
+func (cpu *cpu) ahx(addr uint16) {
+	cpu.storeHigh(addr, cpu.Y, cpu.A&cpu.X)
+}
 
-func (cpu *cpu) tas() {}
+func (cpu *cpu) tas(addr uint16) {
+	cpu.S = cpu.A & cpu.X
+	cpu.storeHigh(addr, cpu.Y, cpu.S)
+}
 
 func (cpu *cpu) shy(addr uint16) {
-	if pagesCross(addr, addr-uint16(cpu.X)) {
-		addr &= uint16(cpu.Y) << 8
-	}
-	res := cpu.Y & (byte(addr>>8) + 1)
-	cpu.write(addr, res)
+	cpu.storeHigh(addr, cpu.X, cpu.Y)
 }
 
 func (cpu *cpu) shx(addr uint16) {
-	if pagesCross(addr, addr-uint16(cpu.Y)) {
-		addr &= uint16(cpu.X) << 8
-	}
-	res := cpu.X & (byte(addr>>8) + 1)
-	cpu.write(addr, res)
+	cpu.storeHigh(addr, cpu.Y, cpu.X)
 }
 
 func (cpu *cpu) lax(addr uint16) {
@@ -707,14 +744,30 @@ func (cpu *cpu) lax(addr uint16) {
 	cpu.P.setZN(v)
 }
 
-func (cpu *cpu) las() {}
+// laxImm is LAX #imm (also known as LXA, ATX), that is unstable as well as XAA
+func (cpu *cpu) laxImm(addr uint16) {
+	v := (cpu.A | cpu.unstable.LAXMagic) & cpu.read(addr)
+	cpu.X = v
+	cpu.A = v
+	cpu.P.setZN(v)
+}
+
+func (cpu *cpu) las(addr uint16) {
+	v := cpu.read(addr) & cpu.S
+	cpu.A = v
+	cpu.X = v
+	cpu.S = v
+	cpu.P.setZN(v)
+}
 
 func (cpu *cpu) dcp(addr uint16) {
-	v := cpu.read(addr)
-	cpu.write(addr, v) // dummy write
+	cpu.rmw(addr, cpu.dcpValue)
+}
+
+func (cpu *cpu) dcpValue(v byte) byte {
 	v--
 	cpu.compare(cpu.A, v)
-	cpu.write(addr, v)
+	return v
 }
 
 func (cpu *cpu) axs(addr uint16) {
@@ -728,20 +781,11 @@ func (cpu *cpu) axs(addr uint16) {
 }
 
 func (cpu *cpu) isb(addr uint16) {
-	k := cpu.read(addr)
-	cpu.write(addr, k) // dummy write
-	k++
-	cpu.write(addr, k)
+	cpu.rmw(addr, cpu.isbValue)
+}
 
-	a := cpu.A
-	b := k
-	c := byte(0)
-	if cpu.P.isCarry() {
-		c = 1
-	}
-	v := a - b - (1 - c)
-	cpu.A = v
-	cpu.P.setCarry(int(a)-int(b)-int(1-c) >= 0)
-	cpu.P.setOverflow(((a^b)&0x80 != 0) && (a^v)&0x80 != 0)
-	cpu.P.setZN(v)
+func (cpu *cpu) isbValue(v byte) byte {
+	v++
+	cpu.sbcValue(v)
+	return v
 }
@@ -8,6 +8,7 @@ type (
 const (
 	irqSourceFrameCounter irqSource = (1 << iota)
 	irqSourceDMC
+	irqSourceMapper
 )
 
 func (i *irqInterruptLine) setHigh(src irqSource) {
@@ -0,0 +1,95 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+)
+
+// IPS patch format
+// "PATCH", records of (3 bytes offset, 2 bytes size, data), "EOF", optional 3 bytes size to truncate
+// A record of size 0 is RLE: (3 bytes offset, 0, 2 bytes run length, 1 byte value)
+// https://zerosoft.zophar.net/ips.php
+var (
+	ipsHeader = []byte("PATCH")
+	ipsFooter = []byte("EOF")
+)
+
+// 1 byte less than the maximum for moving back the offset "EOF"
+const ipsMaxRecordSize = 0xFFFE
+
+// CreateIPS returns the IPS patch to make modified from orig.
+// The two must have the same size.
+func CreateIPS(orig, modified []byte) ([]byte, error) {
+	if len(orig) != len(modified) {
+		return nil, errors.New("ips: the sizes are different")
+	}
+	if len(orig) > 0xFFFFFF {
+		return nil, errors.New("ips: the data is too large")
+	}
+	patch := append([]byte{}, ipsHeader...)
+	for i := 0; i < len(orig); {
+		if orig[i] == modified[i] {
+			i++
+			continue
+		}
+		start := i
+		for i < len(orig) && orig[i] != modified[i] && i-start < ipsMaxRecordSize {
+			i++
+		}
+		// the offset "EOF" is mistaken for the footer
+		if start == 0x454F46 {
+			start--
+		}
+		size := i - start
+		patch = append(patch, byte(start>>16), byte(start>>8), byte(start), byte(size>>8), byte(size))
+		patch = append(patch, modified[start:i]...)
+	}
+	return append(patch, ipsFooter...), nil
+}
+
+// ApplyIPS returns the data patched by the IPS patch
+func ApplyIPS(data, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, ipsHeader) {
+		return nil, errors.New("ips: invalid header")
+	}
+	res := append([]byte{}, data...)
+	p := patch[len(ipsHeader):]
+	for {
+		if bytes.HasPrefix(p, ipsFooter) {
+			// the truncation extension
+			if p = p[len(ipsFooter):]; len(p) >= 3 {
+				size := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+				if size < len(res) {
+					res = res[:size]
+				}
+			}
+			return res, nil
+		}
+		if len(p) < 5 {
+			return nil, errors.New("ips: unexpected end of patch")
+		}
+		offset := int(p[0])<<16 | int(p[1])<<8 | int(p[2])
+		size := int(p[3])<<8 | int(p[4])
+		p = p[5:]
+		var record []byte
+		if size == 0 {
+			// RLE
+			if len(p) < 3 {
+				return nil, errors.New("ips: unexpected end of patch")
+			}
+			size = int(p[0])<<8 | int(p[1])
+			record = bytes.Repeat(p[2:3], size)
+			p = p[3:]
+		} else {
+			if len(p) < size {
+				return nil, errors.New("ips: unexpected end of patch")
+			}
+			record = p[:size]
+			p = p[size:]
+		}
+		if offset+size > len(res) {
+			res = append(res, make([]byte, offset+size-len(res))...)
+		}
+		copy(res[offset:], record)
+	}
+}
@@ -0,0 +1,36 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IPS_CreateAndApply(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		orig     []byte
+		modified []byte
+	}{
+		{"1", []byte{0, 1, 2, 3}, []byte{0, 1, 2, 3}},
+		{"2", []byte{0, 1, 2, 3}, []byte{9, 1, 9, 9}},
+		{"3", make([]byte, 0x454F50), func() []byte {
+			b := make([]byte, 0x454F50)
+			b[0x454F46] = 1
+			return b
+		}()},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			patch, err := CreateIPS(tt.orig, tt.modified)
+			assert.NoError(t, err)
+			got, err := ApplyIPS(tt.orig, patch)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.modified, got)
+		})
+	}
+}
@@ -10,6 +10,13 @@ type lengthCounter struct {
 	enabled bool
 	halt    bool
 	value   byte
+
+	// The writes of the halt flag and the reload take effect after the length counter is clocked in the next APU step.
+	// reloadValue is 0 if no reload is pending.
+	newHalt     bool
+	reloadValue byte
+	// clocked is set if the half frame clocked the counter in this APU step, it's not halted and not zero
+	clocked bool
 }
 
 func (lc *lengthCounter) setEnabled(v bool) {
@@ -18,18 +25,40 @@ func (lc *lengthCounter) setEnabled(v bool) {
 	} else {
 		lc.enabled = false
 		lc.value = 0
+		lc.reloadValue = 0
 	}
 }
 
+func (lc *lengthCounter) setHalt(v bool) {
+	lc.newHalt = v
+}
+
 func (lc *lengthCounter) load(v byte) {
 	// > If the enabled flag is set, the length counter is loaded with entry L of the length table
 	if lc.enabled {
-		lc.value = lengthTable[v]
+		lc.reloadValue = lengthTable[v]
+	}
+}
+
+// applyWrites is called after the frame counter in each APU step.
+// blargg_apu_2005.07.30/readme.txt (10.len_halt_timing, 11.len_reload_timing)
+// Changes to the halt flag occur after clocking the length counter.
+// The reload is ignored if it's written when the length counter is clocked and it's not zero.
+func (lc *lengthCounter) applyWrites() {
+	if lc.reloadValue != 0 {
+		if !lc.clocked {
+			lc.value = lc.reloadValue
+		}
+		lc.reloadValue = 0
 	}
+	lc.clocked = false
+	lc.halt = lc.newHalt
 }
 
+// tick is called by the half frame clock
 func (lc *lengthCounter) tick() {
 	if lc.value > 0 && !lc.halt {
 		lc.value--
+		lc.clocked = true
 	}
 }
@@ -0,0 +1,169 @@
+package nes
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileType is the format of a ROM file, detected by the magic bytes
+type FileType int
+
+const (
+	FileTypeUnknown FileType = iota
+	FileTypeINES             // .nes
+	FileTypeFDS              // .fds, with or without the fwNES header
+	FileTypeNSF              // .nsf
+	FileTypeNSFe             // .nsfe
+	FileTypeUNIF             // .unf
+)
+
+func (t FileType) String() string {
+	switch t {
+	case FileTypeINES:
+		return "iNES"
+	case FileTypeFDS:
+		return "FDS"
+	case FileTypeNSF:
+		return "NSF"
+	case FileTypeNSFe:
+		return "NSFe"
+	case FileTypeUNIF:
+		return "UNIF"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	iNESMagic = []byte("NES\x1a")
+	unifMagic = []byte("UNIF")
+	// a raw FDS image starts with the disk info block
+	fdsDiskInfoMagic = []byte("\x01*NINTENDO-HVC*")
+
+	gzipMagic = []byte{0x1F, 0x8B}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// DetectFileType returns the format of the data
+func DetectFileType(data []byte) FileType {
+	switch {
+	case bytes.HasPrefix(data, iNESMagic):
+		return FileTypeINES
+	case bytes.HasPrefix(data, fdsMagic), bytes.HasPrefix(data, fdsDiskInfoMagic):
+		return FileTypeFDS
+	case bytes.HasPrefix(data, nsfMagic):
+		return FileTypeNSF
+	case bytes.HasPrefix(data, nsfeMagic):
+		return FileTypeNSFe
+	case bytes.HasPrefix(data, unifMagic):
+		return FileTypeUNIF
+	default:
+		return FileTypeUnknown
+	}
+}
+
+// ROMFile is a ROM file read from the disk or an archive
+type ROMFile struct {
+	// Name is the file path, or the name in the archive
+	Name string
+	Type FileType
+	Data []byte
+}
+
+// MultipleROMsError is returned when an archive contains several ROMs and none is chosen
+type MultipleROMsError struct {
+	Names []string
+}
+
+func (e *MultipleROMsError) Error() string {
+	return fmt.Sprintf("the archive contains several roms, choose one of: %s", strings.Join(e.Names, ", "))
+}
+
+// OpenROMFile reads the ROM file, which can be compressed in .gz or .zip.
+// entry chooses the ROM by the name in the zip archive, it can be empty if the archive has only one ROM.
+func OpenROMFile(path, entry string) (*ROMFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		name := r.Name
+		if name == "" {
+			name = strings.TrimSuffix(path, filepath.Ext(path))
+		}
+		return newROMFile(name, b)
+	case bytes.HasPrefix(data, zipMagic):
+		return openZipROM(data, entry)
+	default:
+		return newROMFile(path, data)
+	}
+}
+
+func newROMFile(name string, data []byte) (*ROMFile, error) {
+	t := DetectFileType(data)
+	if t == FileTypeUnknown {
+		return nil, fmt.Errorf("%s: unknown file type", name)
+	}
+	return &ROMFile{
+		Name: name,
+		Type: t,
+		Data: data,
+	}, nil
+}
+
+func openZipROM(data []byte, entry string) (*ROMFile, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	var roms []*ROMFile
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || (entry != "" && f.Name != entry) {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		// the other files like a readme are skipped
+		if t := DetectFileType(b); t != FileTypeUnknown {
+			roms = append(roms, &ROMFile{Name: f.Name, Type: t, Data: b})
+		}
+	}
+	switch len(roms) {
+	case 0:
+		if entry != "" {
+			return nil, fmt.Errorf("%s is not found in the archive", entry)
+		}
+		return nil, fmt.Errorf("no rom is found in the archive")
+	case 1:
+		return roms[0], nil
+	default:
+		names := make([]string, len(roms))
+		for i, r := range roms {
+			names[i] = r.Name
+		}
+		return nil, &MultipleROMsError{Names: names}
+	}
+}
@@ -0,0 +1,100 @@
+package nes
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DetectFileType(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data []byte
+		want FileType
+	}{
+		{"1", []byte("NES\x1a\x02\x01"), FileTypeINES},
+		{"2", []byte("FDS\x1a\x01"), FileTypeFDS},
+		{"3", newTestFDSSide(), FileTypeFDS},
+		{"4", newTestNSF(1), FileTypeNSF},
+		{"5", newTestNSFe(), FileTypeNSFe},
+		{"6", []byte("UNIF\x07\x00\x00\x00"), FileTypeUNIF},
+		{"7", []byte("readme"), FileTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, DetectFileType(tt.data))
+		})
+	}
+}
+
+func Test_OpenROMFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	nesData := []byte("NES\x1a\x01\x01")
+	nsfData := newTestNSF(1)
+
+	writeZip := func(name string, files map[string][]byte) string {
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		for n, b := range files {
+			f, err := w.Create(n)
+			assert.NoError(t, err)
+			_, err = f.Write(b)
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, w.Close())
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+		return path
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err := w.Write(nesData)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	gzPath := filepath.Join(dir, "game.nes.gz")
+	assert.NoError(t, os.WriteFile(gzPath, gz.Bytes(), 0644))
+	rawPath := filepath.Join(dir, "music.bin")
+	assert.NoError(t, os.WriteFile(rawPath, nsfData, 0644))
+
+	single := writeZip("single.zip", map[string][]byte{"readme.txt": []byte("readme"), "game.nes": nesData})
+	multi := writeZip("multi.zip", map[string][]byte{"a.nes": nesData, "b.nsf": nsfData})
+
+	tests := []struct {
+		name    string
+		path    string
+		entry   string
+		want    *ROMFile
+		wantErr bool
+	}{
+		{"1", rawPath, "", &ROMFile{Name: rawPath, Type: FileTypeNSF, Data: nsfData}, false},
+		{"2", gzPath, "", &ROMFile{Name: filepath.Join(dir, "game.nes"), Type: FileTypeINES, Data: nesData}, false},
+		{"3", single, "", &ROMFile{Name: "game.nes", Type: FileTypeINES, Data: nesData}, false},
+		{"4", multi, "b.nsf", &ROMFile{Name: "b.nsf", Type: FileTypeNSF, Data: nsfData}, false},
+		{"5", multi, "", nil, true},
+		{"6", multi, "c.nes", nil, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := OpenROMFile(tt.path, tt.entry)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
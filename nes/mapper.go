@@ -13,22 +13,149 @@ type Mapper interface {
 	Reset()
 }
 
+// The following interfaces are optional for a mapper.
+// They are implemented by the boards that need more than Read/Write, e.g. an IRQ counter or an expansion sound chip.
+
+// irqMapper is implemented by a mapper that can assert the CPU /IRQ line
+type irqMapper interface {
+	connectIRQLine(irqLine *irqInterruptLine)
+}
+
+// cpuClockMapper is implemented by a mapper that is clocked by M2 (every CPU cycle)
+type cpuClockMapper interface {
+	tickCPU()
+}
+
+// audioMapper is implemented by a mapper that has an expansion sound chip.
+// The output is mixed into the APU output.
+type audioMapper interface {
+	audioOutput() float32
+}
+
+// nametableMapper is implemented by a mapper that controls the nametables ($2000-$2FFF) by itself instead of the mirroring,
+// e.g. each 1 KiB nametable can select CIRAM or CHR ROM.
+type nametableMapper interface {
+	// connectVRAM passes the PPU internal VRAM (CIRAM)
+	connectVRAM(vram *[2048]byte)
+	readNametable(addr uint16) byte
+	writeNametable(addr uint16, val byte)
+}
+
+// ppuFetchMapper is implemented by a mapper that snoops the PPU reads.
+// It's notified before each read on the video memory bus with what the PPU is fetching.
+type ppuFetchMapper interface {
+	notifyPPURead(addr uint16, kind ppuFetchKind)
+}
+
+// ppuRegisterMapper is implemented by a mapper that snoops the CPU writes to the PPU registers ($2000-$3FFF),
+// the cartridge can see all the writes on the CPU bus.
+type ppuRegisterMapper interface {
+	notifyPPURegisterWrite(addr uint16, val byte)
+}
+
+// regionMapper is implemented by a cartridge that knows the TV system of the game.
+type regionMapper interface {
+	region() Region
+}
+
+// openBusMapper is implemented by a mapper that leaves some of the cartridge space unmapped, e.g. no PRG RAM.
+// The CPU reads back the last value on the data bus from there.
+type openBusMapper interface {
+	connectOpenBus(openBus *byte)
+}
+
+// BatteryBackedMapper is implemented by a mapper that has persistent memory.
+// Frontends can save and restore it, e.g. as a .sav file.
+type BatteryBackedMapper interface {
+	Mapper
+	// SaveData returns nil if the cartridge doesn't have a battery
+	SaveData() []byte
+	LoadSaveData(data []byte)
+}
+
+// DiskSystemMapper is implemented by the Famicom Disk System
+type DiskSystemMapper interface {
+	Mapper
+	DiskSides() int
+	// DiskSide returns the inserted side, -1 if no disk is inserted
+	DiskSide() int
+	InsertDisk(side int) error
+	EjectDisk()
+	// DiskImage returns the disk image including the writes, in the same format as the loaded one
+	DiskImage() []byte
+}
+
+// hasBusConflicts decides whether the writes to PRG ROM conflict with the ROM output, by the NES 2.0 submapper of a discrete logic board.
+// Submapper 1 has no bus conflicts, 2 has the AND-type bus conflicts, and 0 keeps the board's default.
+// https://www.nesdev.org/wiki/NES_2.0_submappers#UxROM,_CNROM,_AxROM
+func hasBusConflicts(c *Cassette, def bool) bool {
+	switch c.Submapper {
+	case 1:
+		return false
+	case 2:
+		return true
+	default:
+		return def
+	}
+}
+
 func NewMapper(r io.Reader) (Mapper, error) {
 	c, err := NewCassette(r)
 	if err != nil {
 		return nil, err
 	}
-	return NewMapperFromCassette(c), nil
+	return NewMapperFromCassette(c)
+}
+
+// NewMapperFromCassette returns an error if the mapper isn't supported,
+// or the cartridge has the trainer, but the mapper can't map it at $7000
+func NewMapperFromCassette(c *Cassette) (Mapper, error) {
+	m, err := newMapper(c)
+	if err != nil {
+		return nil, err
+	}
+	if c.Trainer != nil && !c.trainerLoaded {
+		return nil, fmt.Errorf("%s doesn't support the trainer", m)
+	}
+	return m, nil
 }
 
-func NewMapperFromCassette(c *Cassette) Mapper {
+func newMapper(c *Cassette) (Mapper, error) {
 	switch c.Mapper {
 	case 0:
-		return newMapper0(c)
+		return newMapper0(c), nil
 	case 2:
-		return newMapper2(c)
+		return newMapper2(c), nil
 	case 3:
-		return newMapper3(c)
+		return newMapper3(c), nil
+	case 5:
+		return newMapper5(c), nil
+	case 7:
+		return newMapper7(c), nil
+	case 9:
+		return newMapper9(c), nil
+	case 10:
+		return newMapper10(c), nil
+	case 11:
+		return newMapper11(c), nil
+	case 13:
+		return newMapper13(c), nil
+	case 19:
+		return newMapper19(c), nil
+	case 30:
+		return newMapper30(c), nil
+	case 34:
+		return newMapper34(c), nil
+	case 58:
+		return newMapper58(c), nil
+	case 66:
+		return newMapper66(c), nil
+	case 69:
+		return newMapper69(c), nil
+	case 71:
+		return newMapper71(c), nil
+	case 85:
+		return newMapper85(c), nil
 	}
-	panic(fmt.Sprintf("Unsupported mapper: %0x", c.Mapper))
+	return nil, fmt.Errorf("unsupported mapper: %d", c.Mapper)
 }
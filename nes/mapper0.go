@@ -11,7 +11,8 @@ func newMapper0(c *Cassette) *mapper0 {
 	return &mapper0{
 		Cassette: c,
 		// > PRG RAM: 2 or 4 KiB, not bankswitched, only in Family Basic (but most emulators provide 8)
-		SRAM: make([]byte, 0x2000), // 8KiB
+		// The size follows the header, iNES without the size provides 8 KiB
+		SRAM: c.newPRGRAM(),
 	}
 }
 
@@ -26,9 +27,15 @@ func (m *mapper0) Reset() {
 func (m *mapper0) Read(addr uint16) byte {
 	switch {
 	case 0x0000 <= addr && addr < 0x2000:
-		return m.readCHR(addr)
+		return m.readCHR(int(addr))
+	case 0x4020 <= addr && addr < 0x6000:
+		return m.readOpenBus()
 	case 0x6000 <= addr && addr < 0x8000:
-		return m.SRAM[addr-0x6000]
+		if m.SRAM == nil {
+			return m.readOpenBus()
+		}
+		// 2 KiB and 4 KiB are mirrored
+		return m.SRAM[int(addr-0x6000)%len(m.SRAM)]
 	case 0x8000 <= addr && addr < 0xC000:
 		index := int(addr - 0x8000)
 		return m.PRG[index]
@@ -48,9 +55,13 @@ func (m *mapper0) Write(addr uint16, val byte) {
 	case 0x0000 <= addr && addr < 0x2000:
 		// https://www.nesdev.org/wiki/NROM
 		// > CHR capacity: 8 KiB ROM (DIP-28 standard pinout) but most emulators support RAM
-		m.writeCHR(addr, val)
+		m.writeCHR(int(addr), val)
+	case 0x4020 <= addr && addr < 0x6000:
+		// nothing
 	case 0x6000 <= addr && addr < 0x8000:
-		m.SRAM[addr-0x6000] = val
+		if m.SRAM != nil {
+			m.SRAM[int(addr-0x6000)%len(m.SRAM)] = val
+		}
 	case 0x8000 <= addr && addr <= 0xFFFF:
 		// nothing
 	default:
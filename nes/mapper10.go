@@ -0,0 +1,87 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/MMC4
+type mapper10 struct {
+	*Cassette
+	prgBank byte
+	chr     mmc2Latch
+	sram    []byte
+}
+
+func newMapper10(c *Cassette) *mapper10 {
+	return &mapper10{
+		Cassette: c,
+		chr:      newMMC2Latch(true),
+		sram:     c.newPRGRAM(),
+	}
+}
+
+func (m *mapper10) String() string {
+	return "Mapper 10"
+}
+
+func (m *mapper10) Reset() {
+	// nothing
+}
+
+func (m *mapper10) notifyPPURead(addr uint16, kind ppuFetchKind) {
+	m.chr.notifyPPURead(addr)
+}
+
+func (m *mapper10) SaveData() []byte {
+	if !m.Battery {
+		return nil
+	}
+	return m.sram
+}
+
+func (m *mapper10) LoadSaveData(data []byte) {
+	copy(m.sram, data)
+}
+
+func (m *mapper10) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		return m.readCHR(m.chr.chrIndex(addr, len(m.CHR)))
+	case 0x4020 <= addr && addr < 0x6000:
+		return m.readOpenBus()
+	case 0x6000 <= addr && addr < 0x8000:
+		if m.sram == nil {
+			return m.readOpenBus()
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case 0x8000 <= addr && addr < 0xC000:
+		// > CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+		index := (int(m.prgBank)*0x4000 + int(addr-0x8000)) % len(m.PRG)
+		return m.PRG[index]
+	case 0xC000 <= addr && addr <= 0xFFFF:
+		// > CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+		return m.PRG[len(m.PRG)-0x4000+int(addr-0xC000)]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper10) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.writeCHR(m.chr.chrIndex(addr, len(m.CHR)), val)
+	case 0x4020 <= addr && addr < 0x6000:
+		// nothing
+	case 0x6000 <= addr && addr < 0x8000:
+		if m.sram != nil {
+			m.sram[int(addr-0x6000)%len(m.sram)] = val
+		}
+	case 0x8000 <= addr && addr < 0xA000:
+		// nothing
+	case 0xA000 <= addr && addr < 0xB000:
+		// > PRG ROM bank select ($A000-$AFFF)
+		m.prgBank = val & 0x0F
+	case 0xB000 <= addr && addr <= 0xFFFF:
+		writeMMC2Register(m.Cassette, &m.chr, addr, val)
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
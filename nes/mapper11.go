@@ -0,0 +1,66 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/Color_Dreams
+type mapper11 struct {
+	*Cassette
+	prgBank byte
+	chrBank byte
+}
+
+func newMapper11(c *Cassette) *mapper11 {
+	return &mapper11{
+		Cassette: c,
+	}
+}
+
+func (m *mapper11) String() string {
+	return "Mapper 11"
+}
+
+func (m *mapper11) Reset() {
+	// nothing
+}
+
+func (m *mapper11) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		// > PPU $0000-$1FFF: 8 KB switchable CHR ROM bank
+		index := (int(m.chrBank)*0x2000 + int(addr)) % len(m.CHR)
+		return m.readCHR(index)
+	case 0x4020 <= addr && addr < 0x8000:
+		// Color Dreams doesn't have PRG RAM
+		return m.readOpenBus()
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		// > CPU $8000-$FFFF: 32 KB switchable PRG ROM bank
+		index := (int(m.prgBank)*0x8000 + int(addr-0x8000)) % len(m.PRG)
+		return m.PRG[index]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper11) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		index := (int(m.chrBank)*0x2000 + int(addr)) % len(m.CHR)
+		m.writeCHR(index, val)
+	case 0x4020 <= addr && addr < 0x8000:
+		// nothing
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		// > 7  bit  0
+		// > ---- ----
+		// > CCCC LLPP
+		// > |||| ||||
+		// > |||| ||++- Select 32 KB PRG ROM bank for CPU $8000-$FFFF
+		// > |||| ++--- Used for lockout defeat
+		// > ++++------ Select 8 KB CHR ROM bank for PPU $0000-$1FFF
+		// The board has bus conflicts
+		val &= m.Read(addr)
+		m.prgBank = val & 0x03
+		m.chrBank = val >> 4
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
@@ -0,0 +1,69 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/CPROM
+type mapper13 struct {
+	*Cassette
+	chrBank byte
+}
+
+func newMapper13(c *Cassette) *mapper13 {
+	// CPROM has 16 KiB CHR RAM
+	if c.chrROMSize == 0 && len(c.CHR) < 0x4000 {
+		c.CHR = make([]byte, 0x4000)
+	}
+	return &mapper13{
+		Cassette: c,
+	}
+}
+
+func (m *mapper13) String() string {
+	return "Mapper 13"
+}
+
+func (m *mapper13) Reset() {
+	// nothing
+}
+
+func (m *mapper13) chrIndex(addr uint16) int {
+	// PPU $0000-$0FFF is fixed to the first 4 KB page, $1000-$1FFF is switchable
+	if addr < 0x1000 {
+		return int(addr)
+	}
+	return (int(m.chrBank)*0x1000 + int(addr-0x1000)) % len(m.CHR)
+}
+
+func (m *mapper13) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		return m.readCHR(m.chrIndex(addr))
+	case 0x4020 <= addr && addr < 0x8000:
+		// CPROM doesn't have PRG RAM
+		return m.readOpenBus()
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		return m.PRG[int(addr-0x8000)%len(m.PRG)]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper13) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.writeCHR(m.chrIndex(addr), val)
+	case 0x4020 <= addr && addr < 0x8000:
+		// nothing
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		// > 7  bit  0
+		// > ---- ----
+		// > xxxx xxCC
+		// >        ||
+		// >        ++- Select 4 KB CHR RAM bank for PPU $1000-$1FFF
+		// The board has bus conflicts
+		val &= m.Read(addr)
+		m.chrBank = val & 0x03
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
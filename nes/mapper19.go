@@ -0,0 +1,222 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/INES_Mapper_019
+// Namco 163
+type mapper19 struct {
+	*Cassette
+	prgBanks [3]byte
+	chrBanks [8]byte
+	ntBanks  [4]byte
+	// > $E800 bit 6/7: 1 = use CHR ROM for the bank values $E0-$FF at PPU $0000-$0FFF/$1000-$1FFF
+	chrRAMDisabled [2]byte
+	sram           []byte
+	sramProtect    byte
+	irqCounter     uint16 // 15 bits
+	irqEnabled     bool
+	audio          *n163Audio
+
+	vram    *[2048]byte
+	irqLine *irqInterruptLine
+}
+
+func newMapper19(c *Cassette) *mapper19 {
+	return &mapper19{
+		Cassette: c,
+		sram:     make([]byte, 0x2000),
+		audio:    newN163Audio(),
+	}
+}
+
+func (m *mapper19) String() string {
+	return "Mapper 19"
+}
+
+func (m *mapper19) Reset() {
+	// nothing
+}
+
+func (m *mapper19) connectIRQLine(irqLine *irqInterruptLine) {
+	m.irqLine = irqLine
+}
+
+func (m *mapper19) connectVRAM(vram *[2048]byte) {
+	m.vram = vram
+}
+
+func (m *mapper19) tickCPU() {
+	// > The IRQ counter is 15 bits, it increments every CPU cycle while enabled and
+	// > an IRQ is triggered when it reaches $7FFF. The counter stops counting there.
+	if m.irqEnabled && m.irqCounter < 0x7FFF {
+		m.irqCounter++
+		if m.irqCounter == 0x7FFF {
+			m.irqLine.setLow(irqSourceMapper)
+		}
+	}
+	m.audio.tick()
+}
+
+func (m *mapper19) audioOutput() float32 {
+	return m.audio.sample() * 0.3
+}
+
+// > The N163 has 128 bytes of internal RAM, which can be battery-backed in the same way as the PRG RAM.
+func (m *mapper19) SaveData() []byte {
+	if !m.Battery {
+		return nil
+	}
+	data := make([]byte, 0, len(m.sram)+len(m.audio.ram))
+	data = append(data, m.sram...)
+	data = append(data, m.audio.ram[:]...)
+	return data
+}
+
+func (m *mapper19) LoadSaveData(data []byte) {
+	n := copy(m.sram, data)
+	copy(m.audio.ram[:], data[n:])
+}
+
+// chrIndex returns the index of CHR ROM, or the index of CIRAM with true
+func (m *mapper19) chrIndex(addr uint16) (int, bool) {
+	bank := m.chrBanks[addr/0x400]
+	if bank >= 0xE0 && m.chrRAMDisabled[addr/0x1000] == 0 {
+		return int(bank&0x01)*0x400 + int(addr%0x400), true
+	}
+	return (int(bank)*0x400 + int(addr%0x400)) % len(m.CHR), false
+}
+
+// ntIndex returns the index of CHR ROM, or the index of CIRAM with true
+func (m *mapper19) ntIndex(addr uint16) (int, bool) {
+	// > $C000-$DFFF: Nametable selection, values $E0-$FF select CIRAM (bit 0 selects the page), the others select CHR ROM
+	bank := m.ntBanks[(addr-0x2000)/0x400]
+	if bank >= 0xE0 {
+		return int(bank&0x01)*0x400 + int(addr%0x400), true
+	}
+	return (int(bank)*0x400 + int(addr%0x400)) % len(m.CHR), false
+}
+
+func (m *mapper19) readNametable(addr uint16) byte {
+	index, ciram := m.ntIndex(addr)
+	if ciram {
+		return m.vram[index]
+	}
+	return m.readCHR(index)
+}
+
+func (m *mapper19) writeNametable(addr uint16, val byte) {
+	index, ciram := m.ntIndex(addr)
+	if ciram {
+		m.vram[index] = val
+	} else {
+		m.writeCHR(index, val)
+	}
+}
+
+func (m *mapper19) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		index, ciram := m.chrIndex(addr)
+		if ciram {
+			return m.vram[index]
+		}
+		return m.readCHR(index)
+	case 0x4020 <= addr && addr < 0x4800:
+		return m.readOpenBus()
+	case 0x4800 <= addr && addr < 0x5000:
+		// > Data Port ($4800-$4FFF) r/w
+		return m.audio.readData()
+	case 0x5000 <= addr && addr < 0x5800:
+		// > IRQ Counter (low) ($5000-$57FF) r/w
+		return byte(m.irqCounter)
+	case 0x5800 <= addr && addr < 0x6000:
+		// > IRQ Counter (high) / IRQ Enable ($5800-$5FFF) r/w
+		v := byte(m.irqCounter>>8) & 0x7F
+		if m.irqEnabled {
+			v |= 0x80
+		}
+		return v
+	case 0x6000 <= addr && addr < 0x8000:
+		return m.sram[addr-0x6000]
+	case 0x8000 <= addr && addr < 0xE000:
+		bank := int(m.prgBanks[(addr-0x8000)/0x2000]) % (len(m.PRG) / 0x2000)
+		return m.PRG[bank*0x2000+int(addr%0x2000)]
+	case 0xE000 <= addr && addr <= 0xFFFF:
+		// > CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+		return m.PRG[len(m.PRG)-0x2000+int(addr-0xE000)]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper19) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		index, ciram := m.chrIndex(addr)
+		if ciram {
+			m.vram[index] = val
+		} else {
+			m.writeCHR(index, val)
+		}
+	case 0x4020 <= addr && addr < 0x4800:
+		// nothing
+	case 0x4800 <= addr && addr < 0x5000:
+		m.audio.writeData(val)
+	case 0x5000 <= addr && addr < 0x5800:
+		// > Writing to either IRQ counter register acknowledges a pending IRQ
+		m.irqCounter = (m.irqCounter & 0x7F00) | uint16(val)
+		m.irqLine.setHigh(irqSourceMapper)
+	case 0x5800 <= addr && addr < 0x6000:
+		m.irqCounter = (m.irqCounter & 0x00FF) | (uint16(val&0x7F) << 8)
+		m.irqEnabled = (val & 0x80) == 0x80
+		m.irqLine.setHigh(irqSourceMapper)
+	case 0x6000 <= addr && addr < 0x8000:
+		// > Write Protect for External RAM ($F800-$FFFF)
+		// > 7  bit  0
+		// > ---- ----
+		// > KKKK DCBA
+		// > |||| ||||
+		// > |||| |||+- 1: Write-protect 2kB window of external RAM from $6000-$67FF (0: write enable)
+		// > |||| ||+-- 1: Write-protect 2kB window of external RAM from $6800-$6FFF (0: write enable)
+		// > |||| |+--- 1: Write-protect 2kB window of external RAM from $7000-$77FF (0: write enable)
+		// > |||| +---- 1: Write-protect 2kB window of external RAM from $7800-$7FFF (0: write enable)
+		// > ++++------ Additionally the value of bits 4-7 must equal %0100 for writes to be enabled
+		window := (addr - 0x6000) / 0x800
+		if (m.sramProtect&0xF0) == 0x40 && (m.sramProtect>>window)&0x01 == 0 {
+			m.sram[addr-0x6000] = val
+		}
+	case 0x8000 <= addr && addr < 0xC000:
+		// > CHR and NT Select ($8000-$DFFF)
+		m.chrBanks[(addr-0x8000)/0x800] = val
+	case 0xC000 <= addr && addr < 0xE000:
+		m.ntBanks[(addr-0xC000)/0x800] = val
+	case 0xE000 <= addr && addr < 0xE800:
+		// > 7  bit  0
+		// > ---- ----
+		// > .SPP PPPP
+		// >  ||| ||||
+		// >  |++-++++- Select 8KB page of PRG-ROM at $8000
+		// >  +-------- Disable sound if set
+		m.prgBanks[0] = val & 0x3F
+		m.audio.disabled = (val & 0x40) == 0x40
+	case 0xE800 <= addr && addr < 0xF000:
+		// > 7  bit  0
+		// > ---- ----
+		// > HLPP PPPP
+		// > |||| ||||
+		// > ||++-++++- Select 8KB page of PRG-ROM at $A000
+		// > |+-------- Disable CHR-RAM at $0000-$0FFF
+		// > +--------- Disable CHR-RAM at $1000-$1FFF
+		m.prgBanks[1] = val & 0x3F
+		m.chrRAMDisabled[0] = (val >> 6) & 0x01
+		m.chrRAMDisabled[1] = (val >> 7) & 0x01
+	case 0xF000 <= addr && addr < 0xF800:
+		m.prgBanks[2] = val & 0x3F
+	case 0xF800 <= addr && addr <= 0xFFFF:
+		// > Address Port ($F800-$FFFF), this register is also the write protect for the PRG RAM
+		m.audio.writeAddress(val)
+		m.sramProtect = val
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
@@ -4,13 +4,16 @@ import "fmt"
 
 type mapper2 struct {
 	*Cassette
-	prgBank byte
+	busConflicts bool
+	prgBank      byte
 }
 
 func newMapper2(c *Cassette) *mapper2 {
 	return &mapper2{
 		Cassette: c,
-		prgBank:  0,
+		// UNROM has bus conflicts, but the games avoid them, so they are emulated only if the submapper says so
+		busConflicts: hasBusConflicts(c, false),
+		prgBank:      0,
 	}
 }
 
@@ -25,10 +28,10 @@ func (m *mapper2) Reset() {
 func (m *mapper2) Read(addr uint16) byte {
 	switch {
 	case 0x0000 <= addr && addr < 0x2000:
-		return m.readCHR(addr)
-	case 0x6000 <= addr && addr < 0x8000:
+		return m.readCHR(int(addr))
+	case 0x4020 <= addr && addr < 0x8000:
 		// mapper2 dont'h have PRG RAM
-		return 0
+		return m.readOpenBus()
 	case 0x8000 <= addr && addr < 0xC000:
 		// > CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
 		index := 0x4000*int(m.prgBank) + int(addr-0x8000)
@@ -46,8 +49,8 @@ func (m *mapper2) Write(addr uint16, val byte) {
 	switch {
 	case 0x0000 <= addr && addr < 0x2000:
 		// mapper2 shouldn't have CHA RAM, but e.g.PCM.demo.wgraphics.nes needs CHR RAM, so I'll prepare it.
-		m.writeCHR(addr, val)
-	case 0x6000 <= addr && addr < 0x8000:
+		m.writeCHR(int(addr), val)
+	case 0x4020 <= addr && addr < 0x8000:
 		// mapper2 don't have PRG RAM
 	case 0x8000 <= addr && addr <= 0xFFFF:
 		// 7  bit  0
@@ -56,6 +59,9 @@ func (m *mapper2) Write(addr uint16, val byte) {
 		//      ||||
 		//      ++++- Select 16 KB PRG ROM bank for CPU $8000-$BFFF
 		//            (UNROM uses bits 2-0; UOROM uses bits 3-0)
+		if m.busConflicts {
+			val &= m.Read(addr)
+		}
 		m.prgBank = val & 0x0F
 	default:
 		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
@@ -0,0 +1,401 @@
+package nes
+
+import (
+	"errors"
+	"fmt"
+)
+
+// https://www.nesdev.org/wiki/Family_Computer_Disk_System
+// iNES mapper 20 is reserved for the Famicom Disk System, but the disk images are loaded by NewDiskSystem.
+// The RAM adapter has 32 KiB of PRG RAM ($6000-$DFFF), the 8 KiB BIOS ROM ($E000-$FFFF) and 8 KiB of CHR RAM.
+const (
+	// The drive transfers a byte about every 150 CPU cycles (96.4 kbit/s)
+	fdsByteCycles = 150
+	// The time for the head to move back to the start of the disk
+	fdsRewindCycles = 50000
+	// The disk is ejected for this time when switching the side, so that the BIOS notices the change
+	fdsEjectCycles = CPUClockFrequency
+)
+
+type mapper20 struct {
+	*Cassette
+	ram  []byte
+	disk *fdsDisk
+
+	// $4023
+	diskRegEnabled  bool
+	soundRegEnabled bool
+
+	// timer IRQ, $4020-$4022
+	timerReload  uint16
+	timerCounter uint16
+	timerRepeat  bool
+	timerEnabled bool
+	timerIRQ     bool
+
+	// $4025
+	motorOn        bool
+	resetTransfer  bool
+	readMode       bool
+	crcControl     bool
+	transferEnable bool
+	diskIRQEnabled bool
+
+	// the drive
+	side             int // -1 if the disk is ejected
+	nextSide         int
+	ejectCounter     int
+	position         int
+	delay            int
+	endOfHead        bool
+	scanning         bool
+	gapEnded         bool
+	readData         byte
+	writeData        byte
+	transferComplete bool
+
+	audio   *fdsAudio
+	irqLine *irqInterruptLine
+}
+
+// NewDiskSystem creates the Famicom Disk System with the disk image (.fds) and the BIOS ROM (8 KiB).
+// The first side of the disk is inserted.
+func NewDiskSystem(image []byte, bios []byte) (Mapper, error) {
+	if len(bios) != 0x2000 {
+		return nil, errors.New("invalid fds bios: the size must be 8 KiB")
+	}
+	disk, err := newFDSDisk(image)
+	if err != nil {
+		return nil, err
+	}
+	return &mapper20{
+		Cassette: &Cassette{
+			PRG:    bios,
+			CHR:    make([]byte, 0x2000),
+			Mapper: 20,
+			Mirror: MirroringHorizontal,
+		},
+		ram:       make([]byte, 0x8000),
+		disk:      disk,
+		side:      0,
+		endOfHead: true,
+		audio:     newFDSAudio(),
+	}, nil
+}
+
+func (m *mapper20) String() string {
+	return "Mapper 20"
+}
+
+func (m *mapper20) Reset() {
+	// nothing
+}
+
+func (m *mapper20) connectIRQLine(irqLine *irqInterruptLine) {
+	m.irqLine = irqLine
+}
+
+func (m *mapper20) audioOutput() float32 {
+	return m.audio.sample() * 0.5
+}
+
+func (m *mapper20) DiskSides() int {
+	return len(m.disk.sides)
+}
+
+func (m *mapper20) DiskSide() int {
+	if m.ejectCounter > 0 {
+		return -1
+	}
+	return m.side
+}
+
+func (m *mapper20) InsertDisk(side int) error {
+	if side < 0 || len(m.disk.sides) <= side {
+		return fmt.Errorf("invalid disk side: %d", side)
+	}
+	m.side = -1
+	m.nextSide = side
+	m.ejectCounter = fdsEjectCycles
+	return nil
+}
+
+func (m *mapper20) EjectDisk() {
+	m.side = -1
+	m.ejectCounter = 0
+}
+
+func (m *mapper20) DiskImage() []byte {
+	return m.disk.image()
+}
+
+func (m *mapper20) updateIRQ() {
+	if m.timerIRQ || (m.transferComplete && m.diskIRQEnabled) {
+		m.irqLine.setLow(irqSourceMapper)
+	} else {
+		m.irqLine.setHigh(irqSourceMapper)
+	}
+}
+
+func (m *mapper20) tickCPU() {
+	if m.ejectCounter > 0 {
+		m.ejectCounter--
+		if m.ejectCounter == 0 {
+			m.side = m.nextSide
+		}
+	}
+	m.tickTimer()
+	m.tickDrive()
+	if m.soundRegEnabled {
+		m.audio.tick()
+	}
+}
+
+func (m *mapper20) tickTimer() {
+	if !m.timerEnabled || !m.diskRegEnabled {
+		return
+	}
+	// The timer IRQ occurs when the counter reaches 0, and the counter is reloaded.
+	if m.timerCounter == 0 {
+		m.timerIRQ = true
+		m.timerCounter = m.timerReload
+		if !m.timerRepeat {
+			m.timerEnabled = false
+		}
+		m.updateIRQ()
+	} else {
+		m.timerCounter--
+	}
+}
+
+func (m *mapper20) tickDrive() {
+	if m.side < 0 || !m.motorOn {
+		// the head goes back to the start
+		m.endOfHead = true
+		m.scanning = false
+		return
+	}
+	if m.resetTransfer && !m.scanning {
+		return
+	}
+	if m.endOfHead {
+		m.delay = fdsRewindCycles
+		m.endOfHead = false
+		m.position = 0
+		m.gapEnded = false
+		return
+	}
+	if m.delay > 0 {
+		m.delay--
+		return
+	}
+
+	m.scanning = true
+	raw := m.disk.sides[m.side]
+	if m.readMode {
+		data := raw[m.position]
+		irq := true
+		if !m.transferEnable {
+			m.gapEnded = false
+		} else if data != 0 && !m.gapEnded {
+			// the end of the gap is not transferred to the CPU
+			m.gapEnded = true
+			irq = false
+		}
+		if m.gapEnded {
+			m.readData = data
+			if irq {
+				m.transferComplete = true
+			}
+		}
+	} else {
+		if !m.crcControl {
+			m.transferComplete = true
+		}
+		data := byte(0)
+		if m.transferEnable {
+			data = m.writeData
+		}
+		raw[m.position] = data
+		m.gapEnded = false
+	}
+	m.updateIRQ()
+
+	m.position++
+	if m.position >= len(raw) {
+		m.motorOn = false
+		m.endOfHead = true
+	} else {
+		m.delay = fdsByteCycles
+	}
+}
+
+func (m *mapper20) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		return m.readCHR(int(addr))
+	case addr == 0x4030:
+		if !m.diskRegEnabled {
+			return m.readOpenBus()
+		}
+		// 7  bit  0
+		// IExB xxTD
+		// ||| |  ||
+		// ||| |  |+- Timer IRQ occurred
+		// ||| |  +-- Byte transfer flag
+		// ||| +----- CRC error
+		// ||+------- End of head
+		// |+-------- Disk data read/write enable (1 when the disk is readable/writable)
+		// The x bits aren't driven
+		v := m.readOpenBus() & 0x2C
+		if m.timerIRQ {
+			v |= 0x01
+		}
+		if m.transferComplete {
+			v |= 0x02
+		}
+		if m.endOfHead {
+			v |= 0x40
+		}
+		// Reading this register acknowledges the timer IRQ and the disk IRQ
+		m.timerIRQ = false
+		m.transferComplete = false
+		m.updateIRQ()
+		return v
+	case addr == 0x4031:
+		if !m.diskRegEnabled {
+			return m.readOpenBus()
+		}
+		m.transferComplete = false
+		m.updateIRQ()
+		return m.readData
+	case addr == 0x4032:
+		if !m.diskRegEnabled {
+			return m.readOpenBus()
+		}
+		// 7  bit  0
+		// xxxx xPRS
+		//       |||
+		//       ||+- Disk flag (0: disk inserted, 1: no disk)
+		//       |+-- Ready flag (0: ready, 1: not ready)
+		//       +--- Protect flag (0: writable, 1: read-only or no disk)
+		// The upper bits aren't driven, they are usually $40 of the address
+		v := m.readOpenBus() & 0xF8
+		if m.side < 0 {
+			v |= 0x07
+		} else if !m.scanning {
+			v |= 0x02
+		}
+		return v
+	case addr == 0x4033:
+		// External connector, bit 7 is the battery status (1: good)
+		return 0x80
+	case 0x4040 <= addr && addr <= 0x4097:
+		if !m.soundRegEnabled {
+			return m.readOpenBus()
+		}
+		return m.audio.read(addr, m.readOpenBus())
+	case 0x4020 <= addr && addr < 0x6000:
+		return m.readOpenBus()
+	case 0x6000 <= addr && addr < 0xE000:
+		return m.ram[addr-0x6000]
+	case 0xE000 <= addr && addr <= 0xFFFF:
+		return m.PRG[addr-0xE000]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper20) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.writeCHR(int(addr), val)
+	case 0x4020 <= addr && addr <= 0x4026:
+		if m.diskRegEnabled || addr == 0x4023 {
+			m.writeRegister(addr, val)
+		}
+	case 0x4040 <= addr && addr <= 0x4097:
+		if m.soundRegEnabled {
+			m.audio.write(addr, val)
+		}
+	case 0x4020 <= addr && addr < 0x6000:
+		// nothing
+	case 0x6000 <= addr && addr < 0xE000:
+		m.ram[addr-0x6000] = val
+	case 0xE000 <= addr && addr <= 0xFFFF:
+		// BIOS ROM
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
+
+func (m *mapper20) writeRegister(addr uint16, val byte) {
+	switch addr {
+	case 0x4020:
+		// IRQ reload value low
+		m.timerReload = (m.timerReload & 0xFF00) | uint16(val)
+	case 0x4021:
+		// IRQ reload value high
+		m.timerReload = (m.timerReload & 0x00FF) | (uint16(val) << 8)
+	case 0x4022:
+		// 7  bit  0
+		// xxxx xxER
+		//        ||
+		//        |+- IRQ repeat flag
+		//        +-- IRQ enabled
+		m.timerRepeat = (val & 0x01) == 0x01
+		m.timerEnabled = (val & 0x02) == 0x02
+		if m.timerEnabled {
+			m.timerCounter = m.timerReload
+		} else {
+			m.timerIRQ = false
+		}
+		m.updateIRQ()
+	case 0x4023:
+		// 7  bit  0
+		// xxxx xxSD
+		//        ||
+		//        |+- Enable disk I/O registers
+		//        +-- Enable sound I/O registers
+		m.diskRegEnabled = (val & 0x01) == 0x01
+		m.soundRegEnabled = (val & 0x02) == 0x02
+		if !m.diskRegEnabled {
+			m.timerEnabled = false
+			m.timerIRQ = false
+			m.transferComplete = false
+			m.updateIRQ()
+		}
+	case 0x4024:
+		// Write data register
+		m.writeData = val
+		m.transferComplete = false
+		m.updateIRQ()
+	case 0x4025:
+		// 7  bit  0
+		// IS1B MRTD
+		// |||| ||||
+		// |||| |||+- Drive motor control (1: on)
+		// |||| ||+-- Transfer reset (1: reset the transfer timing)
+		// |||| |+--- Transfer mode (0: write, 1: read)
+		// |||| +---- Mirroring (0: vertical, 1: horizontal)
+		// |||+------ CRC control (1: transfer the CRC)
+		// ||+------- always 1
+		// |+-------- Transfer enable (the gap end is searched in read mode)
+		// +--------- Disk IRQ enabled
+		m.motorOn = (val & 0x01) == 0x01
+		m.resetTransfer = (val & 0x02) == 0x02
+		m.readMode = (val & 0x04) == 0x04
+		if (val & 0x08) == 0x08 {
+			m.Mirror = MirroringHorizontal
+		} else {
+			m.Mirror = MirroringVertical
+		}
+		m.crcControl = (val & 0x10) == 0x10
+		m.transferEnable = (val & 0x40) == 0x40
+		m.diskIRQEnabled = (val & 0x80) == 0x80
+		m.transferComplete = false
+		m.updateIRQ()
+	case 0x4026:
+		// External connector output, nothing
+	}
+}
@@ -4,15 +4,17 @@ import "fmt"
 
 type mapper3 struct {
 	*Cassette
-	chrBank byte
-	sram    []byte // for test
+	busConflicts bool
+	chrBank      byte
+	sram         []byte // for test, nil if the header has no PRG RAM
 }
 
 func newMapper3(c *Cassette) *mapper3 {
 	return &mapper3{
-		Cassette: c,
-		chrBank:  0,
-		sram:     make([]byte, 0x2000), // for test
+		Cassette:     c,
+		busConflicts: hasBusConflicts(c, false),
+		chrBank:      0,
+		sram:         c.newPRGRAM(), // for test
 	}
 }
 
@@ -28,12 +30,17 @@ func (m *mapper3) Read(addr uint16) byte {
 	switch {
 	case 0x0000 <= addr && addr < 0x2000:
 		// > PPU $0000-$1FFF: 8 KB switchable CHR ROM bank
-		index := uint16(m.chrBank)*0x2000 + uint16(addr)
+		index := (int(m.chrBank)*0x2000 + int(addr)) % len(m.CHR)
 		return m.readCHR(index)
+	case 0x4020 <= addr && addr < 0x6000:
+		return m.readOpenBus()
 	case 0x6000 <= addr && addr < 0x8000:
 		// mapper 3 don't have PRG RAM
 		// but, prepare RAM for automatic testing of ppu_read_buffer
-		return m.sram[addr-0x6000]
+		if m.sram == nil {
+			return m.readOpenBus()
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
 	case 0x8000 <= addr && addr <= 0xFFFF:
 		// > PRG ROM size: 16 KiB or 32 KiB
 		// > PRG ROM bank size: Not bankswitched
@@ -47,11 +54,15 @@ func (m *mapper3) Read(addr uint16) byte {
 func (m *mapper3) Write(addr uint16, val byte) {
 	switch {
 	case 0x0000 <= addr && addr < 0x2000:
-		m.writeCHR(addr, val)
+		m.writeCHR(int(addr), val)
+	case 0x4020 <= addr && addr < 0x6000:
+		// nothing
 	case 0x6000 <= addr && addr < 0x8000:
 		// mapper 3 don't have PRG RAM
 		// but, prepare RAM for automatic testing of ppu_read_buffer
-		m.sram[addr-0x6000] = val
+		if m.sram != nil {
+			m.sram[int(addr-0x6000)%len(m.sram)] = val
+		}
 	case 0x8000 <= addr && addr <= 0xFFFF:
 		// https://www.nesdev.org/wiki/INES_Mapper_003#Bank_select_($8000-$FFFF)
 		// 7  bit  0
@@ -60,6 +71,9 @@ func (m *mapper3) Write(addr uint16, val byte) {
 		// |||| ||||
 		// ++++-++++- Select 8 KB CHR ROM bank for PPU $0000-$1FFF
 		// > CNROM only implements the lowest 2 bits, capping it at 32 KiB CHR. Other boards may implement 4 or more bits for larger CHR.
+		if m.busConflicts {
+			val &= m.Read(addr)
+		}
 		m.chrBank = val
 	default:
 		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
@@ -0,0 +1,222 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/UNROM_512
+// The self-flashable board has the battery flag, and the flash memory (SST39SF040) can be rewritten by the game.
+type mapper30 struct {
+	*Cassette
+	prgBank byte
+	chrBank byte
+	// singleScreen is true if the header's four-screen flag is set, the mirroring is selected by the register.
+	// The real four-screen variant is not supported.
+	singleScreen bool
+	busConflicts bool
+	flashable    bool
+	flash        sstFlash
+}
+
+func newMapper30(c *Cassette) *mapper30 {
+	// UNROM 512 has 32 KiB CHR RAM in 4 banks
+	if c.chrROMSize == 0 && len(c.CHR) < 0x8000 {
+		c.CHR = make([]byte, 0x8000)
+	}
+	m := &mapper30{
+		Cassette:     c,
+		singleScreen: c.Mirror == MirroringFourScreen,
+		// The self-flashable board doesn't have bus conflicts
+		busConflicts: !c.Battery,
+		flashable:    c.Battery,
+		flash:        sstFlash{data: c.PRG},
+	}
+	if m.singleScreen {
+		m.Mirror = MirroringSingleScreenLow
+	}
+	return m
+}
+
+func (m *mapper30) String() string {
+	return "Mapper 30"
+}
+
+func (m *mapper30) Reset() {
+	m.flash.reset()
+}
+
+// SaveData returns the whole flash memory, nil if the board is not self-flashable
+func (m *mapper30) SaveData() []byte {
+	if !m.flashable {
+		return nil
+	}
+	return m.PRG
+}
+
+func (m *mapper30) LoadSaveData(data []byte) {
+	if len(data) == len(m.PRG) {
+		copy(m.PRG, data)
+	}
+}
+
+func (m *mapper30) prgIndex(bank byte, addr uint16) int {
+	return (int(bank)*0x4000 + int(addr%0x4000)) % len(m.PRG)
+}
+
+func (m *mapper30) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		// > PPU $0000-$1FFF: 8 KB switchable CHR RAM bank
+		return m.readCHR((int(m.chrBank)*0x2000 + int(addr)) % len(m.CHR))
+	case 0x4020 <= addr && addr < 0x8000:
+		// UNROM 512 doesn't have PRG RAM
+		return m.readOpenBus()
+	case 0x8000 <= addr && addr < 0xC000:
+		// > CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+		return m.flash.read(m.prgIndex(m.prgBank, addr))
+	case 0xC000 <= addr && addr <= 0xFFFF:
+		// > CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+		return m.flash.read(m.prgIndex(byte(len(m.PRG)/0x4000-1), addr))
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper30) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.writeCHR((int(m.chrBank)*0x2000+int(addr))%len(m.CHR), val)
+	case 0x4020 <= addr && addr < 0x8000:
+		// nothing
+	case 0x8000 <= addr && addr < 0xC000 && m.flashable:
+		// The flash memory commands, the chip address is made from the PRG bank
+		m.flash.write(m.prgIndex(m.prgBank, addr), val)
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		// > 7  bit  0
+		// > ---- ----
+		// > MCCP PPPP
+		// > |||+-++++- Select 16 KB PRG ROM bank for CPU $8000-$BFFF
+		// > |++------- Select 8 KB CHR RAM bank for PPU $0000-$1FFF
+		// > +--------- Select 1 KB VRAM page for all 4 nametables
+		if m.busConflicts {
+			val &= m.Read(addr)
+		}
+		m.prgBank = val & 0x1F
+		m.chrBank = (val >> 5) & 0x03
+		if m.singleScreen {
+			if (val & 0x80) == 0x80 {
+				m.Mirror = MirroringSingleScreenHigh
+			} else {
+				m.Mirror = MirroringSingleScreenLow
+			}
+		}
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
+
+type sstFlashState int
+
+const (
+	sstFlashIdle sstFlashState = iota
+	sstFlashUnlock1
+	sstFlashUnlock2
+	sstFlashProgram
+	sstFlashErase1
+	sstFlashErase2
+	sstFlashErase3
+)
+
+// sstFlash is the command interface of SST39SF010A/020A/040.
+// The commands are unlocked by writing $AA to $5555 and $55 to $2AAA.
+// Programming and erasing finish immediately, so the polling of the game always sees the completion.
+//
+// https://www.nesdev.org/wiki/UNROM_512#Flash_save
+type sstFlash struct {
+	data   []byte
+	state  sstFlashState
+	idMode bool
+}
+
+const (
+	sstManufacturerID = 0xBF
+	sstDeviceID       = 0xB7 // SST39SF040
+	sstSectorSize     = 0x1000
+)
+
+func (f *sstFlash) reset() {
+	f.state = sstFlashIdle
+	f.idMode = false
+}
+
+func (f *sstFlash) read(index int) byte {
+	if f.idMode {
+		// Software ID mode returns the IDs instead of the memory
+		if index&0x01 == 0 {
+			return sstManufacturerID
+		}
+		return sstDeviceID
+	}
+	return f.data[index]
+}
+
+func (f *sstFlash) write(index int, val byte) {
+	// The command addresses are decoded by A0-A14
+	cmdAddr := index & 0x7FFF
+	if val == 0xF0 && f.state != sstFlashProgram {
+		// Software ID exit / reset
+		f.reset()
+		return
+	}
+	switch f.state {
+	case sstFlashIdle:
+		if cmdAddr == 0x5555 && val == 0xAA {
+			f.state = sstFlashUnlock1
+		}
+	case sstFlashUnlock1:
+		f.state = sstFlashIdle
+		if cmdAddr == 0x2AAA && val == 0x55 {
+			f.state = sstFlashUnlock2
+		}
+	case sstFlashUnlock2:
+		f.state = sstFlashIdle
+		if cmdAddr != 0x5555 {
+			return
+		}
+		switch val {
+		case 0xA0:
+			f.state = sstFlashProgram
+		case 0x80:
+			f.state = sstFlashErase1
+		case 0x90:
+			f.idMode = true
+		}
+	case sstFlashProgram:
+		// Programming can only clear the bits, erasing sets them
+		f.data[index] &= val
+		f.state = sstFlashIdle
+	case sstFlashErase1:
+		f.state = sstFlashIdle
+		if cmdAddr == 0x5555 && val == 0xAA {
+			f.state = sstFlashErase2
+		}
+	case sstFlashErase2:
+		f.state = sstFlashIdle
+		if cmdAddr == 0x2AAA && val == 0x55 {
+			f.state = sstFlashErase3
+		}
+	case sstFlashErase3:
+		f.state = sstFlashIdle
+		switch {
+		case val == 0x30:
+			// Sector erase
+			start := index &^ (sstSectorSize - 1)
+			for i := start; i < start+sstSectorSize && i < len(f.data); i++ {
+				f.data[i] = 0xFF
+			}
+		case val == 0x10 && cmdAddr == 0x5555:
+			// Chip erase
+			for i := range f.data {
+				f.data[i] = 0xFF
+			}
+		}
+	}
+}
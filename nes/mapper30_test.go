@@ -0,0 +1,66 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Mapper30_Flash(t *testing.T) {
+	t.Parallel()
+	type write struct {
+		addr uint16
+		val  byte
+	}
+	unlock := func(cmd byte) []write {
+		return []write{{0xC000, 0x01}, {0x9555, 0xAA}, {0xC000, 0x00}, {0xAAAA, 0x55}, {0xC000, 0x01}, {0x9555, cmd}}
+	}
+	program := func(bank byte, addr uint16, val byte) []write {
+		return append(unlock(0xA0), write{0xC000, bank}, write{addr, val})
+	}
+	sectorErase := func(bank byte, addr uint16) []write {
+		w := append(unlock(0x80), unlock(0xAA)[:4]...)
+		return append(w, write{0xC000, bank}, write{addr, 0x30})
+	}
+
+	tests := []struct {
+		name   string
+		writes []write
+		bank   byte
+		addr   uint16
+		want   byte
+	}{
+		{"1", program(3, 0x8123, 0x5A), 3, 0x8123, 0x5A},
+		// programming can only clear the bits
+		{"2", append(program(3, 0x8123, 0x0F), program(3, 0x8123, 0xF3)...), 3, 0x8123, 0x03},
+		{"3", append(program(3, 0x8123, 0x00), sectorErase(3, 0x8000)...), 3, 0x8123, 0xFF},
+		// the other sector is not erased
+		{"4", append(program(3, 0x9123, 0x00), sectorErase(3, 0x8000)...), 3, 0x9123, 0x00},
+		// an incomplete sequence doesn't write
+		{"5", append(unlock(0xA0)[:4], write{0xC000, 3}, write{0x8123, 0x5A}), 3, 0x8123, 0xFF},
+		{"6", unlock(0x90), 1, 0x8000, sstManufacturerID},
+		{"7", append(unlock(0x90), write{0x8000, 0xF0}), 1, 0x8001, 0xFF},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			prg := make([]byte, 0x80000)
+			for i := range prg {
+				prg[i] = 0xFF
+			}
+			m := newMapper30(&Cassette{
+				PRG:     prg,
+				CHR:     make([]byte, 0x2000),
+				Mirror:  MirroringFourScreen,
+				Battery: true,
+			})
+			for _, w := range tt.writes {
+				m.Write(w.addr, w.val)
+			}
+			m.Write(0xC000, tt.bank)
+			assert.Equal(t, tt.want, m.Read(tt.addr))
+		})
+	}
+}
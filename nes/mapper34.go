@@ -0,0 +1,100 @@
+package nes
+
+import "fmt"
+
+// Mapper 34 is used by two boards, BNROM and NINA-001
+// https://www.nesdev.org/wiki/INES_Mapper_034
+type mapper34 struct {
+	*Cassette
+	// nina is true for NINA-001, false for BNROM
+	nina     bool
+	prgBank  byte
+	chrBanks [2]byte
+	sram     []byte
+}
+
+func newMapper34(c *Cassette) *mapper34 {
+	// NES 2.0 submapper 1 is NINA-001 and 2 is BNROM.
+	// Otherwise, NINA-001 has CHR ROM larger than 8 KiB and BNROM has CHR RAM.
+	nina := c.Submapper == 1 || (c.Submapper == 0 && c.chrROMSize > 0x2000)
+	m := &mapper34{
+		Cassette: c,
+		nina:     nina,
+	}
+	if nina {
+		m.sram = make([]byte, 0x2000)
+	}
+	return m
+}
+
+func (m *mapper34) String() string {
+	return "Mapper 34"
+}
+
+func (m *mapper34) Reset() {
+	// nothing
+}
+
+func (m *mapper34) chrIndex(addr uint16) int {
+	if !m.nina {
+		return int(addr)
+	}
+	// NINA-001 has two 4 KB switchable CHR ROM banks
+	return (int(m.chrBanks[addr/0x1000])*0x1000 + int(addr%0x1000)) % len(m.CHR)
+}
+
+func (m *mapper34) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		return m.readCHR(m.chrIndex(addr))
+	case 0x4020 <= addr && addr < 0x6000:
+		return m.readOpenBus()
+	case 0x6000 <= addr && addr < 0x8000:
+		if !m.nina {
+			// BNROM doesn't have PRG RAM
+			return m.readOpenBus()
+		}
+		return m.sram[addr-0x6000]
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		// > CPU $8000-$FFFF: 32 KB switchable PRG ROM bank
+		index := (int(m.prgBank)*0x8000 + int(addr-0x8000)) % len(m.PRG)
+		return m.PRG[index]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper34) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.writeCHR(m.chrIndex(addr), val)
+	case 0x4020 <= addr && addr < 0x6000:
+		// nothing
+	case 0x6000 <= addr && addr < 0x8000:
+		if !m.nina {
+			return
+		}
+		// The registers are mapped over PRG RAM, the writes go to both
+		m.sram[addr-0x6000] = val
+		switch addr {
+		case 0x7FFD:
+			// 32 KB PRG ROM bank for CPU $8000-$FFFF
+			m.prgBank = val & 0x01
+		case 0x7FFE:
+			// 4 KB CHR ROM bank for PPU $0000-$0FFF
+			m.chrBanks[0] = val & 0x0F
+		case 0x7FFF:
+			// 4 KB CHR ROM bank for PPU $1000-$1FFF
+			m.chrBanks[1] = val & 0x0F
+		}
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		if m.nina {
+			return
+		}
+		// BNROM has bus conflicts
+		val &= m.Read(addr)
+		m.prgBank = val
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
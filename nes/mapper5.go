@@ -0,0 +1,506 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/MMC5
+// The MMC5 watches the PPU much more closely than the other mappers.
+// It snoops PPUCTRL/PPUMASK writes and every PPU read to know the sprite size,
+// which fetch is for the background or the sprites and the current scanline.
+type mapper5 struct {
+	*Cassette
+	prgMode       byte    // $5100
+	chrMode       byte    // $5101
+	prgRAMProtect [2]byte // $5102, $5103
+	exRAMMode     byte    // $5104
+	ntMapping     byte    // $5105
+	fillTile      byte    // $5106
+	fillAttr      byte    // $5107
+	prgBanks      [5]byte // $5113-$5117
+	chrBanksA     [8]int  // $5120-$5127
+	chrBanksB     [4]int  // $5128-$512B
+	chrUpper      byte    // $5130
+	// > In 8x8 sprite mode, only the last set of registers written to (either $5120-$5127 or $5128-$512B) will be used
+	lastCHRSetB bool
+
+	splitCtrl   byte // $5200
+	splitScroll byte // $5201
+	splitBank   byte // $5202
+
+	irqCompare byte // $5203
+	irqEnabled bool
+	irqPending bool
+
+	multiplicand byte // $5205
+	multiplier   byte // $5206
+
+	exRAM [0x400]byte
+	sram  []byte
+
+	// snooped PPU state
+	sprite8x16 bool
+	fetchKind  ppuFetchKind
+	lastAddr   uint16
+	matchCount int
+	idleCycles int
+	inFrame    bool
+	scanline   byte
+	// tile is the index of the background tile being fetched, 0 and 1 are fetched at the end of the previous scanline
+	tile      int
+	tileLine  int
+	exAttr    byte
+	inSplit   bool
+	splitTile int
+	splitY    int
+
+	audio   *mmc5Audio
+	vram    *[2048]byte
+	irqLine *irqInterruptLine
+}
+
+func newMapper5(c *Cassette) *mapper5 {
+	return &mapper5{
+		Cassette: c,
+		// $5100 is $03 and $5117 is $FF at power on
+		prgMode:  3,
+		prgBanks: [5]byte{0, 0, 0, 0, 0xFF},
+		sram:     make([]byte, 0x10000),
+		audio:    newMMC5Audio(),
+	}
+}
+
+func (m *mapper5) String() string {
+	return "Mapper 5"
+}
+
+func (m *mapper5) Reset() {
+	// nothing
+}
+
+func (m *mapper5) connectIRQLine(irqLine *irqInterruptLine) {
+	m.irqLine = irqLine
+}
+
+func (m *mapper5) connectVRAM(vram *[2048]byte) {
+	m.vram = vram
+}
+
+func (m *mapper5) audioOutput() float32 {
+	return m.audio.sample()
+}
+
+func (m *mapper5) SaveData() []byte {
+	if !m.Battery {
+		return nil
+	}
+	return m.sram
+}
+
+func (m *mapper5) LoadSaveData(data []byte) {
+	copy(m.sram, data)
+}
+
+func (m *mapper5) tickCPU() {
+	// https://www.nesdev.org/wiki/MMC5#Scanline_detection_and_scanline_IRQ
+	// If the PPU doesn't read for 3 CPU cycles (rendering disabled or vblank), the "in frame" flag is cleared.
+	m.idleCycles++
+	if m.idleCycles >= 3 {
+		m.inFrame = false
+		// the reads before the idle time are not consecutive
+		m.lastAddr = 0
+		m.matchCount = 0
+	}
+	m.audio.tick()
+}
+
+func (m *mapper5) updateIRQ() {
+	if (m.irqEnabled && m.irqPending) || m.audio.irqAsserted() {
+		m.irqLine.setLow(irqSourceMapper)
+	} else {
+		m.irqLine.setHigh(irqSourceMapper)
+	}
+}
+
+func (m *mapper5) notifyPPURegisterWrite(addr uint16, val byte) {
+	switch addr & 0x07 {
+	case 0:
+		// PPUCTRL bit 5: sprite size
+		m.sprite8x16 = (val & 0x20) == 0x20
+	case 1:
+		// PPUMASK, the PPU stops the reads if the rendering is disabled
+		if (val & 0x18) == 0 {
+			m.inFrame = false
+		}
+	}
+}
+
+func (m *mapper5) notifyPPURead(addr uint16, kind ppuFetchKind) {
+	m.fetchKind = kind
+	m.idleCycles = 0
+
+	// The MMC5 detects scanlines by looking for three consecutive PPU reads from the same nametable address.
+	// They are the two garbage nametable fetches at the end of a scanline and the first fetch of the next scanline.
+	if 0x2000 <= addr && addr < 0x3000 && addr == m.lastAddr {
+		m.matchCount++
+		if m.matchCount == 2 {
+			m.detectScanline()
+		}
+	} else {
+		m.matchCount = 0
+	}
+	m.lastAddr = addr
+
+	switch kind {
+	case ppuFetchSpriteNametable, ppuFetchSpritePattern:
+		// the next background fetch is the first tile of the next scanline
+		m.tile = 0
+		if m.inFrame {
+			m.tileLine = int(m.scanline) + 1
+		} else {
+			m.tileLine = 0
+		}
+	case ppuFetchBGNametable:
+		m.exAttr = m.exRAM[addr&0x3FF]
+		m.updateSplit()
+		m.tile++
+	}
+}
+
+func (m *mapper5) detectScanline() {
+	if m.inFrame {
+		m.scanline++
+		if m.scanline == m.irqCompare {
+			m.irqPending = true
+		}
+	} else {
+		m.inFrame = true
+		m.scanline = 0
+		m.irqPending = false
+	}
+	// this fetch is the third tile of the scanline
+	m.tile = 2
+	m.tileLine = int(m.scanline)
+	m.updateIRQ()
+}
+
+// updateSplit decides whether the tile being fetched is in the vertical split region
+func (m *mapper5) updateSplit() {
+	// The vertical split is only available in ExRAM modes 0 and 1
+	m.inSplit = false
+	if (m.splitCtrl&0x80) == 0 || m.exRAMMode > 1 {
+		return
+	}
+	// > 7  bit  0
+	// > ---- ----
+	// > ERxT TTTT
+	// > ||  ||||
+	// > ||  ++++- Specify vertical split start/stop tile
+	// > |+------- Specify vertical split screen side (0:left; 1:right)
+	// > +-------- Enable vertical split mode
+	boundary := int(m.splitCtrl & 0x1F)
+	t := m.tile % 32
+	if (m.splitCtrl & 0x40) == 0x40 {
+		m.inSplit = t >= boundary
+	} else {
+		m.inSplit = t < boundary
+	}
+	m.splitTile = t
+	m.splitY = (m.tileLine + int(m.splitScroll)) % 240
+}
+
+func (m *mapper5) useCHRSetB() bool {
+	if !m.sprite8x16 {
+		return m.lastCHRSetB
+	}
+	// When 8x16 sprites are enabled, the registers $5120-$5127 are used for the sprites and $5128-$512B for the background
+	switch m.fetchKind {
+	case ppuFetchBGPattern:
+		return true
+	case ppuFetchSpritePattern:
+		return false
+	default:
+		return m.lastCHRSetB
+	}
+}
+
+func (m *mapper5) chrIndex(addr uint16) int {
+	// BG tiles from the split or the extended attributes use a 4 KiB bank
+	if m.fetchKind == ppuFetchBGPattern {
+		if m.inSplit {
+			addr = (addr & 0x0FF8) | uint16(m.splitY&0x07)
+			return (int(m.splitBank)*0x1000 + int(addr)) % len(m.CHR)
+		}
+		if m.exRAMMode == 1 {
+			// bits 0-5 select a 4 KiB CHR bank, $5130 bits 0-1 are the upper bits
+			bank := int(m.chrUpper&0x03)<<6 | int(m.exAttr&0x3F)
+			return (bank*0x1000 + int(addr&0x0FFF)) % len(m.CHR)
+		}
+	}
+
+	setB := m.useCHRSetB()
+	var bank, size int
+	switch m.chrMode {
+	case 0:
+		size = 0x2000
+		if setB {
+			bank = m.chrBanksB[3]
+		} else {
+			bank = m.chrBanksA[7]
+		}
+	case 1:
+		size = 0x1000
+		if setB {
+			bank = m.chrBanksB[3]
+		} else {
+			bank = m.chrBanksA[addr/0x1000*4+3]
+		}
+	case 2:
+		size = 0x800
+		if setB {
+			bank = m.chrBanksB[(addr/0x800)%2*2+1]
+		} else {
+			bank = m.chrBanksA[addr/0x800*2+1]
+		}
+	default:
+		size = 0x400
+		if setB {
+			bank = m.chrBanksB[(addr/0x400)%4]
+		} else {
+			bank = m.chrBanksA[addr/0x400]
+		}
+	}
+	return (bank*size + int(addr)%size) % len(m.CHR)
+}
+
+func (m *mapper5) readNametable(addr uint16) byte {
+	if m.inSplit {
+		// the split region uses ExRAM as the nametable
+		switch m.fetchKind {
+		case ppuFetchBGNametable:
+			return m.exRAM[(m.splitY/8)*32+m.splitTile]
+		case ppuFetchBGAttribute:
+			b := m.exRAM[0x3C0+(m.splitY/32)*8+m.splitTile/4]
+			shift := ((m.splitY/16)&1)*4 + ((m.splitTile/2)&1)*2
+			return ((b >> shift) & 0x03) * 0x55
+		}
+	}
+	if m.exRAMMode == 1 && m.fetchKind == ppuFetchBGAttribute {
+		// bits 6-7 select the palette of the tile
+		return (m.exAttr >> 6) * 0x55
+	}
+
+	offset := int(addr % 0x400)
+	// > 7  bit  0
+	// > ---- ----
+	// > DDCC BBAA
+	// Each nametable: 0 = CIRAM page 0, 1 = CIRAM page 1, 2 = ExRAM, 3 = fill mode
+	switch (m.ntMapping >> ((addr - 0x2000) / 0x400 * 2)) & 0x03 {
+	case 0:
+		return m.vram[offset]
+	case 1:
+		return m.vram[0x400+offset]
+	case 2:
+		if m.exRAMMode > 1 {
+			return 0
+		}
+		return m.exRAM[offset]
+	default:
+		if offset < 0x3C0 {
+			return m.fillTile
+		}
+		return (m.fillAttr & 0x03) * 0x55
+	}
+}
+
+func (m *mapper5) writeNametable(addr uint16, val byte) {
+	offset := int(addr % 0x400)
+	switch (m.ntMapping >> ((addr - 0x2000) / 0x400 * 2)) & 0x03 {
+	case 0:
+		m.vram[offset] = val
+	case 1:
+		m.vram[0x400+offset] = val
+	case 2:
+		if m.exRAMMode <= 1 {
+			m.exRAM[offset] = val
+		}
+	default:
+		// fill mode, nothing
+	}
+}
+
+// prgBank returns the value of the bank register for the address in $8000-$FFFF,
+// bit 7 is set for PRG ROM and the others are the 8 KiB bank number.
+func (m *mapper5) prgBank(addr uint16) byte {
+	a := byte((addr - 0x8000) / 0x2000)
+	switch m.prgMode {
+	case 0:
+		// > CPU $8000-$FFFF: 32 KB switchable PRG ROM bank ($5117)
+		return (m.prgBanks[4] & 0xFC) | a
+	case 1:
+		// > CPU $8000-$BFFF: 16 KB switchable PRG ROM/RAM bank ($5115)
+		// > CPU $C000-$FFFF: 16 KB switchable PRG ROM bank ($5117)
+		if a < 2 {
+			return (m.prgBanks[2] & 0xFE) | (a & 0x01)
+		}
+		return (m.prgBanks[4] & 0xFE) | (a & 0x01)
+	case 2:
+		// > CPU $8000-$BFFF: 16 KB switchable PRG ROM/RAM bank ($5115)
+		// > CPU $C000-$DFFF: 8 KB switchable PRG ROM/RAM bank ($5116)
+		// > CPU $E000-$FFFF: 8 KB switchable PRG ROM bank ($5117)
+		if a < 2 {
+			return (m.prgBanks[2] & 0xFE) | (a & 0x01)
+		}
+		return m.prgBanks[a+1]
+	default:
+		// > 8 KB switchable PRG ROM/RAM banks ($5114-$5117)
+		return m.prgBanks[a+1]
+	}
+}
+
+func (m *mapper5) sramIndex(bank byte, addr uint16) int {
+	return (int(bank&0x07)*0x2000 + int(addr%0x2000)) % len(m.sram)
+}
+
+func (m *mapper5) sramWritable() bool {
+	// $5102 must be %10 and $5103 must be %01 to allow writing to PRG RAM
+	return (m.prgRAMProtect[0]&0x03) == 0x02 && (m.prgRAMProtect[1]&0x03) == 0x01
+}
+
+func (m *mapper5) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		return m.readCHR(m.chrIndex(addr))
+	case addr == 0x5010:
+		v := m.audio.readPCMStatus()
+		m.updateIRQ()
+		return v
+	case addr == 0x5015:
+		return m.audio.readStatus()
+	case addr == 0x5204:
+		// > 7  bit  0
+		// > ---- ----
+		// > SVxx xxxx
+		// > ||
+		// > |+-------- "In Frame" flag
+		// > +--------- Scanline IRQ Pending flag
+		// Reading this register acknowledges the IRQ pending flag.
+		v := byte(0)
+		if m.irqPending {
+			v |= 0x80
+		}
+		if m.inFrame {
+			v |= 0x40
+		}
+		m.irqPending = false
+		m.updateIRQ()
+		return v
+	case addr == 0x5205:
+		return byte(uint16(m.multiplicand) * uint16(m.multiplier))
+	case addr == 0x5206:
+		return byte((uint16(m.multiplicand) * uint16(m.multiplier)) >> 8)
+	case 0x4020 <= addr && addr < 0x5C00:
+		return m.readOpenBus()
+	case 0x5C00 <= addr && addr < 0x6000:
+		// Modes 0 and 1: ExRAM can't be read by the CPU (open bus)
+		if m.exRAMMode < 2 {
+			return m.readOpenBus()
+		}
+		return m.exRAM[addr-0x5C00]
+	case 0x6000 <= addr && addr < 0x8000:
+		return m.sram[m.sramIndex(m.prgBanks[0], addr)]
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		if addr == 0xFFFA || addr == 0xFFFB {
+			// The "in frame" flag is cleared when the CPU reads the NMI vector
+			m.inFrame = false
+		}
+		var v byte
+		if bank := m.prgBank(addr); (bank & 0x80) == 0x80 {
+			v = m.PRG[(int(bank&0x7F)*0x2000+int(addr%0x2000))%len(m.PRG)]
+		} else {
+			v = m.sram[m.sramIndex(bank, addr)]
+		}
+		if addr < 0xC000 {
+			m.audio.snoopPRGRead(v)
+		}
+		return v
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper5) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.writeCHR(m.chrIndex(addr), val)
+	case 0x5000 <= addr && addr <= 0x5015:
+		m.audio.write(addr, val)
+	case addr == 0x5100:
+		m.prgMode = val & 0x03
+	case addr == 0x5101:
+		m.chrMode = val & 0x03
+	case addr == 0x5102 || addr == 0x5103:
+		m.prgRAMProtect[addr-0x5102] = val
+	case addr == 0x5104:
+		m.exRAMMode = val & 0x03
+	case addr == 0x5105:
+		m.ntMapping = val
+	case addr == 0x5106:
+		m.fillTile = val
+	case addr == 0x5107:
+		m.fillAttr = val & 0x03
+	case 0x5113 <= addr && addr <= 0x5117:
+		if addr == 0x5117 {
+			// $5117 is always PRG ROM
+			val |= 0x80
+		}
+		m.prgBanks[addr-0x5113] = val
+	case 0x5120 <= addr && addr <= 0x5127:
+		m.chrBanksA[addr-0x5120] = int(m.chrUpper&0x03)<<8 | int(val)
+		m.lastCHRSetB = false
+	case 0x5128 <= addr && addr <= 0x512B:
+		m.chrBanksB[addr-0x5128] = int(m.chrUpper&0x03)<<8 | int(val)
+		m.lastCHRSetB = true
+	case addr == 0x5130:
+		m.chrUpper = val & 0x03
+	case addr == 0x5200:
+		m.splitCtrl = val
+	case addr == 0x5201:
+		m.splitScroll = val
+	case addr == 0x5202:
+		m.splitBank = val
+	case addr == 0x5203:
+		m.irqCompare = val
+	case addr == 0x5204:
+		m.irqEnabled = (val & 0x80) == 0x80
+		m.updateIRQ()
+	case addr == 0x5205:
+		m.multiplicand = val
+	case addr == 0x5206:
+		m.multiplier = val
+	case 0x4020 <= addr && addr < 0x5C00:
+		// nothing
+	case 0x5C00 <= addr && addr < 0x6000:
+		switch m.exRAMMode {
+		case 0, 1:
+			// Modes 0 and 1: the CPU writes $00 instead of the value if the PPU is not rendering
+			if m.inFrame {
+				m.exRAM[addr-0x5C00] = val
+			} else {
+				m.exRAM[addr-0x5C00] = 0
+			}
+		case 2:
+			m.exRAM[addr-0x5C00] = val
+		default:
+			// read-only
+		}
+	case 0x6000 <= addr && addr < 0x8000:
+		if m.sramWritable() {
+			m.sram[m.sramIndex(m.prgBanks[0], addr)] = val
+		}
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		if bank := m.prgBank(addr); (bank&0x80) == 0 && m.sramWritable() {
+			m.sram[m.sramIndex(bank, addr)] = val
+		}
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
@@ -0,0 +1,78 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/INES_Mapper_058
+// The multicarts like "68-in-1" (UNIF BMC-GK-192)
+type mapper58 struct {
+	*Cassette
+	prgBank byte
+	prgMode byte
+	chrBank byte
+}
+
+func newMapper58(c *Cassette) *mapper58 {
+	c.Mirror = MirroringVertical
+	return &mapper58{
+		Cassette: c,
+	}
+}
+
+func (m *mapper58) String() string {
+	return "Mapper 58"
+}
+
+func (m *mapper58) Reset() {
+	// nothing
+}
+
+func (m *mapper58) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		index := (int(m.chrBank)*0x2000 + int(addr)) % len(m.CHR)
+		return m.readCHR(index)
+	case 0x4020 <= addr && addr < 0x8000:
+		// the board doesn't have PRG RAM
+		return m.readOpenBus()
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		var index int
+		if m.prgMode == 1 {
+			// the 16 KB bank is mirrored at $8000 and $C000
+			index = int(m.prgBank)*0x4000 + int(addr&0x3FFF)
+		} else {
+			// the low bit of the bank is ignored in 32 KB mode
+			index = int(m.prgBank>>1)*0x8000 + int(addr-0x8000)
+		}
+		return m.PRG[index%len(m.PRG)]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper58) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		index := (int(m.chrBank)*0x2000 + int(addr)) % len(m.CHR)
+		m.writeCHR(index, val)
+	case 0x4020 <= addr && addr < 0x8000:
+		// nothing
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		// The address is latched, and the data is ignored
+		// > A~[1... .... MOCC CPPP]
+		// >              |||| ||||
+		// >              |||| |+++- PRG bank
+		// >              ||++-+---- CHR bank
+		// >              |+-------- PRG mode (0: 32 KB, 1: 16 KB)
+		// >              +--------- Mirroring (0: Vertical, 1: Horizontal)
+		m.prgBank = byte(addr & 0x07)
+		m.chrBank = byte(addr>>3) & 0x07
+		m.prgMode = byte(addr>>6) & 0x01
+		if addr&0x80 == 0x80 {
+			m.Mirror = MirroringHorizontal
+		} else {
+			m.Mirror = MirroringVertical
+		}
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
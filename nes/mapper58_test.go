@@ -0,0 +1,43 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Mapper58_Write(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		addr       uint16
+		wantPRG    [2]byte // the first bytes of $8000 and $C000
+		wantCHR    byte
+		wantMirror MirroringType
+	}{
+		{"1", 0x8000, [2]byte{0, 1}, 0, MirroringVertical},
+		{"2", 0x8043, [2]byte{3, 3}, 0, MirroringVertical},
+		{"3", 0x80C5, [2]byte{5, 5}, 0, MirroringHorizontal},
+		{"4", 0xFF9B, [2]byte{2, 3}, 3, MirroringHorizontal},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			prg := make([]byte, 0x20000)
+			for i := 0; i < 8; i++ {
+				prg[i*0x4000] = byte(i)
+			}
+			chr := make([]byte, 0x10000)
+			for i := 0; i < 8; i++ {
+				chr[i*0x2000] = byte(i)
+			}
+			m := newMapper58(&Cassette{PRG: prg, CHR: chr, chrROMSize: len(chr)})
+			m.Write(tt.addr, 0xFF)
+			assert.Equal(t, tt.wantPRG, [2]byte{m.Read(0x8000), m.Read(0xC000)})
+			assert.Equal(t, tt.wantCHR, m.Read(0x0000))
+			assert.Equal(t, tt.wantMirror, m.MirroingType())
+		})
+	}
+}
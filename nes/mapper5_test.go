@@ -0,0 +1,54 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Mapper5_ScanlineIRQ(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		compare byte
+		want    int
+	}{
+		{"1", 1, 1},
+		{"2", 100, 100},
+		{"3", 239, 239},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := newMapper5(&Cassette{
+				PRG: make([]byte, 0x8000),
+				CHR: make([]byte, 0x2000),
+			})
+			var irqLine irqInterruptLine
+			var nmiLine nmiInterruptLine
+			m.connectIRQLine(&irqLine)
+			ppu := newPPU(nopRenderer{}, m, MirroringVertical, &nmiLine)
+			ppu.writeMask(0x18)
+			m.Write(0x5203, tt.compare)
+			m.Write(0x5204, 0x80)
+
+			// start from the pre-render line
+			ppu.scanline = 261
+			got := -1
+			for i := 0; i < 341*262 && got < 0; i++ {
+				ppu.step()
+				if i%3 == 0 {
+					m.tickCPU()
+				}
+				if irqLine.isLow() && ppu.scanline < 240 {
+					got = ppu.scanline
+				}
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, byte(0xC0), m.Read(0x5204))
+			assert.True(t, irqLine.isHigh())
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/Sunsoft_FME-7
+// Sunsoft FME-7, 5A and 5B
+type mapper69 struct {
+	*Cassette
+	command  byte
+	chrBanks [8]byte
+	prgBanks [4]byte // $6000, $8000, $A000, $C000
+	sramSel  bool    // true: RAM, false: ROM at $6000-$7FFF
+	sramEn   bool
+	sram     []byte
+
+	irqEnabled        bool
+	irqCounterEnabled bool
+	irqCounter        uint16
+
+	audio   *sunsoft5BAudio
+	irqLine *irqInterruptLine
+}
+
+func newMapper69(c *Cassette) *mapper69 {
+	return &mapper69{
+		Cassette: c,
+		sram:     c.newPRGRAM(),
+		audio:    newSunsoft5BAudio(),
+	}
+}
+
+func (m *mapper69) String() string {
+	return "Mapper 69"
+}
+
+func (m *mapper69) Reset() {
+	// nothing
+}
+
+func (m *mapper69) connectIRQLine(irqLine *irqInterruptLine) {
+	m.irqLine = irqLine
+}
+
+func (m *mapper69) tickCPU() {
+	// > When enabled, the 16-bit IRQ counter is decremented once per CPU cycle.
+	// > When the IRQ counter is decremented from $0000 to $FFFF, an IRQ is generated if IRQ generation is enabled.
+	if m.irqCounterEnabled {
+		m.irqCounter--
+		if m.irqCounter == 0xFFFF && m.irqEnabled {
+			m.irqLine.setLow(irqSourceMapper)
+		}
+	}
+	m.audio.tick()
+}
+
+func (m *mapper69) audioOutput() float32 {
+	return m.audio.sample() * 0.4
+}
+
+func (m *mapper69) SaveData() []byte {
+	if !m.Battery {
+		return nil
+	}
+	return m.sram
+}
+
+func (m *mapper69) LoadSaveData(data []byte) {
+	copy(m.sram, data)
+}
+
+func (m *mapper69) prgIndex(bank byte, addr uint16) int {
+	return (int(bank)%(len(m.PRG)/0x2000))*0x2000 + int(addr%0x2000)
+}
+
+func (m *mapper69) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		index := (int(m.chrBanks[addr/0x400])*0x400 + int(addr%0x400)) % len(m.CHR)
+		return m.readCHR(index)
+	case 0x4020 <= addr && addr < 0x6000:
+		return m.readOpenBus()
+	case 0x6000 <= addr && addr < 0x8000:
+		if !m.sramSel {
+			return m.PRG[m.prgIndex(m.prgBanks[0], addr)]
+		}
+		if !m.sramEn || m.sram == nil {
+			return m.readOpenBus()
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case 0x8000 <= addr && addr < 0xE000:
+		return m.PRG[m.prgIndex(m.prgBanks[(addr-0x6000)/0x2000], addr)]
+	case 0xE000 <= addr && addr <= 0xFFFF:
+		// > CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+		return m.PRG[len(m.PRG)-0x2000+int(addr-0xE000)]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper69) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		index := (int(m.chrBanks[addr/0x400])*0x400 + int(addr%0x400)) % len(m.CHR)
+		m.writeCHR(index, val)
+	case 0x4020 <= addr && addr < 0x6000:
+		// nothing
+	case 0x6000 <= addr && addr < 0x8000:
+		if m.sramSel && m.sramEn && m.sram != nil {
+			m.sram[int(addr-0x6000)%len(m.sram)] = val
+		}
+	case 0x8000 <= addr && addr < 0xA000:
+		// > Command Register ($8000-$9FFF)
+		m.command = val & 0x0F
+	case 0xA000 <= addr && addr < 0xC000:
+		// > Parameter Register ($A000-$BFFF)
+		m.writeParameter(val)
+	case 0xC000 <= addr && addr < 0xE000:
+		// > Audio Register Select ($C000-$DFFF)
+		m.audio.writeAddress(val)
+	case 0xE000 <= addr && addr <= 0xFFFF:
+		// > Audio Register Write ($E000-$FFFF)
+		m.audio.writeData(val)
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
+
+func (m *mapper69) writeParameter(val byte) {
+	switch c := m.command; {
+	case c <= 0x07:
+		// > CHR Bank 0-7 ($0-7)
+		m.chrBanks[c] = val
+	case c == 0x08:
+		// > PRG Bank 0 ($8)
+		// > 7  bit  0
+		// > ---- ----
+		// > ERbB BBBB
+		// > |||| ||||
+		// > ||++-++++- The bank number to select at CPU $6000 - $7FFF
+		// > |+------- RAM / ROM Select (0 = PRG ROM, 1 = PRG RAM)
+		// > +-------- RAM Enable (0 = PRG RAM Disabled, 1 = PRG RAM Enabled)
+		m.prgBanks[0] = val & 0x3F
+		m.sramSel = (val & 0x40) == 0x40
+		m.sramEn = (val & 0x80) == 0x80
+	case 0x09 <= c && c <= 0x0B:
+		// > PRG Bank 1-3 ($9-B)
+		m.prgBanks[c-0x08] = val & 0x3F
+	case c == 0x0C:
+		// > Name Table Mirroring ($C)
+		switch val & 0x03 {
+		case 0:
+			m.Mirror = MirroringVertical
+		case 1:
+			m.Mirror = MirroringHorizontal
+		case 2:
+			m.Mirror = MirroringSingleScreenLow
+		case 3:
+			m.Mirror = MirroringSingleScreenHigh
+		}
+	case c == 0x0D:
+		// > IRQ Control ($D)
+		// > 7  bit  0
+		// > ---- ----
+		// > C... ...T
+		// > |       |
+		// > |       +- IRQ Enable (0 = Do not generate IRQs, 1 = Do generate IRQs)
+		// > +-------- IRQ Counter Enable (0 = Disable Counter Decrement, 1 = Enable Counter Decrement)
+		// > All writes to this register acknowledge an active IRQ.
+		m.irqEnabled = (val & 0x01) == 0x01
+		m.irqCounterEnabled = (val & 0x80) == 0x80
+		m.irqLine.setHigh(irqSourceMapper)
+	case c == 0x0E:
+		// > IRQ Counter Low Byte ($E)
+		m.irqCounter = (m.irqCounter & 0xFF00) | uint16(val)
+	case c == 0x0F:
+		// > IRQ Counter High Byte ($F)
+		m.irqCounter = (m.irqCounter & 0x00FF) | (uint16(val) << 8)
+	}
+}
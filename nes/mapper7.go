@@ -0,0 +1,70 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/AxROM
+type mapper7 struct {
+	*Cassette
+	busConflicts bool
+	prgBank      byte
+}
+
+func newMapper7(c *Cassette) *mapper7 {
+	c.Mirror = MirroringSingleScreenLow
+	return &mapper7{
+		Cassette: c,
+		// AMROM and AOROM have bus conflicts, ANROM doesn't
+		busConflicts: hasBusConflicts(c, false),
+	}
+}
+
+func (m *mapper7) String() string {
+	return "Mapper 7"
+}
+
+func (m *mapper7) Reset() {
+	// nothing
+}
+
+func (m *mapper7) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		return m.readCHR(int(addr))
+	case 0x4020 <= addr && addr < 0x8000:
+		// AxROM doesn't have PRG RAM
+		return m.readOpenBus()
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		// > CPU $8000-$FFFF: 32 KB switchable PRG ROM bank
+		index := (int(m.prgBank)*0x8000 + int(addr-0x8000)) % len(m.PRG)
+		return m.PRG[index]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper7) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.writeCHR(int(addr), val)
+	case 0x4020 <= addr && addr < 0x8000:
+		// nothing
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		// > 7  bit  0
+		// > ---- ----
+		// > xxxM xPPP
+		// >    |  |||
+		// >    |  +++- Select 32 KB PRG ROM bank for CPU $8000-$FFFF
+		// >    +------ Select 1 KB VRAM page for all 4 nametables
+		if m.busConflicts {
+			val &= m.Read(addr)
+		}
+		m.prgBank = val & 0x07
+		if (val & 0x10) == 0x10 {
+			m.Mirror = MirroringSingleScreenHigh
+		} else {
+			m.Mirror = MirroringSingleScreenLow
+		}
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
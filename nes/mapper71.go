@@ -0,0 +1,77 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/INES_Mapper_071
+type mapper71 struct {
+	*Cassette
+	prgBank byte
+}
+
+func newMapper71(c *Cassette) *mapper71 {
+	return &mapper71{
+		Cassette: c,
+	}
+}
+
+func (m *mapper71) String() string {
+	return "Mapper 71"
+}
+
+func (m *mapper71) Reset() {
+	// nothing
+}
+
+func (m *mapper71) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		return m.readCHR(int(addr))
+	case 0x4020 <= addr && addr < 0x8000:
+		// Camerica boards don't have PRG RAM
+		return m.readOpenBus()
+	case 0x8000 <= addr && addr < 0xC000:
+		// > CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+		index := (int(m.prgBank)*0x4000 + int(addr-0x8000)) % len(m.PRG)
+		return m.PRG[index]
+	case 0xC000 <= addr && addr <= 0xFFFF:
+		// > CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+		return m.PRG[len(m.PRG)-0x4000+int(addr-0xC000)]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper71) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.writeCHR(int(addr), val)
+	case 0x4020 <= addr && addr < 0x8000:
+		// nothing
+	case 0x8000 <= addr && addr < 0xC000:
+		// Mirroring control ($8000-$9FFF) is only on the BF9097 board used by Fire Hawk (submapper 1).
+		// The iNES dumps of Fire Hawk are handled by $9000-$9FFF, which the other games don't write to.
+		if addr >= 0xA000 || (m.Submapper != 1 && addr < 0x9000) {
+			return
+		}
+		// > 7  bit  0
+		// > ---- ----
+		// > xxxM xxxx
+		// >    |
+		// >    +----- Select 1 KB CIRAM bank for PPU $2000-$2FFF
+		if (val & 0x10) == 0x10 {
+			m.Mirror = MirroringSingleScreenHigh
+		} else {
+			m.Mirror = MirroringSingleScreenLow
+		}
+	case 0xC000 <= addr && addr <= 0xFFFF:
+		// > 7  bit  0
+		// > ---- ----
+		// > xxxx PPPP
+		// >      ||||
+		// >      ++++- Select 16 KB PRG ROM bank for CPU $8000-$BFFF
+		// Camerica boards don't have bus conflicts
+		m.prgBank = val & 0x0F
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
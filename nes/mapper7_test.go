@@ -0,0 +1,71 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Mapper7_Write(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		submapper  byte
+		rom        byte
+		val        byte
+		wantBank   byte
+		wantMirror MirroringType
+	}{
+		{"1", 0, 0x00, 0x13, 3, MirroringSingleScreenHigh},
+		{"2", 1, 0x00, 0x02, 2, MirroringSingleScreenLow},
+		// AND-type bus conflicts
+		{"3", 2, 0x11, 0x13, 1, MirroringSingleScreenHigh},
+		{"4", 2, 0x06, 0x13, 2, MirroringSingleScreenLow},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			prg := make([]byte, 0x20000)
+			prg[0] = tt.rom
+			m := newMapper7(&Cassette{
+				PRG:       prg,
+				CHR:       make([]byte, 0x2000),
+				Submapper: tt.submapper,
+			})
+			m.Write(0x8000, tt.val)
+			assert.Equal(t, tt.wantBank, m.prgBank)
+			assert.Equal(t, tt.wantMirror, m.MirroingType())
+		})
+	}
+}
+
+func Test_Mapper34_Board(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		submapper byte
+		chrSize   int
+		wantNINA  bool
+	}{
+		{"1", 0, 0, false},
+		{"2", 0, 0x10000, true},
+		{"3", 1, 0x2000, true},
+		{"4", 2, 0x10000, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := newMapper34(&Cassette{
+				PRG:        make([]byte, 0x10000),
+				CHR:        make([]byte, 0x10000),
+				Submapper:  tt.submapper,
+				chrROMSize: tt.chrSize,
+			})
+			assert.Equal(t, tt.wantNINA, m.nina)
+		})
+	}
+}
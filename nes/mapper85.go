@@ -0,0 +1,227 @@
+package nes
+
+import "fmt"
+
+// https://www.nesdev.org/wiki/VRC7
+type mapper85 struct {
+	*Cassette
+	prgBanks [3]byte
+	chrBanks [8]byte
+	sram     []byte
+	sramEn   bool
+	silence  bool
+	irq      vrcIRQ
+	opll     *opll
+}
+
+func newMapper85(c *Cassette) *mapper85 {
+	return &mapper85{
+		Cassette: c,
+		sram:     c.newPRGRAM(),
+		opll:     newOPLL(),
+	}
+}
+
+func (m *mapper85) String() string {
+	return "Mapper 85"
+}
+
+func (m *mapper85) Reset() {
+	m.irq.control(0)
+	m.opll.reset()
+}
+
+func (m *mapper85) connectIRQLine(irqLine *irqInterruptLine) {
+	m.irq.irqLine = irqLine
+}
+
+func (m *mapper85) tickCPU() {
+	m.irq.tick()
+	m.opll.tick()
+}
+
+func (m *mapper85) audioOutput() float32 {
+	if m.silence {
+		return 0
+	}
+	// The VRC7 output is a bit louder than the 2A03 pulse channel
+	return m.opll.sample() * 0.6
+}
+
+func (m *mapper85) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		// > PPU $0000-$03FF: 1 KB switchable CHR bank
+		// > ...
+		// > PPU $1C00-$1FFF: 1 KB switchable CHR bank
+		index := (int(m.chrBanks[addr/0x400])*0x400 + int(addr%0x400)) % len(m.CHR)
+		return m.readCHR(index)
+	case 0x4020 <= addr && addr < 0x6000:
+		return m.readOpenBus()
+	case 0x6000 <= addr && addr < 0x8000:
+		if !m.sramEn || m.sram == nil {
+			return m.readOpenBus()
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case 0x8000 <= addr && addr < 0xE000:
+		// > CPU $8000-$9FFF: 8 KB switchable PRG ROM bank
+		// > CPU $A000-$BFFF: 8 KB switchable PRG ROM bank
+		// > CPU $C000-$DFFF: 8 KB switchable PRG ROM bank
+		bank := int(m.prgBanks[(addr-0x8000)/0x2000]) % (len(m.PRG) / 0x2000)
+		return m.PRG[bank*0x2000+int(addr%0x2000)]
+	case 0xE000 <= addr && addr <= 0xFFFF:
+		// > CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+		return m.PRG[len(m.PRG)-0x2000+int(addr-0xE000)]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper85) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		index := (int(m.chrBanks[addr/0x400])*0x400 + int(addr%0x400)) % len(m.CHR)
+		m.writeCHR(index, val)
+	case 0x4020 <= addr && addr < 0x6000:
+		// nothing
+	case 0x6000 <= addr && addr < 0x8000:
+		if m.sramEn && m.sram != nil {
+			m.sram[int(addr-0x6000)%len(m.sram)] = val
+		}
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		m.writeRegister(addr, val)
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
+
+func (m *mapper85) writeRegister(addr uint16, val byte) {
+	// > VRC7a (Lagrange Point) uses A4 and VRC7b (Tiny Toon Adventures 2) uses A3 as the second register line.
+	// Both are accepted here, there is no overlap in the known games.
+	reg := addr & 0xF000
+	if (addr & 0x18) != 0 {
+		reg |= 0x10
+	}
+	switch reg {
+	case 0x8000:
+		m.prgBanks[0] = val & 0x3F
+	case 0x8010:
+		m.prgBanks[1] = val & 0x3F
+	case 0x9000:
+		m.prgBanks[2] = val & 0x3F
+	case 0x9010:
+		// > $9010: Audio Register Select
+		// > $9030: Audio Register Write
+		if (addr & 0x20) == 0x20 {
+			m.opll.writeData(val)
+		} else {
+			m.opll.writeAddress(val)
+		}
+	case 0xA000, 0xA010, 0xB000, 0xB010, 0xC000, 0xC010, 0xD000, 0xD010:
+		idx := (reg>>12-0xA)*2 + (reg>>4)&1
+		m.chrBanks[idx] = val
+	case 0xE000:
+		// 7  bit  0
+		// ---------
+		// RS.. ..MM
+		// ||     ||
+		// ||     ++- Mirroring (0: vertical; 1: horizontal; 2: one-screen, lower bank; 3: one-screen, upper bank)
+		// |+-------- Silence expansion sound if set
+		// +--------- WRAM enable (1: enable WRAM, 0: protect)
+		switch val & 0x03 {
+		case 0:
+			m.Mirror = MirroringVertical
+		case 1:
+			m.Mirror = MirroringHorizontal
+		case 2:
+			m.Mirror = MirroringSingleScreenLow
+		case 3:
+			m.Mirror = MirroringSingleScreenHigh
+		}
+		silence := (val & 0x40) == 0x40
+		if silence && !m.silence {
+			// > Setting this bit also resets the state of the audio chip
+			m.opll.reset()
+		}
+		m.silence = silence
+		m.sramEn = (val & 0x80) == 0x80
+	case 0xE010:
+		m.irq.latch = val
+	case 0xF000:
+		m.irq.control(val)
+	case 0xF010:
+		m.irq.acknowledge()
+	}
+}
+
+// https://www.nesdev.org/wiki/VRC_IRQ
+// The IRQ counter shared with VRC4, VRC6 and VRC7
+type vrcIRQ struct {
+	latch     byte
+	counter   byte
+	prescaler int
+	enabled   bool
+	enableAck bool
+	cycleMode bool
+
+	irqLine *irqInterruptLine
+}
+
+// > IRQ Control
+// > 7  bit  0
+// > ---------
+// > .... .MEA
+// >       |||
+// >       ||+- IRQ Enable after acknowledgement (see IRQ Acknowledge)
+// >       |+-- IRQ Enable (1 = enabled)
+// >       +--- IRQ Mode (1 = cycle mode, 0 = scanline mode)
+func (v *vrcIRQ) control(val byte) {
+	v.enableAck = (val & 0x01) == 0x01
+	v.enabled = (val & 0x02) == 0x02
+	v.cycleMode = (val & 0x04) == 0x04
+	if v.enabled {
+		v.counter = v.latch
+		v.prescaler = 341
+	}
+	v.clearIRQ()
+}
+
+// > Writing to IRQ Acknowledge will acknowledge an IRQ and copy the 'A' control bit to the 'E' control bit.
+func (v *vrcIRQ) acknowledge() {
+	v.enabled = v.enableAck
+	v.clearIRQ()
+}
+
+func (v *vrcIRQ) clearIRQ() {
+	if v.irqLine != nil {
+		v.irqLine.setHigh(irqSourceMapper)
+	}
+}
+
+func (v *vrcIRQ) tick() {
+	if !v.enabled {
+		return
+	}
+	if v.cycleMode {
+		v.clockCounter()
+		return
+	}
+	// > In scanline mode, a prescaler divides the passing CPU cycles by 113, 113, and 114 in turn.
+	// > This prescaler is a counter which is decremented by 3 every CPU cycle, reloaded with 341 when it reaches zero or less
+	v.prescaler -= 3
+	if v.prescaler <= 0 {
+		v.prescaler += 341
+		v.clockCounter()
+	}
+}
+
+func (v *vrcIRQ) clockCounter() {
+	if v.counter == 0xFF {
+		v.counter = v.latch
+		if v.irqLine != nil {
+			v.irqLine.setLow(irqSourceMapper)
+		}
+	} else {
+		v.counter++
+	}
+}
@@ -0,0 +1,160 @@
+package nes
+
+import "fmt"
+
+// mmc2Latch is the CHR bank switching of MMC2 and MMC4.
+// Each 4 KB CHR area has two banks, and the latch selects one when the PPU reads the tile $FD or $FE.
+type mmc2Latch struct {
+	// banks are indexed by [$0000-$0FFF or $1000-$1FFF][$FD or $FE]
+	banks [2][2]byte
+	// latches are 0 for $FD and 1 for $FE
+	latches [2]byte
+	// lastAddr is the previous PPU read address, the latch is switched after the read
+	lastAddr uint16
+	// MMC4 triggers the latch 0 by $0FD8-$0FDF and $0FE8-$0FEF, MMC2 only by $0FD8 and $0FE8
+	mmc4 bool
+}
+
+func newMMC2Latch(mmc4 bool) mmc2Latch {
+	return mmc2Latch{
+		latches: [2]byte{1, 1},
+		mmc4:    mmc4,
+	}
+}
+
+func (l *mmc2Latch) notifyPPURead(addr uint16) {
+	l.update(l.lastAddr)
+	l.lastAddr = addr
+}
+
+func (l *mmc2Latch) update(addr uint16) {
+	// > PPU reads $0FD8: latch 0 is set to $FD for subsequent reads
+	// > PPU reads $0FE8: latch 0 is set to $FE for subsequent reads
+	// > PPU reads $1FD8 through $1FDF: latch 1 is set to $FD for subsequent reads
+	// > PPU reads $1FE8 through $1FEF: latch 1 is set to $FE for subsequent reads
+	if addr < 0x1000 && !l.mmc4 {
+		switch addr {
+		case 0x0FD8:
+			l.latches[0] = 0
+		case 0x0FE8:
+			l.latches[0] = 1
+		}
+		return
+	}
+	switch addr & 0x1FF8 {
+	case 0x0FD8, 0x1FD8:
+		l.latches[addr/0x1000] = 0
+	case 0x0FE8, 0x1FE8:
+		l.latches[addr/0x1000] = 1
+	}
+}
+
+func (l *mmc2Latch) chrIndex(addr uint16, size int) int {
+	table := addr / 0x1000
+	bank := l.banks[table][l.latches[table]]
+	return (int(bank)*0x1000 + int(addr%0x1000)) % size
+}
+
+// https://www.nesdev.org/wiki/MMC2
+type mapper9 struct {
+	*Cassette
+	prgBank byte
+	chr     mmc2Latch
+	sram    []byte
+}
+
+func newMapper9(c *Cassette) *mapper9 {
+	return &mapper9{
+		Cassette: c,
+		chr:      newMMC2Latch(false),
+		sram:     c.newPRGRAM(),
+	}
+}
+
+func (m *mapper9) String() string {
+	return "Mapper 9"
+}
+
+func (m *mapper9) Reset() {
+	// nothing
+}
+
+func (m *mapper9) notifyPPURead(addr uint16, kind ppuFetchKind) {
+	m.chr.notifyPPURead(addr)
+}
+
+func (m *mapper9) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		return m.readCHR(m.chr.chrIndex(addr, len(m.CHR)))
+	case 0x4020 <= addr && addr < 0x6000:
+		return m.readOpenBus()
+	case 0x6000 <= addr && addr < 0x8000:
+		// PRG RAM is only on the PlayChoice version
+		if m.sram == nil {
+			return m.readOpenBus()
+		}
+		return m.sram[int(addr-0x6000)%len(m.sram)]
+	case 0x8000 <= addr && addr < 0xA000:
+		// > CPU $8000-$9FFF: 8 KB switchable PRG ROM bank
+		index := (int(m.prgBank)*0x2000 + int(addr-0x8000)) % len(m.PRG)
+		return m.PRG[index]
+	case 0xA000 <= addr && addr <= 0xFFFF:
+		// > CPU $A000-$FFFF: Three 8 KB PRG ROM banks, fixed to the last three banks
+		return m.PRG[len(m.PRG)-0x6000+int(addr-0xA000)]
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+func (m *mapper9) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.writeCHR(m.chr.chrIndex(addr, len(m.CHR)), val)
+	case 0x4020 <= addr && addr < 0x6000:
+		// nothing
+	case 0x6000 <= addr && addr < 0x8000:
+		if m.sram != nil {
+			m.sram[int(addr-0x6000)%len(m.sram)] = val
+		}
+	case 0x8000 <= addr && addr < 0xA000:
+		// nothing
+	case 0xA000 <= addr && addr < 0xB000:
+		// > PRG ROM bank select ($A000-$AFFF)
+		m.prgBank = val & 0x0F
+	case 0xB000 <= addr && addr <= 0xFFFF:
+		writeMMC2Register(m.Cassette, &m.chr, addr, val)
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
+
+// writeMMC2Register handles the CHR and mirroring registers at $B000-$FFFF, which are common to MMC2 and MMC4
+func writeMMC2Register(c *Cassette, l *mmc2Latch, addr uint16, val byte) {
+	switch addr & 0xF000 {
+	case 0xB000:
+		// > CHR ROM $FD/0000 bank select ($B000-$BFFF)
+		l.banks[0][0] = val & 0x1F
+	case 0xC000:
+		// > CHR ROM $FE/0000 bank select ($C000-$CFFF)
+		l.banks[0][1] = val & 0x1F
+	case 0xD000:
+		// > CHR ROM $FD/1000 bank select ($D000-$DFFF)
+		l.banks[1][0] = val & 0x1F
+	case 0xE000:
+		// > CHR ROM $FE/1000 bank select ($E000-$EFFF)
+		l.banks[1][1] = val & 0x1F
+	case 0xF000:
+		// > Mirroring ($F000-$FFFF)
+		// > 7  bit  0
+		// > ---- ----
+		// > xxxx xxxM
+		// >         |
+		// >         +- Nametable mirroring (0: vertical; 1: horizontal)
+		if (val & 0x01) == 0x01 {
+			c.Mirror = MirroringHorizontal
+		} else {
+			c.Mirror = MirroringVertical
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MMC2Latch(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		mmc4  bool
+		reads []uint16
+		want  [2]byte
+	}{
+		{"1", false, []uint16{0x0FD8, 0x0000}, [2]byte{0, 1}},
+		{"2", false, []uint16{0x0FD8, 0x0FE8, 0x0000}, [2]byte{1, 1}},
+		// the latch is switched after the read
+		{"3", false, []uint16{0x0FD8}, [2]byte{1, 1}},
+		// MMC2 triggers the latch 0 only by $0FD8
+		{"4", false, []uint16{0x0FDA, 0x0000}, [2]byte{1, 1}},
+		{"5", true, []uint16{0x0FDA, 0x0000}, [2]byte{0, 1}},
+		{"6", false, []uint16{0x1FDF, 0x0000}, [2]byte{1, 0}},
+		{"7", true, []uint16{0x1FD8, 0x0FD8, 0x1FE8, 0x0000}, [2]byte{0, 1}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			l := newMMC2Latch(tt.mmc4)
+			for _, addr := range tt.reads {
+				l.notifyPPURead(addr)
+			}
+			assert.Equal(t, tt.want, l.latches)
+		})
+	}
+}
+
+func Test_Mapper9_ReadCHR(t *testing.T) {
+	t.Parallel()
+	chr := make([]byte, 0x20000)
+	for i := range chr {
+		chr[i] = byte(i / 0x1000)
+	}
+	m := newMapper9(&Cassette{
+		PRG:        make([]byte, 0x20000),
+		CHR:        chr,
+		chrROMSize: len(chr),
+	})
+	m.Write(0xB000, 4)
+	m.Write(0xC000, 5)
+
+	read := func(addr uint16) byte {
+		m.notifyPPURead(addr, ppuFetchBGPattern)
+		return m.Read(addr)
+	}
+	assert.Equal(t, byte(5), read(0x0FD8))
+	assert.Equal(t, byte(4), read(0x0000))
+	assert.Equal(t, byte(4), read(0x0FE8))
+	assert.Equal(t, byte(5), read(0x0000))
+}
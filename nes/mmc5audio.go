@@ -0,0 +1,143 @@
+package nes
+
+// https://www.nesdev.org/wiki/MMC5_audio
+// > The MMC5 has two pulse channels and a raw PCM channel.
+// > The pulse channels behave almost identically to the NES APU pulse channels, but have no sweep unit.
+// > The length counters and envelopes are clocked at a fixed rate of about 240 Hz, regardless of the APU frame counter.
+const mmc5FrameCycles = 7457
+
+type mmc5Audio struct {
+	pulse1 *pulse
+	pulse2 *pulse
+	clock  int
+	frame  int
+
+	pcm           byte
+	pcmReadMode   bool
+	pcmIRQEnabled bool
+	pcmIRQ        bool
+}
+
+func newMMC5Audio() *mmc5Audio {
+	return &mmc5Audio{
+		pulse1: newPulse(1),
+		pulse2: newPulse(2),
+	}
+}
+
+func (a *mmc5Audio) write(addr uint16, val byte) {
+	switch addr {
+	case 0x5000:
+		writePulseController(a.pulse1, val)
+	case 0x5001:
+		// no sweep unit
+	case 0x5002:
+		writePulseTimerLow(a.pulse1, val)
+	case 0x5003:
+		writePulseLengthAndTimerHigh(a.pulse1, val)
+	case 0x5004:
+		writePulseController(a.pulse2, val)
+	case 0x5005:
+		// no sweep unit
+	case 0x5006:
+		writePulseTimerLow(a.pulse2, val)
+	case 0x5007:
+		writePulseLengthAndTimerHigh(a.pulse2, val)
+	case 0x5010:
+		// > 7  bit  0
+		// > ---- ----
+		// > Ixxx xxxM
+		// > |       |
+		// > |       +- Mode select (0 = write mode. 1 = read mode.)
+		// > +--------- PCM IRQ enable (1 = enabled.)
+		a.pcmReadMode = (val & 0x01) == 0x01
+		a.pcmIRQEnabled = (val & 0x80) == 0x80
+	case 0x5011:
+		// Shortly after writing $00, the PCM channel will stop playing in write mode, but writing $00 here has no effect.
+		if !a.pcmReadMode && val != 0 {
+			a.pcm = val
+		}
+	case 0x5015:
+		// ---- --21 Enable pulse channels 2/1
+		a.pulse1.lc.setEnabled((val & 0x01) == 0x01)
+		a.pulse2.lc.setEnabled((val & 0x02) == 0x02)
+	}
+}
+
+// $5010 read
+func (a *mmc5Audio) readPCMStatus() byte {
+	// Reading this register acknowledges the IRQ
+	res := byte(0)
+	if a.pcmIRQ {
+		res |= 0x80
+	}
+	a.pcmIRQ = false
+	return res
+}
+
+// $5015 read
+func (a *mmc5Audio) readStatus() byte {
+	res := byte(0)
+	if a.pulse1.lc.value > 0 {
+		res |= 0x01
+	}
+	if a.pulse2.lc.value > 0 {
+		res |= 0x02
+	}
+	return res
+}
+
+// snoopPRGRead is called on reads of $8000-$BFFF
+func (a *mmc5Audio) snoopPRGRead(val byte) {
+	// In read mode, the PCM channel takes the value of reads from $8000-$BFFF.
+	// A read of $00 triggers an IRQ (if enabled) and does not change the output.
+	if !a.pcmReadMode {
+		return
+	}
+	if val == 0 {
+		a.pcmIRQ = true
+	} else {
+		a.pcm = val
+	}
+}
+
+func (a *mmc5Audio) irqAsserted() bool {
+	return a.pcmIRQEnabled && a.pcmIRQ
+}
+
+func (a *mmc5Audio) tick() {
+	a.clock++
+	if a.clock%2 == 0 {
+		a.clock = 0
+		a.pulse1.tickTimer()
+		a.pulse2.tickTimer()
+	}
+	a.frame++
+	if a.frame >= mmc5FrameCycles {
+		a.frame = 0
+		a.pulse1.tickEnvelope()
+		a.pulse2.tickEnvelope()
+		a.pulse1.tickLengthCounter()
+		a.pulse2.tickLengthCounter()
+	}
+	a.pulse1.lc.applyWrites()
+	a.pulse2.lc.applyWrites()
+}
+
+// Frequency values less than 8 do not silence the MMC5 pulse channels
+func mmc5PulseOutput(p *pulse) byte {
+	if p.lc.value == 0 {
+		return 0
+	}
+	if dutyTable[p.duty][p.dutyPos] == 0 {
+		return 0
+	}
+	return p.el.output()
+}
+
+// sample returns the output mixed at the level of the APU channels,
+// the pulses are the same as the APU pulses and the PCM is about the level of the DMC.
+func (a *mmc5Audio) sample() float32 {
+	pout := pulseTable[mmc5PulseOutput(a.pulse1)+mmc5PulseOutput(a.pulse2)]
+	return pout + tndTable[a.pcm>>1]
+}
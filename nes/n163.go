@@ -0,0 +1,127 @@
+package nes
+
+// https://www.nesdev.org/wiki/Namco_163_audio
+// > The Namco 163 offers up to 8 additional waveform channels.
+// > The N163 has 128 bytes of internal RAM that is shared between channel registers and wavetable.
+// > Internally the N163 updates one channel every 15 CPU cycles.
+const n163ChannelCycles = 15
+
+/*
+Channel registers, n = 0..7 (channel 1..8)
+
+	$40+8n  FFFF FFFF  Low 8 bits of frequency
+	$41+8n  PPPP PPPP  Low 8 bits of phase
+	$42+8n  FFFF FFFF  Middle 8 bits of frequency
+	$43+8n  PPPP PPPP  Middle 8 bits of phase
+	$44+8n  LLLL LLFF  Wave length (256 - %LLLLLL00 samples), high 2 bits of frequency
+	$45+8n  PPPP PPPP  High 8 bits of phase
+	$46+8n  AAAA AAAA  Wave address (in 4-bit samples)
+	$47+8n  -CCC VVVV  Volume (V), number of enabled channels - 1 (C, only $7F)
+*/
+type n163Audio struct {
+	ram      [128]byte
+	address  byte
+	autoIncr bool
+	disabled bool
+	cycle    int
+	channel  int // currently updated channel, 7 (at $78) downward
+	outputs  [8]int
+}
+
+func newN163Audio() *n163Audio {
+	return &n163Audio{
+		channel: 7,
+	}
+}
+
+// $F800-$FFFF
+func (a *n163Audio) writeAddress(val byte) {
+	a.autoIncr = (val & 0x80) == 0x80
+	a.address = val & 0x7F
+}
+
+// $4800-$4FFF read
+func (a *n163Audio) readData() byte {
+	v := a.ram[a.address]
+	a.incrAddress()
+	return v
+}
+
+// $4800-$4FFF write
+func (a *n163Audio) writeData(val byte) {
+	a.ram[a.address] = val
+	a.incrAddress()
+}
+
+func (a *n163Audio) incrAddress() {
+	if a.autoIncr {
+		a.address = (a.address + 1) & 0x7F
+	}
+}
+
+// enabledChannels returns 1 to 8
+func (a *n163Audio) enabledChannels() int {
+	return int((a.ram[0x7F]>>4)&0x07) + 1
+}
+
+func (a *n163Audio) tick() {
+	if a.disabled {
+		return
+	}
+	a.cycle++
+	if a.cycle < n163ChannelCycles {
+		return
+	}
+	a.cycle = 0
+
+	// > It cycles through from channel 8 down to the lowest enabled channel
+	if a.channel < 8-a.enabledChannels() {
+		a.channel = 7
+	}
+	a.outputs[a.channel] = a.updateChannel(a.channel)
+	a.channel--
+	if a.channel < 8-a.enabledChannels() {
+		a.channel = 7
+	}
+}
+
+// updateChannel advances the phase of the channel and returns its output (-120 to 105)
+func (a *n163Audio) updateChannel(ch int) int {
+	base := 0x40 + 8*ch
+	r := a.ram[base : base+8]
+
+	freq := uint32(r[4]&0x03)<<16 | uint32(r[2])<<8 | uint32(r[0])
+	phase := uint32(r[5])<<16 | uint32(r[3])<<8 | uint32(r[1])
+	length := 256 - uint32(r[4]&0xFC)
+
+	phase = (phase + freq) % (length << 16)
+	r[1] = byte(phase)
+	r[3] = byte(phase >> 8)
+	r[5] = byte(phase >> 16)
+
+	sampleAddr := byte((phase >> 16) + uint32(r[6]))
+	sample := a.ram[sampleAddr>>1]
+	if (sampleAddr & 0x01) == 0x01 {
+		sample >>= 4
+	}
+	sample &= 0x0F
+	volume := int(r[7] & 0x0F)
+	return (int(sample) - 8) * volume
+}
+
+// sample returns the average output of the enabled channels.
+// The N163 doesn't mix its channels, it outputs them one after another (time-division multiplexing).
+// The switching runs far above the audio rate and is low-passed on the board,
+// so each channel is heard for 1/N of the time, and the more channels are enabled, the quieter each channel gets.
+// Picking the output of the current slot instead would alias, the sample period repeats the same slots.
+func (a *n163Audio) sample() float32 {
+	if a.disabled {
+		return 0
+	}
+	n := a.enabledChannels()
+	sum := 0
+	for ch := 8 - n; ch < 8; ch++ {
+		sum += a.outputs[ch]
+	}
+	return float32(sum) / float32(n) / 128
+}
@@ -0,0 +1,47 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_N163Audio_Sample(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		channels int
+		channel  int // the channel with the volume
+		want     float32
+	}{
+		{"1", 8, 0, float32((15-8)*15) / 8 / 128},
+		{"2", 8, 3, float32((15-8)*15) / 8 / 128},
+		{"3", 8, 7, float32((15-8)*15) / 8 / 128},
+		{"4", 4, 4, float32((15-8)*15) / 4 / 128},
+		{"5", 1, 7, float32((15-8)*15) / 128},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := newN163Audio()
+			// the sample $F at the wave address 0, the frequency 0 stays at the sample
+			for i := 0; i < 0x40; i++ {
+				a.ram[i] = 0xFF
+			}
+			a.ram[0x40+8*tt.channel+7] = 0x0F
+			a.ram[0x7F] |= byte(tt.channels-1) << 4
+
+			// the APU samples every 40 CPU cycles, that repeats the same channel slots
+			for i := 0; i < 100; i++ {
+				for c := 0; c < 40; c++ {
+					a.tick()
+				}
+				if i >= 10 {
+					assert.InDelta(t, tt.want, a.sample(), 1e-6, "sample:%d", i)
+				}
+			}
+		})
+	}
+}
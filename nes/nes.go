@@ -1,28 +1,44 @@
 package nes
 
 import (
+	"errors"
 	"os"
 	"time"
 )
 
 type NES struct {
+	mapper Mapper
 	cpu    *cpu
 	apu    *apu
 	ppu    *ppu
 	bus    *cpuBus
 	joypad *joypad
 
+	// cycleSteps is true if the CPU runs on the cycle-stepped core
+	cycleSteps bool
+
 	done chan struct{}
 }
 
 type NESOpts struct {
-	debug bool
+	debug      bool
+	unstable   UnstableOpcodeProfile
+	cycleSteps bool
+	ramPattern RAMPattern
+	ramSeed    int64
+	ppuQuirks  PPUQuirks
+	ppuWarmUp  bool
+	ppuReset   PPUReset
 }
 
 type Option func(*NESOpts)
 
 func New(mapper Mapper, renderer Renderer, player Player, options ...Option) *NES {
-	opt := &NESOpts{}
+	opt := &NESOpts{
+		unstable:  DefaultUnstableOpcodeProfile,
+		ppuQuirks: DefaultPPUQuirks,
+		ppuWarmUp: true,
+	}
 	for _, f := range options {
 		f(opt)
 	}
@@ -33,9 +49,21 @@ func New(mapper Mapper, renderer Renderer, player Player, options ...Option) *NE
 	dma := &dma{}
 
 	ppu := newPPU(renderer, mapper, m, &nmiLine)
+	ppu.quirks = opt.ppuQuirks
+	ppu.warmUp = opt.ppuWarmUp && hasPPUWarmUp(mapper)
+	ppu.resetModel = opt.ppuReset
 	joypad := newJoypad()
 	apu := newAPU(&irqLine, player, dma)
 	bus := newCPUBus(ppu, apu, mapper, joypad, dma)
+	bus.ramPattern = opt.ramPattern
+	bus.ramSeed = opt.ramSeed
+
+	if m, ok := mapper.(irqMapper); ok {
+		m.connectIRQLine(&irqLine)
+	}
+	if m, ok := mapper.(audioMapper); ok {
+		apu.expansion = m
+	}
 
 	var tracer *tracer
 	if opt.debug {
@@ -45,14 +73,18 @@ func New(mapper Mapper, renderer Renderer, player Player, options ...Option) *NE
 	}
 
 	cpu := newCPU(bus, &nmiLine, &irqLine, tracer)
+	cpu.unstable = opt.unstable
 
 	return &NES{
+		mapper: mapper,
 		cpu:    cpu,
 		apu:    apu,
 		ppu:    ppu,
 		bus:    bus,
 		joypad: joypad,
 
+		cycleSteps: opt.cycleSteps,
+
 		done: make(chan struct{}),
 	}
 }
@@ -63,37 +95,128 @@ func WithDebug() Option {
 	}
 }
 
+// WithUnstableOpcodes sets the behavior of XAA and LAX #imm
+func WithUnstableOpcodes(p UnstableOpcodeProfile) Option {
+	return func(opts *NESOpts) {
+		opts.unstable = p
+	}
+}
+
+// WithCycleStepCPU runs the CPU on the cycle-stepped core, that advances one bus cycle at a time.
+// The tracer of WithDebug is not available on this core.
+func WithCycleStepCPU() Option {
+	return func(opts *NESOpts) {
+		opts.cycleSteps = true
+	}
+}
+
+// WithRAMPattern sets the content of the RAM at power-up, seed is used only by RAMPatternRandom.
+// The default is RAMPatternZero.
+func WithRAMPattern(p RAMPattern, seed int64) Option {
+	return func(opts *NESOpts) {
+		opts.ramPattern = p
+		opts.ramSeed = seed
+	}
+}
+
+// WithPPUQuirks switches the hardware bugs of the PPU sprite memory.
+// The default is DefaultPPUQuirks.
+func WithPPUQuirks(q PPUQuirks) Option {
+	return func(opts *NESOpts) {
+		opts.ppuQuirks = q
+	}
+}
+
+// WithPPUWarmUp sets whether the PPU ignores the writes to $2000, $2001, $2005 and $2006
+// until the end of the first vblank after PowerUp, as the NTSC and PAL PPUs do.
+// It is enabled by default, and always disabled for the Dendy games.
+// Reset starts the warm-up again only with PPUResetNES of WithPPUReset.
+func WithPPUWarmUp(enabled bool) Option {
+	return func(opts *NESOpts) {
+		opts.ppuWarmUp = enabled
+	}
+}
+
+// WithPPUReset sets how Reset reaches the PPU.
+// The default is PPUResetNES, that clears the registers and starts the warm-up again.
+// PPUResetFamicom leaves the PPU running.
+func WithPPUReset(r PPUReset) Option {
+	return func(opts *NESOpts) {
+		opts.ppuReset = r
+	}
+}
+
+// hasPPUWarmUp returns false if the game runs on a console whose PPU isn't modeled to warm up.
+// The Dendy is built around a clone of the PPU, so the warm-up of the NES isn't applied to it.
+func hasPPUWarmUp(mapper Mapper) bool {
+	if m, ok := mapper.(regionMapper); ok && m.region() == RegionDendy {
+		return false
+	}
+	return true
+}
+
 func (n *NES) PowerUp() {
+	n.bus.powerUp()
 	n.cpu.powerUp()
 	n.apu.powerUp()
 	n.ppu.powerUp()
 }
 
+// Reset pushes the reset button.
+// Unlike PowerUp, the RAM and most of the registers are kept.
 func (n *NES) Reset() {
-	n.cpu.reset()
 	n.apu.reset()
+	n.cpu.reset()
 	n.ppu.reset()
 }
 
+// Step runs an instruction, or an interrupt sequence
 func (n *NES) Step() {
-	n.cpu.step()
+	if !n.cycleSteps {
+		n.cpu.step()
+		return
+	}
+	n.cpu.stepCycle()
+	for !n.cpu.atInstructionBoundary() {
+		n.cpu.stepCycle()
+	}
+}
+
+// StepCycle runs a CPU cycle.
+// Without WithCycleStepCPU, it runs a whole instruction as well as Step.
+func (n *NES) StepCycle() {
+	if !n.cycleSteps {
+		n.cpu.step()
+		return
+	}
+	n.cpu.stepCycle()
+}
+
+// Clock returns the CPU cycles that have elapsed, including the DMA stalls
+func (n *NES) Clock() int {
+	return n.bus.realClock()
 }
 
 func (n *NES) Run() {
+	runRealtime(n.done, n.cpu.bus.realClock, n.StepCycle)
+}
+
+// runRealtime calls step at the speed of the real CPU clock until done is closed
+func runRealtime(done chan struct{}, clock func() int, step func()) {
 	beforeTime := time.Now()
 	steps := float64(0)
 	for {
 		select {
-		case <-n.done:
+		case <-done:
 			return
 		default:
 			now := time.Now()
 			// du / (1sec/CPUClockFrequency)
 			du := float64(now.Sub(beforeTime)*CPUClockFrequency) / float64(time.Second)
 			if steps+du >= 1.0 {
-				beforeClock := n.cpu.bus.realClock()
-				n.cpu.step()
-				afterClock := n.cpu.bus.realClock()
+				beforeClock := clock()
+				step()
+				afterClock := clock()
 				steps = steps + du - float64(afterClock-beforeClock)
 				beforeTime = now
 			}
@@ -113,6 +236,47 @@ func (n *NES) SetCPUPC(pc uint16) {
 	n.cpu.PC = pc
 }
 
+// Halted returns the reason if the CPU is jammed, e.g. by KIL, or nil while running.
+// The CPU stays halted until Reset.
+func (n *NES) Halted() error {
+	if h := n.cpu.halted(); h != nil {
+		return h
+	}
+	return nil
+}
+
 func (n *NES) PeekMemory(addr uint16) byte {
 	return n.bus.peek(addr)
 }
+
+// DiskSides returns the number of the disk sides, 0 if it's not the Famicom Disk System
+func (n *NES) DiskSides() int {
+	if d, ok := n.mapper.(DiskSystemMapper); ok {
+		return d.DiskSides()
+	}
+	return 0
+}
+
+// DiskSide returns the inserted disk side, -1 if no disk is inserted
+func (n *NES) DiskSide() int {
+	if d, ok := n.mapper.(DiskSystemMapper); ok {
+		return d.DiskSide()
+	}
+	return -1
+}
+
+// InsertDisk switches the disk side of the Famicom Disk System.
+// The disk is ejected for a while before inserting, so that the BIOS notices the change.
+func (n *NES) InsertDisk(side int) error {
+	d, ok := n.mapper.(DiskSystemMapper)
+	if !ok {
+		return errors.New("not the famicom disk system")
+	}
+	return d.InsertDisk(side)
+}
+
+func (n *NES) EjectDisk() {
+	if d, ok := n.mapper.(DiskSystemMapper); ok {
+		d.EjectDisk()
+	}
+}
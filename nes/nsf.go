@@ -0,0 +1,230 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// https://www.nesdev.org/wiki/NSF
+// https://www.nesdev.org/wiki/NSFe
+var (
+	nsfMagic  = []byte("NESM\x1a")
+	nsfeMagic = []byte("NSFE")
+)
+
+const (
+	nsfHeaderSize = 0x80
+	// The play speed used when NSFe has no RATE chunk, the same as the common values in NSF
+	nsfDefaultNTSCSpeed = 16639
+	nsfDefaultPALSpeed  = 19997
+)
+
+// Expansion sound chips in the header
+const (
+	NSFChipVRC6 byte = 1 << iota
+	NSFChipVRC7
+	NSFChipFDS
+	NSFChipMMC5
+	NSFChipN163
+	NSFChipSunsoft5B
+)
+
+var nsfChipNames = []string{"VRC6", "VRC7", "FDS", "MMC5", "Namco 163", "Sunsoft 5B"}
+
+type NSF struct {
+	Title     string
+	Artist    string
+	Copyright string
+	Songs     int // the number of the songs
+	StartSong int // 1-based
+	// TrackLabels and TrackTimes are available only in NSFe, the time is in milliseconds (negative if unknown)
+	TrackLabels []string
+	TrackTimes  []int
+
+	LoadAddr uint16
+	InitAddr uint16
+	PlayAddr uint16
+	// PlaySpeed is the period of the PLAY calls in microseconds
+	PlaySpeed uint16
+	PAL       bool
+	Banks     [8]byte
+	Chips     byte
+	Data      []byte
+}
+
+// NewNSF loads a NSF or NSFe file
+func NewNSF(data []byte) (*NSF, error) {
+	switch {
+	case bytes.HasPrefix(data, nsfMagic):
+		return newNSF(data)
+	case bytes.HasPrefix(data, nsfeMagic):
+		return newNSFe(data)
+	default:
+		return nil, errors.New("invalid nsf file")
+	}
+}
+
+// nsfString returns the null-terminated string
+func nsfString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func newNSF(data []byte) (*NSF, error) {
+	if len(data) < nsfHeaderSize {
+		return nil, errors.New("invalid nsf file: too short")
+	}
+	h := data[:nsfHeaderSize]
+	n := &NSF{
+		Songs:     int(h[0x06]),
+		StartSong: int(h[0x07]),
+		LoadAddr:  binary.LittleEndian.Uint16(h[0x08:]),
+		InitAddr:  binary.LittleEndian.Uint16(h[0x0A:]),
+		PlayAddr:  binary.LittleEndian.Uint16(h[0x0C:]),
+		Title:     nsfString(h[0x0E:0x2E]),
+		Artist:    nsfString(h[0x2E:0x4E]),
+		Copyright: nsfString(h[0x4E:0x6E]),
+		PlaySpeed: binary.LittleEndian.Uint16(h[0x6E:]),
+		Chips:     h[0x7B],
+		Data:      data[nsfHeaderSize:],
+	}
+	copy(n.Banks[:], h[0x70:0x78])
+	// $7A bit 0 is PAL, bit 1 is dual PAL/NTSC.
+	// The dual tunes are played as NTSC because the CPU runs at the NTSC clock.
+	if (h[0x7A] & 0x03) == 0x01 {
+		n.PAL = true
+		n.PlaySpeed = binary.LittleEndian.Uint16(h[0x78:])
+	}
+	// NSF2 has the 24-bit program data length at $7D, 0 means all the remaining data
+	if size := int(h[0x7D]) | int(h[0x7E])<<8 | int(h[0x7F])<<16; h[0x05] >= 2 && size > 0 && size < len(n.Data) {
+		n.Data = n.Data[:size]
+	}
+	return n, n.validate()
+}
+
+func newNSFe(data []byte) (*NSF, error) {
+	n := &NSF{
+		Songs:     1,
+		StartSong: 1,
+	}
+	hasInfo := false
+	hasRate := false
+	p := data[len(nsfeMagic):]
+	for {
+		if len(p) < 8 {
+			return nil, errors.New("invalid nsfe file: unexpected end of file")
+		}
+		size := int(binary.LittleEndian.Uint32(p))
+		id := string(p[4:8])
+		if len(p) < 8+size {
+			return nil, fmt.Errorf("invalid nsfe file: %s chunk is too short", id)
+		}
+		c := p[8 : 8+size]
+		p = p[8+size:]
+
+		switch id {
+		case "INFO":
+			if len(c) < 8 {
+				return nil, errors.New("invalid nsfe file: INFO chunk is too short")
+			}
+			hasInfo = true
+			n.LoadAddr = binary.LittleEndian.Uint16(c[0:])
+			n.InitAddr = binary.LittleEndian.Uint16(c[2:])
+			n.PlayAddr = binary.LittleEndian.Uint16(c[4:])
+			n.PAL = (c[6] & 0x03) == 0x01
+			n.Chips = c[7]
+			if len(c) > 8 {
+				n.Songs = int(c[8])
+			}
+			if len(c) > 9 {
+				// 0-based in NSFe
+				n.StartSong = int(c[9]) + 1
+			}
+		case "DATA":
+			n.Data = c
+		case "BANK":
+			copy(n.Banks[:], c)
+		case "RATE":
+			if len(c) >= 2 && !n.PAL {
+				n.PlaySpeed = binary.LittleEndian.Uint16(c)
+				hasRate = true
+			}
+			if len(c) >= 4 && n.PAL {
+				n.PlaySpeed = binary.LittleEndian.Uint16(c[2:])
+				hasRate = true
+			}
+		case "auth":
+			s := bytes.Split(c, []byte{0})
+			for i, f := range []*string{&n.Title, &n.Artist, &n.Copyright} {
+				if i < len(s) {
+					*f = string(s[i])
+				}
+			}
+		case "tlbl":
+			for _, s := range bytes.Split(bytes.TrimSuffix(c, []byte{0}), []byte{0}) {
+				n.TrackLabels = append(n.TrackLabels, string(s))
+			}
+		case "time":
+			for i := 0; i+4 <= len(c); i += 4 {
+				n.TrackTimes = append(n.TrackTimes, int(int32(binary.LittleEndian.Uint32(c[i:]))))
+			}
+		case "NEND":
+			if !hasInfo {
+				return nil, errors.New("invalid nsfe file: no INFO chunk")
+			}
+			if !hasRate {
+				n.PlaySpeed = nsfDefaultNTSCSpeed
+				if n.PAL {
+					n.PlaySpeed = nsfDefaultPALSpeed
+				}
+			}
+			return n, n.validate()
+		default:
+			// A chunk whose ID starts with an uppercase letter is required for the playback
+			if 'A' <= id[0] && id[0] <= 'Z' {
+				return nil, fmt.Errorf("invalid nsfe file: unsupported %s chunk", id)
+			}
+		}
+	}
+}
+
+func (n *NSF) validate() error {
+	if len(n.Data) == 0 {
+		return errors.New("invalid nsf file: no data")
+	}
+	if n.Songs == 0 {
+		return errors.New("invalid nsf file: no songs")
+	}
+	if n.StartSong < 1 || n.Songs < n.StartSong {
+		n.StartSong = 1
+	}
+	if !n.bankswitched() && (n.LoadAddr < 0x6000 || (n.LoadAddr < 0x8000 && (n.Chips&NSFChipFDS) == 0)) {
+		return fmt.Errorf("invalid nsf file: load address 0x%04x", n.LoadAddr)
+	}
+	return nil
+}
+
+// bankswitched is true if any of the initial banks is not 0
+func (n *NSF) bankswitched() bool {
+	for _, b := range n.Banks {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ChipNames returns the names of the expansion sound chips
+func (n *NSF) ChipNames() []string {
+	var res []string
+	for i, name := range nsfChipNames {
+		if (n.Chips>>i)&0x01 == 0x01 {
+			res = append(res, name)
+		}
+	}
+	return res
+}
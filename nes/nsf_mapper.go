@@ -0,0 +1,297 @@
+package nes
+
+import "fmt"
+
+// The player environment for NSF.
+// The tune is placed at $8000-$FFFF (and $6000-$7FFF for FDS) by the 4 KiB banks,
+// and the idle loop at $4100 runs between the INIT and PLAY calls.
+//
+// https://www.nesdev.org/wiki/NSF#Bank_switching
+const (
+	nsfIdleAddr = 0x4100
+)
+
+var nsfIdleLoop = []byte{
+	0x4C, 0x00, 0x41, // JMP $4100
+}
+
+type nsfMapper struct {
+	nsf *NSF
+	// rom is split into the 4 KiB banks
+	rom []byte
+	// banks for $6000-$FFFF, $6000-$7FFF are used only by FDS
+	banks [10]byte
+	// $6000-$7FFF, or $6000-$FFFF for FDS
+	ram []byte
+	chr []byte
+
+	vrc6 *vrc6Audio
+	opll *opll
+	fds  *fdsAudio
+	mmc5 *mmc5Audio
+	n163 *n163Audio
+	s5b  *sunsoft5BAudio
+
+	// MMC5 has the multiplier and ExRAM that are used by some tunes
+	exRAM        [0x400]byte
+	multiplicand byte
+	multiplier   byte
+
+	// openBus is the CPU data bus, that is read from the addresses the player doesn't drive
+	openBus *byte
+}
+
+func newNSFMapper(nsf *NSF) *nsfMapper {
+	m := &nsfMapper{
+		nsf: nsf,
+		chr: make([]byte, 0x2000),
+	}
+	var padding int
+	switch {
+	case nsf.bankswitched():
+		padding = int(nsf.LoadAddr & 0x0FFF)
+	case m.hasChip(NSFChipFDS):
+		padding = int(nsf.LoadAddr - 0x6000)
+	default:
+		padding = int(nsf.LoadAddr - 0x8000)
+	}
+	size := (padding + len(nsf.Data) + 0x0FFF) &^ 0x0FFF
+	// Not to mirror the unused area of the small tune
+	if !nsf.bankswitched() && size < 0xA000 {
+		size = 0xA000
+	}
+	m.rom = make([]byte, size)
+	copy(m.rom[padding:], nsf.Data)
+	if m.hasChip(NSFChipFDS) {
+		m.ram = make([]byte, 0xA000)
+	} else {
+		m.ram = make([]byte, 0x2000)
+	}
+	m.Reset()
+	return m
+}
+
+func (m *nsfMapper) String() string {
+	return "NSF"
+}
+
+func (m *nsfMapper) MirroingType() MirroringType {
+	return MirroringVertical
+}
+
+func (m *nsfMapper) hasChip(chip byte) bool {
+	return (m.nsf.Chips & chip) == chip
+}
+
+// Reset clears the memory and the expansion chips, and restores the initial banks
+func (m *nsfMapper) Reset() {
+	for i := range m.ram {
+		m.ram[i] = 0
+	}
+	m.exRAM = [0x400]byte{}
+	m.multiplicand = 0
+	m.multiplier = 0
+	m.vrc6, m.opll, m.fds, m.mmc5, m.n163, m.s5b = nil, nil, nil, nil, nil, nil
+	if m.hasChip(NSFChipVRC6) {
+		m.vrc6 = newVRC6Audio()
+	}
+	if m.hasChip(NSFChipVRC7) {
+		m.opll = newOPLL()
+	}
+	if m.hasChip(NSFChipFDS) {
+		m.fds = newFDSAudio()
+	}
+	if m.hasChip(NSFChipMMC5) {
+		m.mmc5 = newMMC5Audio()
+	}
+	if m.hasChip(NSFChipN163) {
+		m.n163 = newN163Audio()
+	}
+	if m.hasChip(NSFChipSunsoft5B) {
+		m.s5b = newSunsoft5BAudio()
+	}
+
+	switch {
+	case m.nsf.bankswitched():
+		for i, b := range m.nsf.Banks {
+			m.writeBank(2+i, b)
+		}
+		// The FDS banks at $6000-$7FFF take the values for $E000-$FFFF
+		if m.hasChip(NSFChipFDS) {
+			m.writeBank(0, m.nsf.Banks[6])
+			m.writeBank(1, m.nsf.Banks[7])
+		}
+	case m.hasChip(NSFChipFDS):
+		for i := range m.banks {
+			m.writeBank(i, byte(i))
+		}
+	default:
+		for i := 0; i < 8; i++ {
+			m.writeBank(2+i, byte(i))
+		}
+	}
+}
+
+// writeBank sets the bank of $6000+slot*$1000.
+// FDS copies the bank into RAM because the whole area is writable.
+func (m *nsfMapper) writeBank(slot int, bank byte) {
+	m.banks[slot] = bank
+	if m.fds != nil {
+		copy(m.ram[slot*0x1000:(slot+1)*0x1000], m.romBank(bank))
+	}
+}
+
+func (m *nsfMapper) romBank(bank byte) []byte {
+	offset := (int(bank) * 0x1000) % len(m.rom)
+	return m.rom[offset : offset+0x1000]
+}
+
+func (m *nsfMapper) tickCPU() {
+	if m.vrc6 != nil {
+		m.vrc6.tick()
+	}
+	if m.opll != nil {
+		m.opll.tick()
+	}
+	if m.fds != nil {
+		m.fds.tick()
+	}
+	if m.mmc5 != nil {
+		m.mmc5.tick()
+	}
+	if m.n163 != nil {
+		m.n163.tick()
+	}
+	if m.s5b != nil {
+		m.s5b.tick()
+	}
+}
+
+// audioOutput uses the same levels as the mappers
+func (m *nsfMapper) audioOutput() float32 {
+	v := float32(0)
+	if m.vrc6 != nil {
+		v += m.vrc6.sample() * 0.6
+	}
+	if m.opll != nil {
+		v += m.opll.sample() * 0.6
+	}
+	if m.fds != nil {
+		v += m.fds.sample() * 0.5
+	}
+	if m.mmc5 != nil {
+		v += m.mmc5.sample()
+	}
+	if m.n163 != nil {
+		v += m.n163.sample() * 0.3
+	}
+	if m.s5b != nil {
+		v += m.s5b.sample() * 0.4
+	}
+	return v
+}
+
+func (m *nsfMapper) Read(addr uint16) byte {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		return m.chr[addr]
+	case nsfIdleAddr <= addr && int(addr) < nsfIdleAddr+len(nsfIdleLoop):
+		return nsfIdleLoop[addr-nsfIdleAddr]
+	case m.fds != nil && 0x4040 <= addr && addr <= 0x4097:
+		return m.fds.read(addr, m.readOpenBus())
+	case m.n163 != nil && 0x4800 <= addr && addr < 0x5000:
+		return m.n163.readData()
+	case m.mmc5 != nil && addr == 0x5010:
+		return m.mmc5.readPCMStatus()
+	case m.mmc5 != nil && addr == 0x5015:
+		return m.mmc5.readStatus()
+	case m.mmc5 != nil && addr == 0x5205:
+		return byte(uint16(m.multiplicand) * uint16(m.multiplier))
+	case m.mmc5 != nil && addr == 0x5206:
+		return byte((uint16(m.multiplicand) * uint16(m.multiplier)) >> 8)
+	case m.mmc5 != nil && 0x5C00 <= addr && addr < 0x5FF6:
+		return m.exRAM[addr-0x5C00]
+	case 0x4020 <= addr && addr < 0x6000:
+		return m.readOpenBus()
+	case 0x6000 <= addr && addr < 0x8000:
+		return m.ram[addr-0x6000]
+	case 0x8000 <= addr && addr <= 0xFFFF:
+		var v byte
+		if m.fds != nil {
+			v = m.ram[addr-0x6000]
+		} else {
+			v = m.romBank(m.banks[2+(addr-0x8000)/0x1000])[addr%0x1000]
+		}
+		if m.mmc5 != nil && addr < 0xC000 {
+			m.mmc5.snoopPRGRead(v)
+		}
+		return v
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Read(0x%04x)", m, addr))
+	}
+}
+
+// connectOpenBus passes the CPU data bus
+func (m *nsfMapper) connectOpenBus(openBus *byte) {
+	m.openBus = openBus
+}
+
+// readOpenBus returns the last value on the CPU data bus
+func (m *nsfMapper) readOpenBus() byte {
+	if m.openBus == nil {
+		return 0
+	}
+	return *m.openBus
+}
+
+func (m *nsfMapper) Write(addr uint16, val byte) {
+	switch {
+	case 0x0000 <= addr && addr < 0x2000:
+		m.chr[addr] = val
+	case m.fds != nil && 0x4040 <= addr && addr <= 0x4097:
+		m.fds.write(addr, val)
+	case m.n163 != nil && 0x4800 <= addr && addr < 0x5000:
+		m.n163.writeData(val)
+	case m.mmc5 != nil && 0x5000 <= addr && addr <= 0x5015:
+		m.mmc5.write(addr, val)
+	case m.mmc5 != nil && addr == 0x5205:
+		m.multiplicand = val
+	case m.mmc5 != nil && addr == 0x5206:
+		m.multiplier = val
+	case m.mmc5 != nil && 0x5C00 <= addr && addr < 0x5FF6:
+		m.exRAM[addr-0x5C00] = val
+	case 0x5FF6 <= addr && addr <= 0x5FFF:
+		// $5FF6-$5FF7 are available only for FDS
+		if addr >= 0x5FF8 || m.fds != nil {
+			m.writeBank(int(addr-0x5FF6), val)
+		}
+	case 0x4020 <= addr && addr < 0x6000:
+		// nothing
+	case 0x6000 <= addr && addr <= 0xFFFF:
+		// FDS RAM is writable up to $DFFF
+		if addr < 0x8000 || (m.fds != nil && addr < 0xE000) {
+			m.ram[addr-0x6000] = val
+		}
+		m.writeExpansion(addr, val)
+	default:
+		panic(fmt.Sprintf("Unable to reach %s Write(0x%04x) = 0x%02x", m, addr, val))
+	}
+}
+
+// writeExpansion handles the expansion sound registers at $8000-$FFFF
+func (m *nsfMapper) writeExpansion(addr uint16, val byte) {
+	switch {
+	case m.vrc6 != nil && 0x9000 <= addr && addr <= 0xB002 && (addr&0x0FFF) <= 3:
+		m.vrc6.write(addr, val)
+	case m.opll != nil && addr == 0x9010:
+		m.opll.writeAddress(val)
+	case m.opll != nil && addr == 0x9030:
+		m.opll.writeData(val)
+	case m.n163 != nil && 0xF800 <= addr:
+		m.n163.writeAddress(val)
+	case m.s5b != nil && 0xC000 <= addr && addr < 0xE000:
+		m.s5b.writeAddress(val)
+	case m.s5b != nil && 0xE000 <= addr:
+		m.s5b.writeData(val)
+	}
+}
@@ -0,0 +1,147 @@
+package nes
+
+import (
+	"image/color"
+	"sync/atomic"
+	"time"
+)
+
+// nopRenderer discards the video output, NSF has nothing to display
+type nopRenderer struct{}
+
+func (nopRenderer) Render(x, y int, c color.Color) {}
+func (nopRenderer) Refresh()                       {}
+
+// NSFPlayer plays a NSF tune on the emulated NES.
+// INIT is called when a song is selected, and PLAY is called at the play speed while the CPU is in the idle loop.
+//
+// https://www.nesdev.org/wiki/NSF#Initializing_a_tune
+type NSFPlayer struct {
+	nsf    *NSF
+	nes    *NES
+	mapper *nsfMapper
+
+	// song is the selected song (1-based)
+	song atomic.Int32
+	// request is the song that Step will initialize, 0 if none
+	request atomic.Int32
+
+	playPeriod int
+	nextPlay   int
+
+	done chan struct{}
+}
+
+func NewNSFPlayer(nsf *NSF, player Player) *NSFPlayer {
+	m := newNSFMapper(nsf)
+	p := &NSFPlayer{
+		nsf:    nsf,
+		nes:    New(m, nopRenderer{}, player),
+		mapper: m,
+		done:   make(chan struct{}),
+	}
+	speed := int(nsf.PlaySpeed)
+	if speed == 0 {
+		speed = nsfDefaultNTSCSpeed
+	}
+	p.playPeriod = int(int64(speed) * CPUClockFrequency / int64(time.Second/time.Microsecond))
+	p.nes.PowerUp()
+	p.SelectSong(nsf.StartSong)
+	return p
+}
+
+func (p *NSFPlayer) NSF() *NSF {
+	return p.nsf
+}
+
+// Song returns the selected song (1-based)
+func (p *NSFPlayer) Song() int {
+	return int(p.song.Load())
+}
+
+// SelectSong starts the song (1-based). It's safe to call while Run is running.
+func (p *NSFPlayer) SelectSong(song int) {
+	if song < 1 || p.nsf.Songs < song {
+		return
+	}
+	p.song.Store(int32(song))
+	p.request.Store(int32(song))
+}
+
+func (p *NSFPlayer) Next() {
+	p.SelectSong(p.Song()%p.nsf.Songs + 1)
+}
+
+func (p *NSFPlayer) Prev() {
+	p.SelectSong((p.Song()+p.nsf.Songs-2)%p.nsf.Songs + 1)
+}
+
+// https://www.nesdev.org/wiki/NSF#Initializing_a_tune
+func (p *NSFPlayer) initSong(song int) {
+	bus := p.nes.bus
+	for i := range bus.ram {
+		bus.ram[i] = 0
+	}
+	p.mapper.Reset()
+	for addr := uint16(0x4000); addr <= 0x4013; addr++ {
+		bus.write(addr, 0)
+	}
+	bus.write(0x4015, 0x00)
+	bus.write(0x4015, 0x0F)
+	bus.write(0x4017, 0x40)
+
+	cpu := p.nes.cpu
+	cpu.A = byte(song - 1)
+	cpu.X = 0
+	if p.nsf.PAL {
+		cpu.X = 1
+	}
+	cpu.Y = 0
+	cpu.S = 0xFD
+	cpu.P.setInterruptDisable(true)
+	p.call(p.nsf.InitAddr)
+	p.nextPlay = bus.realClock() + p.playPeriod
+}
+
+// call jumps to the routine, RTS returns to the idle loop
+func (p *NSFPlayer) call(addr uint16) {
+	cpu := p.nes.cpu
+	ret := uint16(nsfIdleAddr - 1)
+	cpu.bus.ram[0x100|uint16(cpu.S)] = byte(ret >> 8)
+	cpu.S--
+	cpu.bus.ram[0x100|uint16(cpu.S)] = byte(ret)
+	cpu.S--
+	cpu.PC = addr
+}
+
+// Step runs an instruction
+func (p *NSFPlayer) Step() {
+	if song := p.request.Swap(0); song != 0 {
+		p.initSong(int(song))
+	}
+	// PLAY is not called until INIT or the previous PLAY returns
+	if clock := p.nes.bus.realClock(); p.nes.cpu.PC == nsfIdleAddr && p.nextPlay <= clock {
+		p.call(p.nsf.PlayAddr)
+		for p.nextPlay <= clock {
+			p.nextPlay += p.playPeriod
+		}
+	}
+	p.nes.cpu.step()
+}
+
+// Run plays in real time until Close is called
+func (p *NSFPlayer) Run() {
+	runRealtime(p.done, p.nes.bus.realClock, p.Step)
+}
+
+// RunFor plays as fast as possible for the duration of the emulated time, e.g. to render a WAV file
+func (p *NSFPlayer) RunFor(d time.Duration) {
+	end := p.nes.bus.realClock() + int(d.Seconds()*CPUClockFrequency)
+	for p.nes.bus.realClock() < end {
+		p.Step()
+	}
+}
+
+func (p *NSFPlayer) Close() {
+	close(p.done)
+}
@@ -0,0 +1,156 @@
+package nes
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestNSF returns the tune that INIT stores the song number in $6000, and PLAY increments $6001
+func newTestNSF(songs byte) []byte {
+	h := make([]byte, nsfHeaderSize)
+	copy(h, nsfMagic)
+	h[0x05] = 1
+	h[0x06] = songs
+	h[0x07] = 1
+	binary.LittleEndian.PutUint16(h[0x08:], 0x8000)
+	binary.LittleEndian.PutUint16(h[0x0A:], 0x8000)
+	binary.LittleEndian.PutUint16(h[0x0C:], 0x8004)
+	copy(h[0x0E:], "title")
+	copy(h[0x2E:], "artist")
+	copy(h[0x4E:], "copyright")
+	binary.LittleEndian.PutUint16(h[0x6E:], 16639)
+	code := []byte{
+		0x8D, 0x00, 0x60, // STA $6000
+		0x60,             // RTS
+		0xEE, 0x01, 0x60, // INC $6001
+		0x60, // RTS
+	}
+	return append(h, code...)
+}
+
+func newTestNSFe() []byte {
+	chunk := func(id string, data []byte) []byte {
+		b := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+		return append(append(b, id...), data...)
+	}
+	nsf := newTestNSF(2)
+	info := []byte{0x00, 0x80, 0x00, 0x80, 0x04, 0x80, 0x00, 0x00, 0x02, 0x01}
+	b := append([]byte{}, nsfeMagic...)
+	b = append(b, chunk("INFO", info)...)
+	b = append(b, chunk("DATA", nsf[nsfHeaderSize:])...)
+	b = append(b, chunk("auth", []byte("title\x00artist\x00copyright\x00"))...)
+	b = append(b, chunk("tlbl", []byte("first\x00second\x00"))...)
+	b = append(b, chunk("time", []byte{0x10, 0x27, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF})...)
+	b = append(b, chunk("xtra", []byte{0x01})...)
+	return append(b, chunk("NEND", nil)...)
+}
+
+func Test_NewNSF(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *NSF
+		wantErr bool
+	}{
+		{"1", newTestNSF(3), &NSF{
+			Title:     "title",
+			Artist:    "artist",
+			Copyright: "copyright",
+			Songs:     3,
+			StartSong: 1,
+			LoadAddr:  0x8000,
+			InitAddr:  0x8000,
+			PlayAddr:  0x8004,
+			PlaySpeed: 16639,
+			Data:      newTestNSF(3)[nsfHeaderSize:],
+		}, false},
+		{"2", newTestNSFe(), &NSF{
+			Title:       "title",
+			Artist:      "artist",
+			Copyright:   "copyright",
+			Songs:       2,
+			StartSong:   2,
+			TrackLabels: []string{"first", "second"},
+			TrackTimes:  []int{10000, -1},
+			LoadAddr:    0x8000,
+			InitAddr:    0x8000,
+			PlayAddr:    0x8004,
+			PlaySpeed:   nsfDefaultNTSCSpeed,
+			Data:        newTestNSF(2)[nsfHeaderSize:],
+		}, false},
+		{"3", newTestNSF(3)[:0x40], nil, true},
+		{"4", append(newTestNSFe()[:len(newTestNSFe())-8], 0x00, 0x00, 0x00, 0x00, 'N', 'E', 'W', 'S'), nil, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := NewNSF(tt.data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_NSFPlayer_InitAndPlay(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		song     int
+		duration time.Duration
+		wantPlay []byte
+	}{
+		{"1", 1, 100 * time.Millisecond, []byte{5, 6}},
+		{"2", 3, 500 * time.Millisecond, []byte{29, 30}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			nsf, err := NewNSF(newTestNSF(3))
+			assert.NoError(t, err)
+			p := NewNSFPlayer(nsf, &fakePlayer{})
+			p.SelectSong(tt.song)
+			p.RunFor(tt.duration)
+			assert.Equal(t, tt.song, p.Song())
+			assert.Equal(t, byte(tt.song-1), p.mapper.Read(0x6000))
+			assert.Contains(t, tt.wantPlay, p.mapper.Read(0x6001))
+		})
+	}
+}
+
+func Test_NSFMapper_OpenBus(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		addr uint16
+		want byte
+	}{
+		{"1", 0x4020, 0x5A},
+		{"2", 0x5FFF, 0x5A},
+		// PRG RAM
+		{"3", 0x6000, 0x00},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			nsf, err := NewNSF(newTestNSF(1))
+			assert.NoError(t, err)
+			p := NewNSFPlayer(nsf, &fakePlayer{})
+			p.nes.bus.openBus = 0x5A
+			assert.Equal(t, tt.want, p.mapper.Read(tt.addr))
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package nes
+
+import "math"
+
+// https://www.nesdev.org/wiki/VRC7_audio
+// > The VRC7 contains a YM2413 derivative FM synthesizer with 6 channels of 2-operator FM synthesis.
+// The synthesizer runs at 3.58MHz / 72 = 49.7kHz, that is exactly 36 CPU cycles per sample on NTSC.
+const opllClockDivider = 36
+
+// https://www.nesdev.org/wiki/VRC7_instruments
+// The built-in instrument patches dumped by Nuke.YKT. Patch 0 is the user defined instrument ($00-$07).
+var vrc7Patches = [16][8]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // custom
+	{0x03, 0x21, 0x05, 0x06, 0xE8, 0x81, 0x42, 0x27}, // Buzzy Bell
+	{0x13, 0x41, 0x14, 0x0D, 0xD8, 0xF6, 0x23, 0x12}, // Guitar
+	{0x11, 0x11, 0x08, 0x08, 0xFA, 0xB2, 0x20, 0x12}, // Wurly
+	{0x31, 0x61, 0x0C, 0x07, 0xA8, 0x64, 0x61, 0x27}, // Flute
+	{0x32, 0x21, 0x1E, 0x06, 0xE1, 0x76, 0x01, 0x28}, // Clarinet
+	{0x02, 0x01, 0x06, 0x00, 0xA3, 0xE2, 0xF4, 0xF4}, // Synth
+	{0x21, 0x61, 0x1D, 0x07, 0x82, 0x81, 0x11, 0x07}, // Trumpet
+	{0x23, 0x21, 0x22, 0x17, 0xA2, 0x72, 0x01, 0x17}, // Organ
+	{0x35, 0x11, 0x25, 0x00, 0x40, 0x73, 0x72, 0x01}, // Bells
+	{0xB5, 0x01, 0x0F, 0x0F, 0xA8, 0xA5, 0x51, 0x02}, // Vibes
+	{0x17, 0xC1, 0x24, 0x07, 0xF8, 0xF8, 0x22, 0x12}, // Vibraphone
+	{0x71, 0x23, 0x11, 0x06, 0x65, 0x74, 0x18, 0x16}, // Tutti
+	{0x01, 0x02, 0xD3, 0x05, 0xC9, 0x95, 0x03, 0x02}, // Fretless
+	{0x61, 0x63, 0x0C, 0x00, 0x94, 0xC0, 0x33, 0xF6}, // Synth Bass
+	{0x21, 0x72, 0x0D, 0x00, 0xC1, 0xD5, 0x56, 0x06}, // Sweep
+}
+
+// All attenuations are handled in the log domain, the unit is 1/256 of log2 (= about 0.0235dB) like the real chip.
+// e.g. the envelope has 0.375dB steps, so that is 16 units.
+const (
+	opllEnvelopeShift    = 4 // 0.375dB
+	opllTotalLevelShift  = 5 // 0.75dB
+	opllMaxEnvelope      = 127
+	opllMaxAttenuation   = 12 * 256
+	opllPhaseBits        = 19
+	opllPhaseOutputShift = opllPhaseBits - 10
+)
+
+var (
+	// quarter of a sine wave
+	opllLogSinTable [256]int
+	opllExpTable    [256]int
+	// indexed by [block][fnum >> 5]
+	opllKSLTable [8][16]int
+)
+
+// The frequency multiplier, doubled so that x1/2 can be expressed
+var opllMultTable = [16]int{1, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 20, 24, 24, 30, 30}
+
+// The envelope increment patterns for the lower 2 bits of the rate
+var opllEGPatterns = [4][8]int{
+	{0, 1, 0, 1, 0, 1, 0, 1},
+	{0, 1, 0, 1, 1, 1, 0, 1},
+	{0, 1, 1, 1, 0, 1, 1, 1},
+	{0, 1, 1, 1, 1, 1, 1, 1},
+}
+
+// vibrato, applied to fnum as pm * fnum / 256
+var opllPMTable = [8]int{0, 1, 2, 1, 0, -1, -2, -1}
+
+func init() {
+	for i := 0; i < 256; i++ {
+		s := math.Sin((float64(i) + 0.5) * math.Pi / 512)
+		opllLogSinTable[i] = int(math.Round(-math.Log2(s) * 256))
+		opllExpTable[i] = int(math.Round(math.Pow(2, float64(255-i)/256) * 1024))
+	}
+	// The key scale level attenuation in dB at block 7, it decreases 3dB per block
+	ksl := [16]float64{
+		0.000, 9.000, 12.000, 13.875, 15.000, 16.125, 16.875, 17.625,
+		18.000, 18.750, 19.125, 19.500, 19.875, 20.250, 20.625, 21.000,
+	}
+	for block := 0; block < 8; block++ {
+		for f := 0; f < 16; f++ {
+			db := ksl[f] - 3*float64(7-block)
+			if db < 0 {
+				db = 0
+			}
+			opllKSLTable[block][f] = int(math.Round(db * 256 / (20 * math.Log10(2))))
+		}
+	}
+}
+
+// opllExp converts the attenuation to the linear amplitude (0 to 2042)
+func opllExp(att int) int {
+	if att >= opllMaxAttenuation {
+		return 0
+	}
+	return opllExpTable[att&0xFF] >> (att >> 8)
+}
+
+type opllEnvelopeState byte
+
+const (
+	opllEnvelopeAttack opllEnvelopeState = iota
+	opllEnvelopeDecay
+	opllEnvelopeSustain
+	opllEnvelopeRelease
+)
+
+// opllOperatorPatch is the half of a patch for one operator (modulator or carrier)
+type opllOperatorPatch struct {
+	am       bool // tremolo
+	vib      bool // vibrato
+	sustain  bool // EG type: true is sustained tone, false is percussive tone
+	ksr      bool // key scale rate
+	mult     byte
+	ksl      byte
+	halfSine bool // rectified sine wave
+	ar       byte
+	dr       byte
+	sl       byte
+	rr       byte
+}
+
+type opllPatch struct {
+	op         [2]opllOperatorPatch // 0: modulator, 1: carrier
+	totalLevel byte                 // modulator only, the carrier uses the channel volume instead
+	feedback   byte                 // modulator only
+}
+
+/*
+newOPLLPatch parses the 8 bytes instrument data
+
+	$00/$01: modulator/carrier    TVSK MMMM   tremolo (T), vibrato (V), sustained (S), key scale rate (K), multiplier (M)
+	$02:     modulator            KKOO OOOO   key scale level (K), total level (O)
+	$03:     carrier/modulator    KK-Q WFFF   carrier key scale level (K), carrier wave (Q), modulator wave (W), feedback (F)
+	$04/$05: modulator/carrier    AAAA DDDD   attack (A), decay (D)
+	$06/$07: modulator/carrier    SSSS RRRR   sustain level (S), release (R)
+*/
+func newOPLLPatch(b [8]byte) opllPatch {
+	p := opllPatch{}
+	for i := 0; i < 2; i++ {
+		p.op[i].am = (b[i] & 0x80) == 0x80
+		p.op[i].vib = (b[i] & 0x40) == 0x40
+		p.op[i].sustain = (b[i] & 0x20) == 0x20
+		p.op[i].ksr = (b[i] & 0x10) == 0x10
+		p.op[i].mult = b[i] & 0x0F
+		p.op[i].ar = b[4+i] >> 4
+		p.op[i].dr = b[4+i] & 0x0F
+		p.op[i].sl = b[6+i] >> 4
+		p.op[i].rr = b[6+i] & 0x0F
+	}
+	p.op[0].ksl = b[2] >> 6
+	p.op[1].ksl = b[3] >> 6
+	p.op[1].halfSine = (b[3] & 0x10) == 0x10
+	p.op[0].halfSine = (b[3] & 0x08) == 0x08
+	p.totalLevel = b[2] & 0x3F
+	p.feedback = b[3] & 0x07
+	return p
+}
+
+type opllOperator struct {
+	phase    int // opllPhaseBits bits
+	envelope int // 0 (max) to 127 (silence)
+	state    opllEnvelopeState
+	output   int
+	prevOut  int // for feedback
+}
+
+type opllChannel struct {
+	fnum       uint16 // 9 bits
+	block      byte   // 3 bits
+	sustain    bool
+	keyOn      bool
+	instrument byte
+	volume     byte
+
+	mod opllOperator
+	car opllOperator
+}
+
+type opll struct {
+	address     byte
+	customPatch [8]byte
+	patches     [16]opllPatch
+	channels    [6]opllChannel
+
+	clock     int // CPU cycles
+	egCounter int
+	amCounter int
+	pmCounter int
+	output    int
+}
+
+func newOPLL() *opll {
+	o := &opll{}
+	for i := range vrc7Patches {
+		o.patches[i] = newOPLLPatch(vrc7Patches[i])
+	}
+	o.reset()
+	return o
+}
+
+func (o *opll) reset() {
+	o.address = 0
+	o.customPatch = [8]byte{}
+	o.patches[0] = newOPLLPatch(o.customPatch)
+	for i := range o.channels {
+		o.channels[i] = opllChannel{}
+		o.channels[i].mod.envelope = opllMaxEnvelope
+		o.channels[i].car.envelope = opllMaxEnvelope
+		o.channels[i].mod.state = opllEnvelopeRelease
+		o.channels[i].car.state = opllEnvelopeRelease
+	}
+	o.egCounter = 0
+	o.amCounter = 0
+	o.pmCounter = 0
+	o.output = 0
+}
+
+// $9010
+func (o *opll) writeAddress(val byte) {
+	o.address = val
+}
+
+// $9030
+func (o *opll) writeData(val byte) {
+	a := o.address
+	switch {
+	case a <= 0x07:
+		o.customPatch[a] = val
+		o.patches[0] = newOPLLPatch(o.customPatch)
+	case 0x10 <= a && a <= 0x15:
+		// FFFF FFFF  fnum low 8 bits (F)
+		ch := &o.channels[a-0x10]
+		ch.fnum = (ch.fnum & 0x100) | uint16(val)
+	case 0x20 <= a && a <= 0x25:
+		// --SK OOOF  sustain (S), key on (K), octave (O), fnum high bit (F)
+		ch := &o.channels[a-0x20]
+		ch.fnum = (ch.fnum & 0xFF) | (uint16(val&0x01) << 8)
+		ch.block = (val >> 1) & 0x07
+		ch.sustain = (val & 0x20) == 0x20
+		keyOn := (val & 0x10) == 0x10
+		if keyOn && !ch.keyOn {
+			ch.mod.keyOn()
+			ch.car.keyOn()
+		} else if !keyOn && ch.keyOn {
+			ch.mod.keyOff()
+			ch.car.keyOff()
+		}
+		ch.keyOn = keyOn
+	case 0x30 <= a && a <= 0x35:
+		// IIII VVVV  instrument (I), volume (V)
+		ch := &o.channels[a-0x30]
+		ch.instrument = val >> 4
+		ch.volume = val & 0x0F
+	default:
+		// VRC7 doesn't have the channel 6-8 and the rhythm mode
+	}
+}
+
+func (op *opllOperator) keyOn() {
+	op.state = opllEnvelopeAttack
+	op.phase = 0
+}
+
+func (op *opllOperator) keyOff() {
+	op.state = opllEnvelopeRelease
+}
+
+// tick is called every CPU cycle
+func (o *opll) tick() {
+	o.clock++
+	if o.clock < opllClockDivider {
+		return
+	}
+	o.clock = 0
+	o.clockSample()
+}
+
+// clockSample generates one sample (49.7kHz)
+func (o *opll) clockSample() {
+	o.egCounter++
+	o.amCounter++
+	o.pmCounter++
+
+	// tremolo: 3.7Hz triangle wave, depth 4.875dB (0 to 13 envelope steps)
+	amStep := (o.amCounter >> 9) % 26
+	if amStep > 13 {
+		amStep = 26 - amStep
+	}
+	am := amStep << opllEnvelopeShift
+	// vibrato: 6.1Hz
+	pm := opllPMTable[(o.pmCounter>>10)&7]
+
+	out := 0
+	for i := range o.channels {
+		out += o.channels[i].clock(&o.patches[o.channels[i].instrument], o.egCounter, am, pm)
+	}
+	o.output = out
+}
+
+// rate returns the effective envelope rate (0 to 63)
+func (ch *opllChannel) rate(p *opllOperatorPatch, r byte) int {
+	if r == 0 {
+		return 0
+	}
+	kcode := int(ch.block)<<1 | int(ch.fnum>>8)
+	if !p.ksr {
+		kcode >>= 2
+	}
+	rate := int(r)*4 + kcode
+	if rate > 63 {
+		rate = 63
+	}
+	return rate
+}
+
+func opllEnvelopeIncrement(rate int, counter int) int {
+	if rate == 0 {
+		return 0
+	}
+	hi := rate >> 2
+	lo := rate & 3
+	if hi < 13 {
+		shift := 13 - hi
+		if counter&((1<<shift)-1) != 0 {
+			return 0
+		}
+		return opllEGPatterns[lo][(counter>>shift)&7]
+	}
+	return opllEGPatterns[lo][counter&7] << (hi - 12)
+}
+
+func (ch *opllChannel) tickEnvelope(op *opllOperator, p *opllOperatorPatch, counter int) {
+	switch op.state {
+	case opllEnvelopeAttack:
+		rate := ch.rate(p, p.ar)
+		if rate >= 60 {
+			op.envelope = 0
+		} else if inc := opllEnvelopeIncrement(rate, counter); inc > 0 {
+			op.envelope += (^op.envelope * inc) >> 3
+		}
+		if op.envelope <= 0 {
+			op.envelope = 0
+			op.state = opllEnvelopeDecay
+		}
+	case opllEnvelopeDecay:
+		op.envelope += opllEnvelopeIncrement(ch.rate(p, p.dr), counter)
+		// SL has 3dB steps, that is 8 envelope steps
+		sl := int(p.sl) << 3
+		if p.sl == 15 {
+			sl = opllMaxEnvelope
+		}
+		if op.envelope >= sl {
+			op.state = opllEnvelopeSustain
+		}
+	case opllEnvelopeSustain:
+		// The sustained tone holds the level until key off, but the percussive tone keeps decaying
+		if !p.sustain {
+			op.envelope += opllEnvelopeIncrement(ch.rate(p, p.rr), counter)
+		}
+	case opllEnvelopeRelease:
+		var r byte
+		if ch.sustain {
+			r = 5
+		} else if p.sustain {
+			r = p.rr
+		} else {
+			r = 7
+		}
+		op.envelope += opllEnvelopeIncrement(ch.rate(p, r), counter)
+	}
+	if op.envelope > opllMaxEnvelope {
+		op.envelope = opllMaxEnvelope
+	}
+}
+
+func (ch *opllChannel) phaseIncrement(p *opllOperatorPatch, pm int) int {
+	fnum := int(ch.fnum)
+	if p.vib {
+		fnum += (pm * fnum) >> 8
+	}
+	return ((fnum << ch.block) * opllMultTable[p.mult]) >> 1
+}
+
+func (ch *opllChannel) attenuation(op *opllOperator, p *opllOperatorPatch, totalLevel byte, am int) int {
+	att := op.envelope<<opllEnvelopeShift + int(totalLevel)<<opllTotalLevelShift
+	if p.ksl > 0 {
+		// KSL 1: 1.5dB/oct, 2: 3dB/oct, 3: 6dB/oct
+		ksl := opllKSLTable[ch.block][ch.fnum>>5]
+		att += (ksl << (p.ksl - 1)) >> 1
+	}
+	if p.am {
+		att += am
+	}
+	return att
+}
+
+// operatorOutput returns the signed amplitude of the operator (-2042 to 2042)
+func opllOperatorOutput(phase int, att int, halfSine bool) int {
+	phase &= 0x3FF
+	if halfSine && (phase&0x200) != 0 {
+		return 0
+	}
+	idx := phase & 0xFF
+	if (phase & 0x100) != 0 {
+		idx = 0xFF - idx
+	}
+	v := opllExp(opllLogSinTable[idx] + att)
+	if (phase & 0x200) != 0 {
+		return -v
+	}
+	return v
+}
+
+// clock returns the channel output (-2042 to 2042)
+func (ch *opllChannel) clock(patch *opllPatch, egCounter int, am int, pm int) int {
+	mp := &patch.op[0]
+	cp := &patch.op[1]
+
+	ch.tickEnvelope(&ch.mod, mp, egCounter)
+	ch.tickEnvelope(&ch.car, cp, egCounter)
+
+	ch.mod.phase = (ch.mod.phase + ch.phaseIncrement(mp, pm)) & ((1 << opllPhaseBits) - 1)
+	ch.car.phase = (ch.car.phase + ch.phaseIncrement(cp, pm)) & ((1 << opllPhaseBits) - 1)
+
+	// modulator with self feedback
+	fb := 0
+	if patch.feedback > 0 {
+		fb = (ch.mod.output + ch.mod.prevOut) >> (8 - patch.feedback)
+	}
+	ch.mod.prevOut = ch.mod.output
+	ch.mod.output = opllOperatorOutput(
+		ch.mod.phase>>opllPhaseOutputShift+fb,
+		ch.attenuation(&ch.mod, mp, patch.totalLevel, am),
+		mp.halfSine,
+	)
+
+	// carrier, modulated by the modulator output
+	ch.car.output = opllOperatorOutput(
+		ch.car.phase>>opllPhaseOutputShift+ch.mod.output,
+		ch.attenuation(&ch.car, cp, ch.volume<<2, am),
+		cp.halfSine,
+	)
+	return ch.car.output
+}
+
+// sample returns the latest sample in the range of -1.0 to 1.0
+func (o *opll) sample() float32 {
+	return float32(o.output) / (6 * 2048)
+}
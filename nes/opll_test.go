@@ -0,0 +1,154 @@
+package nes
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeOPLL(o *opll, regs [][2]byte) {
+	for _, r := range regs {
+		o.writeAddress(r[0])
+		o.writeData(r[1])
+	}
+}
+
+func Test_OPLL_NewPatch(t *testing.T) {
+	t.Parallel()
+	// Buzzy Bell
+	got := newOPLLPatch(vrc7Patches[1])
+	want := opllPatch{
+		op: [2]opllOperatorPatch{
+			{mult: 3, ksl: 0, ar: 0xE, dr: 0x8, sl: 0x4, rr: 0x2},
+			{sustain: true, mult: 1, ksl: 0, ar: 0x8, dr: 0x1, sl: 0x2, rr: 0x7},
+		},
+		totalLevel: 0x05,
+		feedback:   0x06,
+	}
+	assert.Equal(t, want, got)
+}
+
+func Test_OPLL_WriteData(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		regs [][2]byte
+		ch   int
+		want opllChannel
+	}{
+		{
+			"fnum low",
+			[][2]byte{{0x12, 0xAB}},
+			2,
+			opllChannel{fnum: 0xAB},
+		},
+		{
+			"fnum high, block, key on, sustain",
+			[][2]byte{{0x15, 0xAB}, {0x25, 0x3B}},
+			5,
+			opllChannel{fnum: 0x1AB, block: 5, sustain: true, keyOn: true},
+		},
+		{
+			"instrument and volume",
+			[][2]byte{{0x30, 0x7C}},
+			0,
+			opllChannel{instrument: 7, volume: 0x0C},
+		},
+		{
+			"channel 6-8 don't exist",
+			[][2]byte{{0x16, 0xFF}, {0x26, 0xFF}, {0x36, 0xFF}},
+			0,
+			opllChannel{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			o := newOPLL()
+			writeOPLL(o, tt.regs)
+			got := o.channels[tt.ch]
+			assert.Equal(t, tt.want.fnum, got.fnum)
+			assert.Equal(t, tt.want.block, got.block)
+			assert.Equal(t, tt.want.sustain, got.sustain)
+			assert.Equal(t, tt.want.keyOn, got.keyOn)
+			assert.Equal(t, tt.want.instrument, got.instrument)
+			assert.Equal(t, tt.want.volume, got.volume)
+		})
+	}
+}
+
+func Test_OPLL_PhaseIncrement(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		fnum  uint16
+		block byte
+		mult  byte
+		want  int
+	}{
+		{"x1/2", 0x100, 4, 0, 2048},
+		{"x1", 0x100, 4, 1, 4096},
+		{"x2", 0x100, 4, 2, 8192},
+		{"x10 = x11", 0x100, 0, 11, 2560},
+		{"max", 0x1FF, 7, 15, 0x1FF << 7 * 15},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ch := &opllChannel{fnum: tt.fnum, block: tt.block}
+			got := ch.phaseIncrement(&opllOperatorPatch{mult: tt.mult}, 0)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_OPLL_SineOutput(t *testing.T) {
+	t.Parallel()
+	o := newOPLL()
+	writeOPLL(o, [][2]byte{
+		// custom patch: the modulator is silent (AR=0, TL=63), the carrier is a sine wave with the fastest attack
+		{0x00, 0x20}, {0x01, 0x21}, {0x02, 0x3F}, {0x03, 0x00},
+		{0x04, 0x00}, {0x05, 0xF0}, {0x06, 0x0F}, {0x07, 0x0F},
+		// custom instrument, max volume
+		{0x30, 0x00},
+		// fnum = 0x100, block = 4, key on: 8/1024 cycle per sample
+		{0x10, 0x00}, {0x20, 0x19},
+	})
+
+	want := []int{106, 206, 306, 404, 502, 599, 693, 787, 880, 967, 1055, 1141}
+	for i := range want {
+		o.clockSample()
+		assert.Equal(t, want[i], o.output)
+	}
+	for i := len(want); i < 256; i++ {
+		o.clockSample()
+		// compare with the ideal sine wave
+		ideal := 2042 * math.Sin(2*math.Pi*float64(8*(i+1))/1024)
+		assert.InDelta(t, ideal, float64(o.output), 10)
+	}
+
+	// key off
+	writeOPLL(o, [][2]byte{{0x20, 0x09}})
+	for i := 0; i < 200; i++ {
+		o.clockSample()
+	}
+	assert.Equal(t, opllMaxEnvelope, o.channels[0].car.envelope)
+	assert.InDelta(t, 0, o.output, 16)
+}
+
+func Test_OPLL_Tick(t *testing.T) {
+	t.Parallel()
+	o := newOPLL()
+	writeOPLL(o, [][2]byte{{0x30, 0x10}, {0x10, 0x80}, {0x20, 0x19}})
+	for i := 0; i < opllClockDivider-1; i++ {
+		o.tick()
+	}
+	assert.Equal(t, 0, o.egCounter)
+	o.tick()
+	assert.Equal(t, 1, o.egCounter)
+}
@@ -0,0 +1,39 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+)
+
+// PatchExtensions are the file extensions of the supported patches
+var PatchExtensions = []string{".ips", ".bps", ".ups"}
+
+// ApplyPatch returns the data patched by the IPS, BPS or UPS patch, detected by the magic bytes.
+// data is not modified.
+func ApplyPatch(data, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, ipsHeader):
+		return ApplyIPS(data, patch)
+	case bytes.HasPrefix(patch, bpsHeader):
+		return ApplyBPS(data, patch)
+	case bytes.HasPrefix(patch, upsHeader):
+		return ApplyUPS(data, patch)
+	default:
+		return nil, errors.New("unknown patch format")
+	}
+}
+
+// ApplyPatch patches the ROM in memory, the file type is detected again
+func (f *ROMFile) ApplyPatch(patch []byte) error {
+	data, err := ApplyPatch(f.Data, patch)
+	if err != nil {
+		return err
+	}
+	t := DetectFileType(data)
+	if t == FileTypeUnknown {
+		return errors.New("the patched rom is an unknown file type")
+	}
+	f.Data = data
+	f.Type = t
+	return nil
+}
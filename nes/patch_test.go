@@ -0,0 +1,91 @@
+package nes
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// appendPatchNumber encodes the variable-length number of BPS and UPS
+func appendPatchNumber(b []byte, n int) []byte {
+	for {
+		x := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(b, x|0x80)
+		}
+		b = append(b, x)
+		n--
+	}
+}
+
+func appendPatchFooter(b, source, target []byte) []byte {
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(source))
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+}
+
+func Test_ApplyPatch(t *testing.T) {
+	t.Parallel()
+	source := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	// 0,1,2,3 from source, 9,8 as is, 9,8,9,8 repeated from target, 6,7 copied from source
+	target := []byte{0, 1, 2, 3, 9, 8, 9, 8, 9, 8, 6, 7}
+
+	bps := append([]byte("BPS1"), appendPatchNumber(nil, len(source))...)
+	bps = appendPatchNumber(bps, len(target))
+	bps = appendPatchNumber(bps, 0)
+	bps = appendPatchNumber(bps, (4-1)<<2|bpsSourceRead)
+	bps = appendPatchNumber(bps, (2-1)<<2|bpsTargetRead)
+	bps = append(bps, 9, 8)
+	bps = appendPatchNumber(bps, (4-1)<<2|bpsTargetCopy)
+	bps = appendPatchNumber(bps, 4<<1)
+	bps = appendPatchNumber(bps, (2-1)<<2|bpsSourceCopy)
+	bps = appendPatchNumber(bps, 6<<1)
+	brokenBPS := appendPatchFooter(append([]byte{}, bps...), source, source)
+	bps = appendPatchFooter(bps, source, target)
+
+	// XOR from offset 4 to 11
+	ups := append([]byte("UPS1"), appendPatchNumber(nil, len(source))...)
+	ups = appendPatchNumber(ups, len(target))
+	ups = appendPatchNumber(ups, 4)
+	ups = append(ups, 4^9, 5^8, 6^9, 7^8, 9, 8, 6, 7, 0)
+	ups = appendPatchFooter(ups, source, target)
+
+	ipsRLE := append([]byte("PATCH"), 0, 0, 1, 0, 0, 0, 3, 0xAA)
+	ipsRLE = append(ipsRLE, "EOF"...)
+	ipsTruncate := append([]byte("PATCH"), 0, 0, 0, 0, 1, 0xBB)
+	ipsTruncate = append(ipsTruncate, "EOF"...)
+	ipsTruncate = append(ipsTruncate, 0, 0, 4)
+
+	tests := []struct {
+		name    string
+		patch   []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"1", bps, target, false},
+		{"2", ups, target, false},
+		{"3", ipsRLE, []byte{0, 0xAA, 0xAA, 0xAA, 4, 5, 6, 7}, false},
+		{"4", ipsTruncate, []byte{0xBB, 1, 2, 3}, false},
+		{"5", brokenBPS, nil, true},
+		{"6", bps[:len(bps)-1], nil, true},
+		{"7", []byte("unknown"), nil, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ApplyPatch(source, tt.patch)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, []byte{0, 1, 2, 3, 4, 5, 6, 7}, source)
+		})
+	}
+}
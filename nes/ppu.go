@@ -61,6 +61,12 @@ func (m *ppuRAM) mirrorAddr(addr uint16) uint16 {
 		default:
 			panic(fmt.Sprintf("unexpected addr 0x%04X in vram.mirrorAddr", addr))
 		}
+	} else if m.mirroring.IsSingleScreenLow() {
+		// All nametables refer to the first 1 KiB of VRAM
+		return (addr - 0x2000) % 0x400
+	} else if m.mirroring.IsSingleScreenHigh() {
+		// All nametables refer to the second 1 KiB of VRAM
+		return 0x400 + (addr-0x2000)%0x400
 	} else {
 		panic(fmt.Sprintf("unimplemented ppu mirroing addr type: %d", m.mirroring))
 	}
@@ -74,27 +80,71 @@ func (m *ppuRAM) write(addr uint16, val byte) {
 	m.ram[m.mirrorAddr(addr)] = val
 }
 
+// ppuFetchKind tells what the PPU is reading on the video memory bus
+type ppuFetchKind byte
+
+const (
+	// ppuFetchCPU is the access through PPUDATA ($2007)
+	ppuFetchCPU ppuFetchKind = iota
+	ppuFetchBGNametable
+	ppuFetchBGAttribute
+	ppuFetchBGPattern
+	// ppuFetchSpriteNametable is the garbage nametable fetch during the sprite fetches (dots 257-320)
+	ppuFetchSpriteNametable
+	ppuFetchSpritePattern
+)
+
 type ppuBus struct {
 	ram    *ppuRAM
 	mapper Mapper
+	// nametableMapper is nil if the nametables follow the mirroring
+	nametableMapper nametableMapper
+	// fetchMapper is nil if the mapper doesn't snoop the PPU reads
+	fetchMapper ppuFetchMapper
+	fetchKind   ppuFetchKind
 	// https://www.nesdev.org/wiki/Open_bus_behavior#PPU_open_bus
 	// > The PPU has two data buses: the I/O bus, used to communicate with the CPU, and the video memory bus.
 	// This is the video memory bus variable
 	openbus uint16
 }
 
+// Some mappers switch the nametable mirroring at runtime, so follow the current one of the cartridge.
+func (bus *ppuBus) syncMirroring() {
+	bus.ram.mirroring = bus.mapper.MirroingType()
+}
+
+func (bus *ppuBus) readNametable(addr uint16) byte {
+	if bus.nametableMapper != nil {
+		return bus.nametableMapper.readNametable(addr)
+	}
+	bus.syncMirroring()
+	return bus.ram.read(addr)
+}
+
+func (bus *ppuBus) writeNametable(addr uint16, val byte) {
+	if bus.nametableMapper != nil {
+		bus.nametableMapper.writeNametable(addr, val)
+		return
+	}
+	bus.syncMirroring()
+	bus.ram.write(addr, val)
+}
+
 func (bus *ppuBus) read(addr uint16) byte {
+	if bus.fetchMapper != nil {
+		bus.fetchMapper.notifyPPURead(addr, bus.fetchKind)
+	}
 	res := byte(0)
 	switch {
 	case 0x0000 <= addr && addr <= 0x1FFF:
 		res = bus.mapper.Read(addr)
 	case 0x2000 <= addr && addr <= 0x2FFF:
-		res = bus.ram.read(addr)
+		res = bus.readNametable(addr)
 	case 0x3000 <= addr && addr <= 0x3FFF:
 		// Mirrors of $2000-$2FFF
 		// ref: https://www.nesdev.org/wiki/PPU_registers#The_PPUDATA_read_buffer_(post-fetch)
 		// > Simultaneously, the PPU also performs a normal read from the PPU memory at the specified address, "underneath" the palette data,
-		res = bus.ram.read(addr - 0x1000)
+		res = bus.readNametable(addr - 0x1000)
 	default:
 		panic(fmt.Sprintf("read ppubus invalid addr = 0x%04x", addr))
 	}
@@ -107,10 +157,10 @@ func (bus *ppuBus) write(addr uint16, val byte) {
 	case 0x0000 <= addr && addr <= 0x1FFF:
 		bus.mapper.Write(addr, val)
 	case 0x2000 <= addr && addr <= 0x2FFF:
-		bus.ram.write(addr, val)
+		bus.writeNametable(addr, val)
 	case 0x3000 <= addr && addr <= 0x3EFF:
 		// Mirrors of $2000-$2EFF
-		bus.ram.write(addr-0x1000, val)
+		bus.writeNametable(addr-0x1000, val)
 	case 0x3F00 <= addr && addr <= 0x3FFF:
 		// nothing
 		// https://www.nesdev.org/wiki/PPU_pinout
@@ -139,6 +189,24 @@ type ppu struct {
 	renderer   Renderer
 	oddFrame   byte // even/odd frame flag (1 bit)
 
+	// renderingEnabled is the rendering flag seen by the rendering pipeline.
+	// It follows PPUMASK a dot late, so that a $2001 write toggles rendering a few dots after the write.
+	// The odd frame skip and the rendering on/off in the middle of the scanline depend on this delay.
+	renderingEnabled bool
+
+	// $2006 updates v a few dots after the second write
+	vramAddrDelay int
+	pendingV      uint16
+	// outputPaletteAddr is the palette address of the last rendered pixel
+	outputPaletteAddr paletteAddr
+
+	// warmUp is true if the PPU ignores the writes for a while after the power-up
+	warmUp bool
+	// warmingUp is true until the end of the first vblank after the power-up, or the reset of the NES
+	warmingUp bool
+	// resetModel is how the reset button reaches the PPU
+	resetModel PPUReset
+
 	// https://www.nesdev.org/wiki/Open_bus_behavior#PPU_open_bus
 	// > The PPU has two data buses: the I/O bus, used to communicate with the CPU, and the video memory bus.
 	// This is the I/O bus variable
@@ -162,6 +230,12 @@ type ppu struct {
 	oamMIdx               int
 	copySpriteStateCycle  int
 
+	quirks           PPUQuirks
+	oamCorrupted     bool
+	oamCorruptedRows [32]bool
+	// oamRowClock is the clock of the last access to each OAM row for the decay
+	oamRowClock [32]int
+
 	// background temp variables
 	nameTableByte           byte
 	bgPaletteNumber         byte
@@ -181,6 +255,8 @@ type ppu struct {
 	nmiLine *nmiInterruptLine
 
 	clock int
+	// dataReadClock is the clock of the last $2007 read that took effect
+	dataReadClock int
 }
 
 func (ppu *ppu) FetchVBlankStarted() bool {
@@ -203,6 +279,39 @@ var powerupPaletteRAM = [32]byte{
 	0x08, 0x3A, 0x00, 0x02, 0x00, 0x20, 0x2C, 0x08,
 }
 
+// PPUReset is how the reset button reaches the PPU.
+// https://www.nesdev.org/wiki/PPU_power_up_state
+type PPUReset int
+
+const (
+	// PPUResetNES resets the PPU with the CPU, as the reset line of the NES front-loader is connected to the PPU.
+	// The registers are cleared and the warm-up starts again.
+	PPUResetNES PPUReset = iota
+	// PPUResetFamicom resets only the CPU, as the reset button of the Famicom isn't connected to the PPU.
+	// The PPU keeps running with its registers.
+	PPUResetFamicom
+)
+
+// PPUResets are the names of the reset models, that ParsePPUReset accepts
+var PPUResets = []string{"nes", "famicom"}
+
+func (r PPUReset) String() string {
+	if 0 <= r && int(r) < len(PPUResets) {
+		return PPUResets[r]
+	}
+	return "unknown"
+}
+
+// ParsePPUReset returns the reset model by the name
+func ParsePPUReset(s string) (PPUReset, error) {
+	for i, name := range PPUResets {
+		if s == name {
+			return PPUReset(i), nil
+		}
+	}
+	return PPUResetNES, fmt.Errorf("unknown ppu reset: %s", s)
+}
+
 func newPPU(renderer Renderer, mapper Mapper, mirror MirroringType, nmiLine *nmiInterruptLine) *ppu {
 	ppu := &ppu{
 		bus: &ppuBus{
@@ -212,6 +321,16 @@ func newPPU(renderer Renderer, mapper Mapper, mirror MirroringType, nmiLine *nmi
 		cycle:    -1,
 		renderer: renderer,
 		nmiLine:  nmiLine,
+		quirks:   DefaultPPUQuirks,
+
+		dataReadClock: -ppuDataReadInterval,
+	}
+	if m, ok := mapper.(nametableMapper); ok {
+		ppu.bus.nametableMapper = m
+		m.connectVRAM(&ppu.bus.ram.ram)
+	}
+	if m, ok := mapper.(ppuFetchMapper); ok {
+		ppu.bus.fetchMapper = m
 	}
 
 	// init
@@ -224,22 +343,45 @@ func newPPU(renderer Renderer, mapper Mapper, mirror MirroringType, nmiLine *nmi
 	return ppu
 }
 
+// https://www.nesdev.org/wiki/PPU_power_up_state
 func (ppu *ppu) powerUp() {
 	_ = copy(ppu.paletteRAM[:], powerupPaletteRAM[:])
+	ppu.ctrl = ppuControlRegister(0)
 	ppu.status = ppuStatusRegister(0)
 	ppu.mask = ppuMaskRegister(0)
 	ppu.oamAddr = 0
 	ppu.w = false
+	ppu.t = 0
+	ppu.x = 0
+	ppu.v = 0
 	ppu.readBuffer = 0
 	ppu.oddFrame = 0
+	ppu.renderingEnabled = false
+	ppu.vramAddrDelay = 0
+	// https://www.nesdev.org/wiki/PPU_power_up_state
+	// The PPU ignores the writes to PPUCTRL, PPUMASK, PPUSCROLL and PPUADDR for about 29658 CPU cycles after the power-up.
+	// The internal reset signal is cleared at the end of the first vblank, that is the start of the pre-render line.
+	ppu.warmingUp = ppu.warmUp
 }
 
+// reset keeps PPUSTATUS, OAMADDR, PPUADDR and the memories.
+// On the NES, the warm-up starts again as well as the power-up.
+// On the Famicom, the PPU isn't reset at all.
 func (ppu *ppu) reset() {
-	ppu.status = ppuStatusRegister(0)
+	if ppu.resetModel == PPUResetFamicom {
+		return
+	}
+	ppu.ctrl = ppuControlRegister(0)
 	ppu.mask = ppuMaskRegister(0)
 	ppu.w = false
+	// PPUSCROLL is cleared, but v of PPUADDR is kept
+	ppu.t = 0
+	ppu.x = 0
 	ppu.readBuffer = 0
 	ppu.oddFrame = 0
+	ppu.renderingEnabled = false
+	ppu.vramAddrDelay = 0
+	ppu.warmingUp = ppu.warmUp
 }
 
 func (ppu *ppu) readData(addr uint16) (result byte, isPalette bool, busData byte) {
@@ -253,6 +395,14 @@ func (ppu *ppu) readData(addr uint16) (result byte, isPalette bool, busData byte
 	}
 }
 
+// fetch is the read for rendering, kind is passed to the mapper that snoops the PPU reads
+func (ppu *ppu) fetch(addr uint16, kind ppuFetchKind) byte {
+	ppu.bus.fetchKind = kind
+	b, _, _ := ppu.readData(addr)
+	ppu.bus.fetchKind = ppuFetchCPU
+	return b
+}
+
 func (ppu *ppu) writeData(addr uint16, val byte) {
 	addr &= 0x3FFF
 	ppu.bus.write(addr, val)
@@ -289,6 +439,13 @@ func (ppu *ppu) readRegister(addr uint16) byte {
 		// $2006 PPUADDR write only
 	case 7:
 		// $2007 PPUDATA
+		if ppu.clock-ppu.dataReadClock < ppuDataReadInterval {
+			// The read in the next CPU cycle is ignored, because the PPU is still busy with the previous one.
+			// e.g. the dummy read of LDA $2007,X, or the repeated reads of the CPU halted by DMC DMA.
+			// It returns the I/O bus, that holds the previous result.
+			break
+		}
+		ppu.dataReadClock = ppu.clock
 		// ppu_open_bus/readme.txt
 		// D = openbus bit
 		// DD-- ----   palette
@@ -336,6 +493,13 @@ func (ppu *ppu) peekRegister(addr uint16) byte {
 // writeRegister is called from CPU Memory Mapped I/O
 func (ppu *ppu) writeRegister(addr uint16, val byte) {
 	ppu.iobus.refresh(val, 0xFF, ppu.clock)
+	if ppu.warmingUp {
+		switch addr & 0x07 {
+		case 0, 1, 5, 6:
+			// the writes are ignored, and the toggle of PPUSCROLL and PPUADDR isn't changed either
+			return
+		}
+	}
 	switch addr & 0x07 {
 	case 0:
 		ppu.writeController(val)
@@ -377,6 +541,13 @@ func (ppu *ppu) writeController(val byte) {
 	// t: ...GH.. ........ <- d: ......GH
 	// <used elsewhere>    <- d: ABCDEF..
 	ppu.t = (ppu.t & 0xF3FF) | (uint16(val)&0x03)<<10
+
+	// https://www.nesdev.org/wiki/PPU_registers#PPUCTRL
+	// A write landing on dot 257 of a render line conflicts with the horizontal copy from t to v, and the nametable X bit of v is lost.
+	// The next scanline is drawn from the left nametable, until the copy on the next dot 257 restores the bit.
+	if ppu.cycle == 257 && ppu.isRenderLine() && ppu.isRenderingEnabled() {
+		ppu.v &^= 0x0400
+	}
 }
 
 // $2001: PPUMASK
@@ -415,6 +586,7 @@ func (ppu *ppu) writeOAMAddr(val byte) {
 
 // $2004: OAMDATA read
 func (ppu *ppu) readOAMData() byte {
+	ppu.accessOAMRow(ppu.oamAddr)
 	return ppu.primaryOAM[ppu.oamAddr]
 }
 
@@ -429,6 +601,7 @@ func (ppu *ppu) writeOAMData(val byte) {
 		if (ppu.oamAddr & 0x03) == 0x02 {
 			val &= 0xE3
 		}
+		ppu.accessOAMRow(ppu.oamAddr)
 		ppu.primaryOAM[ppu.oamAddr] = val
 		ppu.oamAddr++
 	} else {
@@ -484,11 +657,42 @@ func (ppu *ppu) writePPUAddr(val byte) {
 		// v: <...all bits...> <- t: <...all bits...>
 		// w:                  <- 0
 		ppu.t = (ppu.t & 0xFF00) | uint16(val)
-		ppu.v = ppu.t
+		// v is copied from t 3 dots later, the old v is still used by the rendering and $2007 until then
+		ppu.pendingV = ppu.t
+		ppu.vramAddrDelay = ppuAddrUpdateDelay
 		ppu.w = false
 	}
 }
 
+// ppuAddrUpdateDelay is the PPU cycles from the second $2006 write until v is updated
+const ppuAddrUpdateDelay = 3
+
+// updatePPUAddr copies the written address to v at the end of the delay
+func (ppu *ppu) updatePPUAddr() {
+	if !(ppu.isRenderLine() && ppu.isRenderingEnabled()) {
+		ppu.v = ppu.pendingV
+		return
+	}
+	// When the update lands on the dot incrementing v, the written address and the incremented v conflict,
+	// and the bits of the incremented scroll are ANDed with the written ones.
+	visibleCycle := ppu.cycle >= 1 && ppu.cycle <= 256
+	preFetchCycle := ppu.cycle >= 321 && ppu.cycle <= 336
+	switch {
+	case ppu.cycle == 256:
+		// coarse X and Y are incremented
+		ppu.v &= ppu.pendingV
+	case (visibleCycle || preFetchCycle) && ppu.cycle%8 == 0:
+		// coarse X is incremented
+		ppu.v = (ppu.pendingV &^ 0x041F) | (ppu.v & ppu.pendingV & 0x041F)
+	default:
+		ppu.v = ppu.pendingV
+	}
+}
+
+// ppuDataReadInterval is the PPU cycles that a $2007 read keeps the PPU busy.
+// It covers the read in the next CPU cycle, that comes 2 to 4 PPU cycles later depending on the phase of the read in the CPU cycle.
+const ppuDataReadInterval = 5
+
 // $2007: PPUDATA read
 func (ppu *ppu) readPPUData() (result byte, isPalette bool, busData byte) {
 	result, isPalette, busData = ppu.readData(ppu.v)
@@ -590,13 +794,21 @@ func (ppu *ppu) incrY() {
 func (ppu *ppu) fetchNT() {
 	v := ppu.v
 	addr := 0x2000 | (v & 0x0FFF)
-	ppu.nameTableByte, _, _ = ppu.readData(addr)
+	ppu.nameTableByte = ppu.fetch(addr, ppuFetchBGNametable)
+}
+
+// fetchGarbageNT is the unused nametable fetch, dots 337-340 and the first half of each sprite fetch in dots 257-320.
+// The result is not used by the PPU, but mappers can see them (e.g. MMC5 detects scanlines with them)
+func (ppu *ppu) fetchGarbageNT(kind ppuFetchKind) {
+	v := ppu.v
+	addr := 0x2000 | (v & 0x0FFF)
+	ppu.fetch(addr, kind)
 }
 
 func (ppu *ppu) fetchAT() {
 	v := ppu.v
 	addr := 0x23C0 | (v & 0x0C00) | ((v >> 4) & 0x38) | ((v >> 2) & 0x07)
-	b, _, _ := ppu.readData(addr)
+	b := ppu.fetch(addr, ppuFetchBGAttribute)
 	//
 	// b
 	// 7654 3210
@@ -620,17 +832,32 @@ func (ppu *ppu) fetchAT() {
 func (ppu *ppu) fetchBGLSBits() {
 	fineY := (ppu.v >> 12) & 7
 	addr := ppu.ctrl.bgPatternAddr() | uint16(ppu.nameTableByte)<<4 | fineY
-	ppu.bgPixelColorIndexLSBits, _, _ = ppu.readData(addr)
+	ppu.bgPixelColorIndexLSBits = ppu.fetch(addr, ppuFetchBGPattern)
 }
 
 func (ppu *ppu) fetchBGMSBits() {
 	fineY := (ppu.v >> 12) & 7
 	addr := ppu.ctrl.bgPatternAddr() | uint16(ppu.nameTableByte)<<4 | fineY
-	ppu.bgPixelColorIndexMSBits, _, _ = ppu.readData(addr + 8)
+	ppu.bgPixelColorIndexMSBits = ppu.fetch(addr+8, ppuFetchBGPattern)
+}
+
+// fetchDummySprite reads the pattern of tile $FF like the real PPU does for the empty sprite slots
+func (ppu *ppu) fetchDummySprite() {
+	addr := ppu.ctrl.spritePatternAddr() | 0xFF<<4
+	if ppu.ctrl.spriteSize() == 16 {
+		addr = 0x1000 | 0xFE<<4
+	}
+	ppu.fetch(addr, ppuFetchSpritePattern)
+	ppu.fetch(addr+8, ppuFetchSpritePattern)
 }
 
 func (ppu *ppu) fetchSpriteForNextScanline() {
 	// called cycle: 264, 272, ..., 320
+	if ppu.isPreLine() {
+		// The sprite fetches are performed on the pre-render line too, but the sprites aren't rendered on the first visible line
+		ppu.fetchDummySprite()
+		return
+	}
 	sidx := (ppu.cycle - 264) / 8
 	sy, stile, sattr, sx := getSpriteFromOAM(ppu.secondaryOAM[:], byte(sidx))
 	lo, hi, ok := func() (byte, byte, bool) {
@@ -640,14 +867,15 @@ func (ppu *ppu) fetchSpriteForNextScanline() {
 				// eval時点で範囲内しか見ない&0xFFで初期化されるが、初期化途中でsprite&bgともにdisableされて前回の状態が残ることがある
 				// eval時点だけでなくこのfetchタイミングでも範囲内か確認して少なくとも場外のspriteは表示させないようにしておく
 				// As a result of fixing another bug, maybe there is no problem now?
+				ppu.fetchDummySprite()
 				return 0, 0, false
 			}
 			if sattr.flipSpriteVertically() {
 				y = 7 - y
 			}
 			addr := ppu.ctrl.spritePatternAddr() | (uint16(stile) << 4) | y
-			lo, _, _ := ppu.readData(addr)
-			hi, _, _ := ppu.readData(addr + 8)
+			lo := ppu.fetch(addr, ppuFetchSpritePattern)
+			hi := ppu.fetch(addr+8, ppuFetchSpritePattern)
 
 			return lo, hi, true
 		} else {
@@ -658,6 +886,7 @@ func (ppu *ppu) fetchSpriteForNextScanline() {
 			tileIndex := uint16(stile) & 0b11111110
 			y := uint16(ppu.scanline) - uint16(sy)
 			if y > 15 {
+				ppu.fetchDummySprite()
 				return 0, 0, false
 			}
 			if sattr.flipSpriteVertically() {
@@ -668,8 +897,8 @@ func (ppu *ppu) fetchSpriteForNextScanline() {
 				y -= 8
 			}
 			addr := bankTile | tileIndex<<4 | y
-			lo, _, _ := ppu.readData(addr)
-			hi, _, _ := ppu.readData(addr + 8)
+			lo := ppu.fetch(addr, ppuFetchSpritePattern)
+			hi := ppu.fetch(addr+8, ppuFetchSpritePattern)
 
 			return lo, hi, true
 		}
@@ -705,6 +934,10 @@ func (ppu *ppu) evalSpriteForNextScanline() {
 		ppu.oamMIdx = 0
 		ppu.copySpriteStateCycle = -1
 		ppu.spriteEvaluationState = scanPrimaryOAMState
+		// The sprite evaluation reads OAM every scanline, so OAM is refreshed while rendering
+		for addr := 0; addr < len(ppu.primaryOAM); addr += 8 {
+			ppu.accessOAMRow(byte(addr))
+		}
 		fallthrough
 	case scanPrimaryOAMState:
 		if ppu.cycle%2 == 0 {
@@ -757,7 +990,11 @@ func (ppu *ppu) evalSpriteForNextScanline() {
 				ppu.spriteEvaluationState = doneSpriteEvaluationState
 			} else {
 				ppu.primaryOAMIndex++
-				ppu.oamMIdx = (ppu.oamMIdx + 1) % 4
+				if ppu.quirks.SpriteOverflowBug {
+					// https://www.nesdev.org/wiki/PPU_sprite_evaluation
+					// > If the value is not in range, increment n and m (without carry).
+					ppu.oamMIdx = (ppu.oamMIdx + 1) % 4
+				}
 				if ppu.primaryOAMIndex == 64 {
 					ppu.spriteEvaluationState = doneSpriteEvaluationState
 				}
@@ -781,7 +1018,7 @@ func (ppu *ppu) isPreLine() bool {
 }
 
 func (ppu *ppu) isRenderingEnabled() bool {
-	return ppu.mask.showBG() || ppu.mask.showSprites()
+	return ppu.renderingEnabled
 }
 
 func (ppu *ppu) loadNextBGPaletteData() {
@@ -858,6 +1095,17 @@ func (ppu *ppu) multiplexPaletteAddr(x int) paletteAddr {
 	}
 }
 
+// corruptPalette overwrites the palette entry that v points at with the color of the pixel being drawn.
+// When the rendering is disabled in the middle of the visible dots, the palette RAM switches its address
+// from the rendered pixel to v while the color is still on its data lines, and the color is written to the new address.
+// https://www.nesdev.org/wiki/PPU_registers
+func (ppu *ppu) corruptPalette() {
+	if (ppu.v&0x3F00) != 0x3F00 || !ppu.isVisibleScanlines() || ppu.cycle < 1 || ppu.cycle > 256 {
+		return
+	}
+	ppu.paletteRAM.write(paletteAddr(ppu.v), ppu.paletteRAM.read(ppu.outputPaletteAddr))
+}
+
 func (ppu *ppu) renderPixel() {
 	x := ppu.cycle - 1 // visibleCycle := ppu.Cycle >= 1 && ppu.Cycle <= 256
 	y := ppu.scanline
@@ -865,6 +1113,7 @@ func (ppu *ppu) renderPixel() {
 	var c color.Color
 	if ppu.isRenderingEnabled() {
 		addr := ppu.multiplexPaletteAddr(x)
+		ppu.outputPaletteAddr = addr
 		c = palette[ppu.paletteRAM.read(addr)%64]
 	} else {
 		// https://www.nesdev.org/wiki/PPU_rendering#Rendering_disabled
@@ -884,6 +1133,10 @@ func (ppu *ppu) renderPixel() {
 func (ppu *ppu) step() {
 	ppu.clock++
 
+	if ppu.oamCorrupted && ppu.isRenderingEnabled() && ppu.isRenderLine() {
+		ppu.corruptOAM()
+	}
+
 	if ppu.isRenderingEnabled() {
 		if ppu.oddFrame == 1 && ppu.isPreLine() && ppu.cycle == 339 {
 			// skip 1 cycle
@@ -936,6 +1189,12 @@ func (ppu *ppu) step() {
 			ppu.fetchBGMSBits()
 		}
 	}
+	// https://www.nesdev.org/wiki/PPU_rendering#Cycles_337-340
+	// > Two bytes are fetched, but the purpose for this is unknown. These fetches are 2 PPU cycles each.
+	// Do them on the first cycle of each, because the odd frame skips the last cycle of the pre-render line.
+	if ppu.isRenderingEnabled() && ppu.isRenderLine() && (ppu.cycle == 337 || ppu.cycle == 339) {
+		ppu.fetchGarbageNT(ppuFetchBGNametable)
+	}
 
 	// secondary OAM clear
 	if ppu.isRenderingEnabled() && 1 <= ppu.cycle && ppu.cycle <= 64 && ppu.isVisibleScanlines() {
@@ -967,12 +1226,14 @@ func (ppu *ppu) step() {
 	}
 
 	// sprite fetch
-	if ppu.isRenderingEnabled() && 257 <= ppu.cycle && ppu.cycle <= 320 && ppu.isVisibleScanlines() {
+	if ppu.isRenderingEnabled() && 257 <= ppu.cycle && ppu.cycle <= 320 && ppu.isRenderLine() {
 		switch ppu.cycle % 8 {
 		case 2:
 			// garbage NT byte
+			ppu.fetchGarbageNT(ppuFetchSpriteNametable)
 		case 4:
-			// garbage AT byte
+			// garbage NT byte
+			ppu.fetchGarbageNT(ppuFetchSpriteNametable)
 		case 6:
 			// fetch sprite pattern table low byte
 			// this process is included in fetchSpriteForNextScanline
@@ -1012,10 +1273,27 @@ func (ppu *ppu) step() {
 
 	// Pre-render line
 	if ppu.isPreLine() && ppu.cycle == 1 {
+		ppu.warmingUp = false
 		ppu.status.clearVBlankStarted()
 		ppu.nmiLine.setHigh()
 		ppu.status.clearSprite0Hit()
 		ppu.status.clearSpriteOverflow()
 	}
 
+	if ppu.vramAddrDelay > 0 {
+		ppu.vramAddrDelay--
+		if ppu.vramAddrDelay == 0 {
+			ppu.updatePPUAddr()
+		}
+	}
+
+	// A $2001 write between the dots is seen from the dot after the next one
+	renderingEnabled := ppu.mask.showBG() || ppu.mask.showSprites()
+	if ppu.quirks.OAMRowCorruption && ppu.renderingEnabled && !renderingEnabled && ppu.isRenderLine() {
+		ppu.markOAMCorruption()
+	}
+	if ppu.quirks.PaletteCorruption && ppu.renderingEnabled && !renderingEnabled {
+		ppu.corruptPalette()
+	}
+	ppu.renderingEnabled = renderingEnabled
 }
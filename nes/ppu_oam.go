@@ -0,0 +1,74 @@
+package nes
+
+// PPUQuirks switches the hardware bugs of the PPU sprite memory and palette, mainly for debugging.
+type PPUQuirks struct {
+	// SpriteOverflowBug scans OAM diagonally after 8 sprites are found.
+	// https://www.nesdev.org/wiki/PPU_sprite_evaluation#Sprite_overflow_bug
+	// If false, the sprite overflow flag is set by the Y-coordinates correctly.
+	SpriteOverflowBug bool
+	// OAMRowCorruption copies the first row of OAM over another row,
+	// when the rendering is disabled in the middle of the render lines and then enabled again.
+	OAMRowCorruption bool
+	// OAMDecay loses the content of the OAM rows, that haven't been accessed for a while.
+	// The PPU refreshes OAM only while rendering, so the content fades during a long forced blank.
+	OAMDecay bool
+	// PaletteCorruption overwrites the palette entry that v points at with the color of the current pixel,
+	// when the rendering is disabled in the middle of the visible dots while v is in $3F00-$3FFF.
+	PaletteCorruption bool
+}
+
+// DefaultPPUQuirks are the quirks enabled by default.
+// The OAM corruption, the OAM decay and the palette corruption are off, because their details vary by the console and aren't fully researched.
+var DefaultPPUQuirks = PPUQuirks{
+	SpriteOverflowBug: true,
+}
+
+// oamDecayInterval is the PPU clocks, that an OAM row keeps the content without being accessed.
+// OAM is DRAM and the content lasts around 3000 CPU cycles (about 1.7 ms).
+const oamDecayInterval = 3000 * 3
+
+// markOAMCorruption marks the OAM row, that is corrupted by disabling the rendering at the current dot.
+// The secondary OAM clear (dots 0-63) and the sprite fetches (dots 256-319) leave the OAM address somewhere,
+// and the row of the address is overwritten by the first row when the rendering starts again.
+func (ppu *ppu) markOAMCorruption() {
+	if 0 <= ppu.cycle && ppu.cycle < 64 {
+		// the row advances every 2 dots
+		ppu.oamCorruptedRows[ppu.cycle>>1] = true
+		ppu.oamCorrupted = true
+	} else if 256 <= ppu.cycle && ppu.cycle < 320 {
+		// each sprite fetch of 8 dots advances the row during the first 3 dots, and stays at the row for the last 5 dots
+		base := (ppu.cycle - 256) >> 3
+		offset := (ppu.cycle - 256) & 0x07
+		if offset > 3 {
+			offset = 3
+		}
+		ppu.oamCorruptedRows[base*4+offset] = true
+		ppu.oamCorrupted = true
+	}
+}
+
+// corruptOAM copies the first row (8 bytes) of OAM over the marked rows
+func (ppu *ppu) corruptOAM() {
+	for i := range ppu.oamCorruptedRows {
+		if ppu.oamCorruptedRows[i] {
+			copy(ppu.primaryOAM[i*8:i*8+8], ppu.primaryOAM[:8])
+			ppu.oamCorruptedRows[i] = false
+		}
+	}
+	ppu.oamCorrupted = false
+}
+
+// accessOAMRow refreshes the OAM row of the address, and decays the row if it hasn't been accessed for too long
+func (ppu *ppu) accessOAMRow(addr byte) {
+	if !ppu.quirks.OAMDecay {
+		return
+	}
+	row := addr >> 3
+	if ppu.clock-ppu.oamRowClock[row] > oamDecayInterval {
+		// The decayed bits vary by the console, $FF hides the sprites at least
+		for i := 0; i < 8; i++ {
+			ppu.primaryOAM[int(row)*8+i] = 0xFF
+		}
+	}
+	ppu.oamRowClock[row] = ppu.clock
+}
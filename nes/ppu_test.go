@@ -8,6 +8,18 @@ import (
 
 // TODO: `H` の文字のテスト
 
+// newTestPPU returns the PPU with NROM of vertical mirroring
+func newTestPPU(t *testing.T) *ppu {
+	t.Helper()
+	var nmiLine nmiInterruptLine
+	m := newMapper0(&Cassette{
+		PRG:    make([]byte, 0x8000),
+		CHR:    make([]byte, 0x2000),
+		Mirror: MirroringVertical,
+	})
+	return newPPU(nopRenderer{}, m, MirroringVertical, &nmiLine)
+}
+
 func Test_PPU_MirrorVRAMAddr(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -410,3 +422,459 @@ func Test_ReadStatus(t *testing.T) {
 		})
 	}
 }
+
+func Test_PPU_OddFrameSkip(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		mask      byte
+		newMask   byte
+		writeDot  int // the number of the steps before writing PPUMASK
+		wantCycle int // 1 if the dot is skipped
+	}{
+		{"1", 0x00, 0x08, 0, 1},
+		{"2", 0x00, 0x08, 2, 1},
+		{"3", 0x00, 0x08, 3, 0},
+		{"4", 0x08, 0x00, 2, 0},
+		{"5", 0x08, 0x00, 3, 1},
+		{"6", 0x10, 0x00, 3, 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.writeMask(tt.mask)
+			ppu.renderingEnabled = ppu.mask.showBG() || ppu.mask.showSprites()
+			ppu.scanline = 261
+			ppu.cycle = 336
+			ppu.oddFrame = 1
+
+			for i := 0; i < 5; i++ {
+				if i == tt.writeDot {
+					ppu.writeRegister(0x2001, tt.newMask)
+				}
+				ppu.step()
+			}
+			// (336,261) -> (337,261) -> (338,261) -> (339,261) -> (340,261) or (0,0) -> (0,0) or (1,0)
+			assert.Equal(t, 0, ppu.scanline)
+			assert.Equal(t, tt.wantCycle, ppu.cycle)
+			assert.Equal(t, byte(0), ppu.oddFrame)
+		})
+	}
+}
+
+func Test_PPU_SpriteOverflowBug(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		sprite8      [4]byte
+		sprite9      [4]byte
+		quirks       PPUQuirks
+		wantOverflow bool
+	}{
+		// the 9th sprite is in range
+		{"1", [4]byte{10, 0xFF, 0xFF, 0xFF}, [4]byte{0xFF, 0xFF, 0xFF, 0xFF}, PPUQuirks{SpriteOverflowBug: true}, true},
+		{"2", [4]byte{10, 0xFF, 0xFF, 0xFF}, [4]byte{0xFF, 0xFF, 0xFF, 0xFF}, PPUQuirks{}, true},
+		// the tile index of the 10th sprite is read as the Y-coordinate
+		{"3", [4]byte{0xFF, 0xFF, 0xFF, 0xFF}, [4]byte{0xFF, 10, 0xFF, 0xFF}, PPUQuirks{SpriteOverflowBug: true}, true},
+		{"4", [4]byte{0xFF, 0xFF, 0xFF, 0xFF}, [4]byte{0xFF, 10, 0xFF, 0xFF}, PPUQuirks{}, false},
+		// the Y-coordinate of the 10th sprite is missed
+		{"5", [4]byte{0xFF, 0xFF, 0xFF, 0xFF}, [4]byte{10, 0xFF, 0xFF, 0xFF}, PPUQuirks{SpriteOverflowBug: true}, false},
+		{"6", [4]byte{0xFF, 0xFF, 0xFF, 0xFF}, [4]byte{10, 0xFF, 0xFF, 0xFF}, PPUQuirks{}, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.scanline = 10
+			ppu.quirks = tt.quirks
+			for i := range ppu.primaryOAM {
+				ppu.primaryOAM[i] = 0xFF
+			}
+			for i := 0; i < 8; i++ {
+				ppu.primaryOAM[4*i] = 10
+			}
+			copy(ppu.primaryOAM[4*8:], tt.sprite8[:])
+			copy(ppu.primaryOAM[4*9:], tt.sprite9[:])
+
+			ppu.spriteEvaluationState = initAndScanPrimaryOAMState
+			for ppu.cycle = 65; ppu.cycle <= 256; ppu.cycle++ {
+				ppu.evalSpriteForNextScanline()
+			}
+			assert.Equal(t, tt.wantOverflow, ppu.status.get()&0x20 == 0x20)
+		})
+	}
+}
+
+func Test_PPU_OAMRowCorruption(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		cycle   int // the dot disabling the rendering
+		quirks  PPUQuirks
+		wantRow int // -1 if no row is corrupted
+	}{
+		{"1", 10, PPUQuirks{OAMRowCorruption: true}, 5},
+		{"2", 261, PPUQuirks{OAMRowCorruption: true}, 3},
+		{"3", 300, PPUQuirks{OAMRowCorruption: true}, 23},
+		{"4", 100, PPUQuirks{OAMRowCorruption: true}, -1},
+		{"5", 10, PPUQuirks{}, -1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.quirks = tt.quirks
+			for i := range ppu.primaryOAM {
+				ppu.primaryOAM[i] = byte(i)
+			}
+			ppu.writeMask(0x18)
+			ppu.renderingEnabled = true
+			ppu.scanline = 5
+			ppu.cycle = tt.cycle - 1
+
+			ppu.writeRegister(0x2001, 0x00)
+			ppu.step()
+			ppu.writeRegister(0x2001, 0x18)
+			ppu.step()
+			ppu.step()
+
+			want := [256]byte{}
+			for i := range want {
+				want[i] = byte(i)
+			}
+			if tt.wantRow >= 0 {
+				copy(want[tt.wantRow*8:], want[:8])
+			}
+			assert.Equal(t, want, ppu.primaryOAM)
+		})
+	}
+}
+
+func Test_PPU_OAMDecay(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		clock  int // the clock reading OAM
+		quirks PPUQuirks
+		want   byte
+	}{
+		{"1", oamDecayInterval, PPUQuirks{OAMDecay: true}, 0x12},
+		{"2", oamDecayInterval + 1, PPUQuirks{OAMDecay: true}, 0xFF},
+		{"3", oamDecayInterval + 1, PPUQuirks{}, 0x12},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.quirks = tt.quirks
+			ppu.writeOAMAddr(0x08)
+			ppu.writeOAMData(0x12)
+			ppu.clock = tt.clock
+			ppu.writeOAMAddr(0x08)
+			assert.Equal(t, tt.want, ppu.readOAMData())
+		})
+	}
+}
+
+func Test_PPU_WarmUp(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		warmUp   bool
+		steps    int // the PPU clocks before writing the registers, the first clock is the dot 0
+		reset    bool
+		model    PPUReset
+		wantCtrl byte
+		wantT    uint16
+		wantW    bool
+	}{
+		{"1", true, 0, false, PPUResetNES, 0x00, 0x0000, false},
+		// the pre-render line dot 0
+		{"2", true, 261*341 + 1, false, PPUResetNES, 0x00, 0x0000, false},
+		// the pre-render line dot 1
+		{"3", true, 261*341 + 2, false, PPUResetNES, 0x03, 0x0C00, true},
+		{"4", false, 0, false, PPUResetNES, 0x03, 0x0C00, true},
+		// the reset of the NES starts the warm-up again
+		{"5", true, 261*341 + 2, true, PPUResetNES, 0x00, 0x0000, false},
+		{"6", false, 261*341 + 2, true, PPUResetNES, 0x03, 0x0C00, true},
+		// the reset of the Famicom doesn't reach the PPU
+		{"7", true, 261*341 + 2, true, PPUResetFamicom, 0x03, 0x0C00, true},
+		// the warm-up after the power-up isn't cut by the reset of the Famicom
+		{"8", true, 0, true, PPUResetFamicom, 0x00, 0x0000, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.warmUp = tt.warmUp
+			ppu.resetModel = tt.model
+			ppu.powerUp()
+			for i := 0; i < tt.steps; i++ {
+				ppu.step()
+			}
+			if tt.reset {
+				ppu.reset()
+			}
+
+			ppu.writeRegister(0x2000, 0x03)
+			ppu.writeRegister(0x2001, 0x18)
+			ppu.writeRegister(0x2006, 0x0C)
+			assert.Equal(t, tt.wantCtrl, byte(ppu.ctrl))
+			assert.Equal(t, tt.wantCtrl != 0, ppu.mask == 0x18)
+			assert.Equal(t, tt.wantT, ppu.t)
+			assert.Equal(t, tt.wantW, ppu.w)
+		})
+	}
+}
+
+func Test_PPU_Reset(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		model    PPUReset
+		wantCtrl byte
+		wantMask byte
+		wantT    uint16
+		wantW    bool
+	}{
+		{"1", PPUResetNES, 0x00, 0x00, 0x0000, false},
+		{"2", PPUResetFamicom, 0x80, 0x18, 0x0C00, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.resetModel = tt.model
+			ppu.powerUp()
+			ppu.writeRegister(0x2000, 0x80)
+			ppu.writeRegister(0x2001, 0x18)
+			ppu.writeRegister(0x2006, 0x0C)
+			ppu.reset()
+			assert.Equal(t, tt.wantCtrl, byte(ppu.ctrl))
+			assert.Equal(t, tt.wantMask, byte(ppu.mask))
+			assert.Equal(t, tt.wantT, ppu.t)
+			assert.Equal(t, tt.wantW, ppu.w)
+		})
+	}
+}
+
+func Test_ParsePPUReset(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		s       string
+		want    PPUReset
+		wantErr bool
+	}{
+		{"1", "nes", PPUResetNES, false},
+		{"2", "famicom", PPUResetFamicom, false},
+		{"3", "dendy", PPUResetNES, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParsePPUReset(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.s, got.String())
+		})
+	}
+}
+
+func Test_PPU_WarmUpRegion(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		region Region
+		warmUp bool
+		want   bool
+	}{
+		{"1", RegionNTSC, true, true},
+		{"2", RegionPAL, true, true},
+		{"3", RegionMulti, true, true},
+		{"4", RegionDendy, true, false},
+		{"5", RegionNTSC, false, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := newMapper0(&Cassette{
+				PRG:    make([]byte, 0x8000),
+				CHR:    make([]byte, 0x2000),
+				Mirror: MirroringVertical,
+				Region: tt.region,
+			})
+			n := New(m, nopRenderer{}, &fakePlayer{}, WithPPUWarmUp(tt.warmUp))
+			n.PowerUp()
+			assert.Equal(t, tt.want, n.ppu.warmingUp)
+		})
+	}
+}
+
+func Test_PPU_UpdatePPUAddr(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		scanline         int
+		cycle            int
+		renderingEnabled bool
+		v                uint16
+		pendingV         uint16
+		want             uint16
+	}{
+		{"1", 10, 8, false, 0x1234, 0x2345, 0x2345},
+		{"2", 10, 100, true, 0x1234, 0x2345, 0x2345},
+		{"3", 241, 256, true, 0x0FF0, 0x2345, 0x2345},
+		// coarse X is incremented on the dot
+		{"4", 10, 8, true, 0x0002, 0x2345, 0x2340},
+		{"5", 261, 328, true, 0x0007, 0x2345, 0x2345},
+		// coarse X and Y are incremented on the dot
+		{"6", 10, 256, true, 0x0FF0, 0x2345, 0x0340},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.scanline = tt.scanline
+			ppu.cycle = tt.cycle
+			ppu.renderingEnabled = tt.renderingEnabled
+			ppu.v = tt.v
+			ppu.pendingV = tt.pendingV
+			ppu.updatePPUAddr()
+			assert.Equal(t, tt.want, ppu.v)
+		})
+	}
+}
+
+func Test_PPU_WritePPUAddrDelay(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		steps int
+		want  uint16
+	}{
+		{"1", 0, 0x0000},
+		{"2", 2, 0x0000},
+		{"3", 3, 0x2108},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.scanline = 241
+			ppu.writeRegister(0x2006, 0x21)
+			ppu.writeRegister(0x2006, 0x08)
+			for i := 0; i < tt.steps; i++ {
+				ppu.step()
+			}
+			assert.Equal(t, tt.want, ppu.v)
+			assert.Equal(t, uint16(0x2108), ppu.t)
+		})
+	}
+}
+
+func Test_PPU_WriteControllerDot257(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		scanline         int
+		cycle            int
+		renderingEnabled bool
+		want             uint16
+	}{
+		// the nametable X bit of v is lost
+		{"1", 10, 257, true, 0x0001},
+		{"2", 261, 257, true, 0x0001},
+		{"3", 10, 256, true, 0x0401},
+		{"4", 10, 258, true, 0x0401},
+		{"5", 10, 257, false, 0x0401},
+		{"6", 241, 257, true, 0x0401},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.scanline = tt.scanline
+			ppu.cycle = tt.cycle
+			ppu.renderingEnabled = tt.renderingEnabled
+			ppu.v = 0x0401
+			ppu.writeRegister(0x2000, 0x01)
+			assert.Equal(t, tt.want, ppu.v)
+			assert.Equal(t, uint16(0x0400), ppu.t)
+		})
+	}
+}
+
+func Test_PPU_PaletteCorruption(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		scanline      int
+		cycle         int // the dot that the rendering is disabled at
+		mask          byte
+		v             uint16
+		quirks        PPUQuirks
+		wantCorrupted bool
+	}{
+		// the backdrop color of the current pixel is written to the entry of v
+		{"1", 10, 100, 0x00, 0x3F01, PPUQuirks{PaletteCorruption: true}, true},
+		{"2", 10, 100, 0x00, 0x3F01, PPUQuirks{}, false},
+		// v isn't in the palette
+		{"3", 10, 100, 0x00, 0x2001, PPUQuirks{PaletteCorruption: true}, false},
+		// out of the visible dots
+		{"4", 10, 300, 0x00, 0x3F01, PPUQuirks{PaletteCorruption: true}, false},
+		{"5", 261, 100, 0x00, 0x3F01, PPUQuirks{PaletteCorruption: true}, false},
+		// the rendering is kept
+		{"6", 10, 100, 0x08, 0x3F01, PPUQuirks{PaletteCorruption: true}, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ppu := newTestPPU(t)
+			ppu.quirks = tt.quirks
+			ppu.paletteRAM.write(universalBGColor, 0x2A)
+			ppu.renderingEnabled = true
+			ppu.writeMask(tt.mask)
+			ppu.scanline = tt.scanline
+			ppu.cycle = tt.cycle - 1
+			ppu.v = tt.v
+			ppu.step()
+
+			var want paletteRAM
+			want.write(universalBGColor, 0x2A)
+			if tt.wantCorrupted {
+				want.write(paletteAddr(tt.v), 0x2A)
+			}
+			assert.Equal(t, want, ppu.paletteRAM)
+		})
+	}
+}
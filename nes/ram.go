@@ -0,0 +1,64 @@
+package nes
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// RAMPattern is the content of the internal RAM at power-up.
+// https://www.nesdev.org/wiki/CPU_power_up_state
+// The RAM is not cleared by the hardware, so the content depends on the console.
+type RAMPattern int
+
+const (
+	RAMPatternZero   RAMPattern = iota // all $00
+	RAMPatternFF                       // all $FF
+	RAMPatternFCEUX                    // 4 bytes of $00 and 4 bytes of $FF alternately, as FCEUX does
+	RAMPatternRandom                   // random bytes by the seed
+)
+
+// RAMPatterns are the names of the patterns, that ParseRAMPattern accepts
+var RAMPatterns = []string{"zero", "ff", "fceux", "random"}
+
+func (p RAMPattern) String() string {
+	if 0 <= p && int(p) < len(RAMPatterns) {
+		return RAMPatterns[p]
+	}
+	return "unknown"
+}
+
+// ParseRAMPattern returns the pattern by the name
+func ParseRAMPattern(s string) (RAMPattern, error) {
+	for i, name := range RAMPatterns {
+		if s == name {
+			return RAMPattern(i), nil
+		}
+	}
+	return RAMPatternZero, fmt.Errorf("unknown ram pattern: %s", s)
+}
+
+// fill initializes the RAM by the pattern, seed is used only by RAMPatternRandom
+func (p RAMPattern) fill(ram []byte, seed int64) {
+	switch p {
+	case RAMPatternZero:
+		for i := range ram {
+			ram[i] = 0x00
+		}
+	case RAMPatternFF:
+		for i := range ram {
+			ram[i] = 0xFF
+		}
+	case RAMPatternFCEUX:
+		for i := range ram {
+			if i&4 == 0 {
+				ram[i] = 0x00
+			} else {
+				ram[i] = 0xFF
+			}
+		}
+	case RAMPatternRandom:
+		rand.New(rand.NewSource(seed)).Read(ram)
+	default:
+		panic(fmt.Sprintf("Unable to reach RAMPattern.fill(%d)", p))
+	}
+}
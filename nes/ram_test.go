@@ -0,0 +1,74 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RAMPattern(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		pattern string
+		seed    int64
+		want    []byte
+	}{
+		{"1", "zero", 0, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"2", "ff", 0, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{"3", "fceux", 0, []byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0x00}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := ParseRAMPattern(tt.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.pattern, p.String())
+			ram := make([]byte, len(tt.want))
+			for i := range ram {
+				ram[i] = 0x5A
+			}
+			p.fill(ram, tt.seed)
+			assert.Equal(t, tt.want, ram)
+		})
+	}
+}
+
+func Test_RAMPattern_Random(t *testing.T) {
+	t.Parallel()
+	a := make([]byte, 0x800)
+	b := make([]byte, 0x800)
+	c := make([]byte, 0x800)
+	RAMPatternRandom.fill(a, 1)
+	RAMPatternRandom.fill(b, 1)
+	RAMPatternRandom.fill(c, 2)
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+
+	_, err := ParseRAMPattern("unknown")
+	assert.Error(t, err)
+}
+
+func Test_NES_PowerUpAndReset(t *testing.T) {
+	t.Parallel()
+	n := newTestCPU(nil, WithRAMPattern(RAMPatternFF, 0))
+	n.PowerUp()
+	assert.Equal(t, byte(0xFF), n.PeekMemory(0x0000))
+	assert.Equal(t, byte(0xFF), n.PeekMemory(0x07FF))
+	assert.Equal(t, byte(0xFD), n.cpu.S)
+
+	n.bus.write(0x0000, 0x12)
+	n.cpu.A, n.cpu.X, n.cpu.Y = 0x01, 0x02, 0x03
+	n.cpu.P = processorStatus(0x20 | 0x01)
+	n.ppu.writeController(0x80)
+	n.Reset()
+	assert.Equal(t, byte(0x12), n.PeekMemory(0x0000))
+	assert.Equal(t, byte(0xFF), n.PeekMemory(0x0001))
+	assert.Equal(t, [3]byte{0x01, 0x02, 0x03}, [3]byte{n.cpu.A, n.cpu.X, n.cpu.Y})
+	assert.Equal(t, byte(0xFA), n.cpu.S)
+	// the I flag is set, the others are kept
+	assert.Equal(t, processorStatus(0x20|0x04|0x01), n.cpu.P)
+	assert.Equal(t, ppuControlRegister(0), n.ppu.ctrl)
+}
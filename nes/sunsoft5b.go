@@ -0,0 +1,218 @@
+package nes
+
+import "math"
+
+// https://www.nesdev.org/wiki/Sunsoft_5B_audio
+// > The Sunsoft 5B is a variant of the FME-7 with an integrated YM2149F (AY-3-8910 compatible) sound chip.
+// > It has 3 square wave channels, a noise generator and an envelope generator.
+// > Tone frequency = Clock / (32 * Period), Noise frequency = Clock / (32 * Period), Envelope frequency = Clock / (512 * Period)
+// Clock is the CPU clock, so the tone and noise advance every 16 CPU cycles (one half of a square wave period)
+// and the envelope advances a step every 16 CPU cycles too (32 steps per 512 CPU cycles).
+const sunsoft5BToneCycles = 16
+
+// The output level of the 32 steps (1.5dB per step), level 0 is silence
+var sunsoft5BVolumeTable [32]float32
+
+func init() {
+	for i := 1; i < 32; i++ {
+		sunsoft5BVolumeTable[i] = float32(math.Pow(10, -float64(31-i)*1.5/20))
+	}
+}
+
+type sunsoft5BChannel struct {
+	period      uint16 // 12 bits
+	counter     uint16
+	out         bool
+	volume      byte // 4 bits
+	useEnvelope bool
+	toneOff     bool
+	noiseOff    bool
+}
+
+type sunsoft5BAudio struct {
+	address  byte
+	channels [3]sunsoft5BChannel
+	clock    int
+
+	noisePeriod  byte // 5 bits
+	noiseCounter byte
+	noiseLFSR    uint32 // 17 bits
+	noiseOut     bool
+
+	envPeriod    uint16
+	envCounter   uint16
+	envStep      int // 0 to 31
+	envContinue  bool
+	envAttack    bool
+	envAlternate bool
+	envHold      bool
+	envHolding   bool
+	envUp        bool
+}
+
+func newSunsoft5BAudio() *sunsoft5BAudio {
+	return &sunsoft5BAudio{
+		noiseLFSR: 1,
+	}
+}
+
+// $C000-$DFFF
+func (a *sunsoft5BAudio) writeAddress(val byte) {
+	// > 7......0
+	// > ---- RRRR
+	// > The upper 4 bits are used as a write protect, must be 0 to select the register.
+	a.address = val
+}
+
+// $E000-$FFFF
+func (a *sunsoft5BAudio) writeData(val byte) {
+	if (a.address & 0xF0) != 0 {
+		return
+	}
+	switch r := a.address & 0x0F; r {
+	case 0x00, 0x02, 0x04:
+		// Channel A/B/C low period
+		ch := &a.channels[r/2]
+		ch.period = (ch.period & 0x0F00) | uint16(val)
+	case 0x01, 0x03, 0x05:
+		// Channel A/B/C high period
+		ch := &a.channels[r/2]
+		ch.period = (ch.period & 0x00FF) | (uint16(val&0x0F) << 8)
+	case 0x06:
+		// Noise period
+		a.noisePeriod = val & 0x1F
+	case 0x07:
+		// 7......0
+		// --CBAcba
+		//   |||+++- Tone disable (active high) on channel A/B/C
+		//   +++---- Noise disable (active high) on channel A/B/C
+		for i := range a.channels {
+			a.channels[i].toneOff = (val>>i)&0x01 == 0x01
+			a.channels[i].noiseOff = (val>>(i+3))&0x01 == 0x01
+		}
+	case 0x08, 0x09, 0x0A:
+		// 7......0
+		// ---EVVVV
+		//    |++++- Channel volume
+		//    +----- Use envelope instead of the volume
+		ch := &a.channels[r-0x08]
+		ch.volume = val & 0x0F
+		ch.useEnvelope = (val & 0x10) == 0x10
+	case 0x0B:
+		a.envPeriod = (a.envPeriod & 0xFF00) | uint16(val)
+	case 0x0C:
+		a.envPeriod = (a.envPeriod & 0x00FF) | (uint16(val) << 8)
+	case 0x0D:
+		// 7......0
+		// ----CAaH
+		//     |||+- Hold
+		//     ||+-- Alternate
+		//     |+--- Attack
+		//     +---- Continue
+		a.envContinue = (val & 0x08) == 0x08
+		a.envAttack = (val & 0x04) == 0x04
+		a.envAlternate = (val & 0x02) == 0x02
+		a.envHold = (val & 0x01) == 0x01
+		// > Writing to the shape register restarts the envelope
+		a.envStep = 0
+		a.envCounter = 0
+		a.envHolding = false
+		a.envUp = a.envAttack
+	default:
+		// $0E/$0F are the I/O ports, not connected
+	}
+}
+
+func (a *sunsoft5BAudio) tick() {
+	a.clock++
+	if a.clock%sunsoft5BToneCycles == 0 {
+		for i := range a.channels {
+			ch := &a.channels[i]
+			ch.counter++
+			if ch.counter >= ch.period {
+				ch.counter = 0
+				ch.out = !ch.out
+			}
+		}
+		a.tickNoise()
+		a.tickEnvelope()
+		a.clock = 0
+	}
+}
+
+func (a *sunsoft5BAudio) tickNoise() {
+	a.noiseCounter++
+	// The noise generator runs at the half rate of the tone
+	if a.noiseCounter >= a.noisePeriod*2 {
+		a.noiseCounter = 0
+		// 17 bits LFSR, taps at bit 0 and bit 3
+		bit := (a.noiseLFSR ^ (a.noiseLFSR >> 3)) & 0x01
+		a.noiseLFSR = (a.noiseLFSR >> 1) | (bit << 16)
+		a.noiseOut = (a.noiseLFSR & 0x01) == 0x01
+	}
+}
+
+func (a *sunsoft5BAudio) tickEnvelope() {
+	a.envCounter++
+	if a.envCounter < a.envPeriod {
+		return
+	}
+	a.envCounter = 0
+	if a.envHolding {
+		return
+	}
+	a.envStep++
+	if a.envStep < 32 {
+		return
+	}
+	// end of the cycle
+	if !a.envContinue {
+		a.envUp = false
+		a.envHolding = true
+		a.envStep = 31
+		return
+	}
+	if a.envHold {
+		if a.envAlternate {
+			a.envUp = !a.envUp
+		}
+		a.envHolding = true
+		a.envStep = 31
+		return
+	}
+	if a.envAlternate {
+		a.envUp = !a.envUp
+	}
+	a.envStep = 0
+}
+
+// envelopeLevel returns 0 to 31
+func (a *sunsoft5BAudio) envelopeLevel() int {
+	if a.envHolding {
+		if a.envUp {
+			return 31
+		}
+		return 0
+	}
+	if a.envUp {
+		return a.envStep
+	}
+	return 31 - a.envStep
+}
+
+// sample returns the mixed output in the range of 0.0 to 1.0
+func (a *sunsoft5BAudio) sample() float32 {
+	out := float32(0)
+	for i := range a.channels {
+		ch := &a.channels[i]
+		if (ch.out || ch.toneOff) && (a.noiseOut || ch.noiseOff) {
+			if ch.useEnvelope {
+				out += sunsoft5BVolumeTable[a.envelopeLevel()]
+			} else if ch.volume > 0 {
+				// The 4 bits volume has 3dB steps, that is the odd levels of the envelope
+				out += sunsoft5BVolumeTable[2*ch.volume+1]
+			}
+		}
+	}
+	return out / 3
+}
@@ -0,0 +1,85 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sunsoft5BAudio_Envelope(t *testing.T) {
+	t.Parallel()
+	// levels at the start, after 1 cycle (32 steps) and after 2 cycles
+	tests := []struct {
+		name  string
+		shape byte
+		want  []int
+	}{
+		{"\\___", 0x00, []int{31, 0, 0}},
+		{"/___", 0x04, []int{0, 0, 0}},
+		{"\\\\\\\\", 0x08, []int{31, 31, 31}},
+		{"\\___ (hold)", 0x09, []int{31, 0, 0}},
+		{"\\/\\/", 0x0A, []int{31, 0, 31}},
+		{"\\```", 0x0B, []int{31, 31, 31}},
+		{"////", 0x0C, []int{0, 0, 0}},
+		{"/```", 0x0D, []int{0, 31, 31}},
+		{"/\\/\\", 0x0E, []int{0, 31, 0}},
+		{"/___ (hold)", 0x0F, []int{0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := newSunsoft5BAudio()
+			a.writeAddress(0x0B)
+			a.writeData(0x01)
+			a.writeAddress(0x0D)
+			a.writeData(tt.shape)
+
+			got := []int{a.envelopeLevel()}
+			for i := 0; i < 2; i++ {
+				for j := 0; j < 32; j++ {
+					a.tickEnvelope()
+				}
+				got = append(got, a.envelopeLevel())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_Sunsoft5BAudio_EnvelopeFrequency(t *testing.T) {
+	t.Parallel()
+	// Envelope frequency = Clock / (512 * Period), a cycle of 32 steps
+	tests := []struct {
+		name   string
+		period byte
+	}{
+		{"1", 1},
+		{"2", 3},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := newSunsoft5BAudio()
+			a.writeAddress(0x0B)
+			a.writeData(tt.period)
+			a.writeAddress(0x0D)
+			a.writeData(0x0E) // /\/\
+
+			cycles := 512 * int(tt.period)
+			for i := 0; i < cycles-1; i++ {
+				a.tick()
+			}
+			assert.Equal(t, 31, a.envelopeLevel())
+			a.tick()
+			assert.Equal(t, 31, a.envelopeLevel())
+			for i := 0; i < 16*int(tt.period); i++ {
+				a.tick()
+			}
+			assert.Equal(t, 30, a.envelopeLevel())
+		})
+	}
+}
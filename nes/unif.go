@@ -0,0 +1,160 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// UNIF has the board name instead of the mapper number, and the data in chunks
+// https://www.nesdev.org/wiki/UNIF
+const unifHeaderSize = 32
+
+// unifBoards maps the UNIF board names without the prefix (e.g. "NES-") to the iNES mappers
+var unifBoards = map[string]uint16{
+	"NROM":         0,
+	"NROM-128":     0,
+	"NROM-256":     0,
+	"RROM":         0,
+	"RROM-128":     0,
+	"UNROM":        2,
+	"UOROM":        2,
+	"CNROM":        3,
+	"EKROM":        5,
+	"ELROM":        5,
+	"ETROM":        5,
+	"EWROM":        5,
+	"AMROM":        7,
+	"ANROM":        7,
+	"AN1ROM":       7,
+	"AOROM":        7,
+	"PNROM":        9,
+	"PEEOROM":      9,
+	"FJROM":        10,
+	"FKROM":        10,
+	"CPROM":        13,
+	"UNROM-512-8":  30,
+	"UNROM-512-16": 30,
+	"UNROM-512-32": 30,
+	"BNROM":        34,
+	"GK-192":       58,
+	"GNROM":        66,
+	"MHROM":        66,
+	"JLROM":        69,
+	"JSROM":        69,
+	"BTR":          69,
+}
+
+// unifBoardPrefixes are stripped from the board names
+var unifBoardPrefixes = []string{"NES-", "HVC-", "UNL-", "BMC-", "BTL-"}
+
+// UNIFBoardMapper returns the iNES mapper of the UNIF board name
+func UNIFBoardMapper(board string) (uint16, bool) {
+	for _, p := range unifBoardPrefixes {
+		if strings.HasPrefix(board, p) {
+			board = board[len(p):]
+			break
+		}
+	}
+	m, ok := unifBoards[board]
+	return m, ok
+}
+
+func newUNIFCassette(data []byte) (*Cassette, error) {
+	if !bytes.HasPrefix(data, unifMagic) || len(data) < unifHeaderSize {
+		return nil, errors.New("invalid unif file")
+	}
+	var (
+		board  string
+		prgs   [16][]byte
+		chrs   [16][]byte
+		mirror = MirroringHorizontal
+		c      = &Cassette{}
+	)
+	p := data[unifHeaderSize:]
+	for len(p) > 0 {
+		if len(p) < 8 {
+			return nil, errors.New("invalid unif file: unexpected end of file")
+		}
+		id := string(p[:4])
+		size := int(binary.LittleEndian.Uint32(p[4:]))
+		if len(p) < 8+size {
+			return nil, fmt.Errorf("invalid unif file: %s chunk is too short", id)
+		}
+		chunk := p[8 : 8+size]
+		p = p[8+size:]
+
+		switch {
+		case id == "MAPR":
+			board = nsfString(chunk)
+		case id == "NAME":
+			c.Title = nsfString(chunk)
+		case id == "BATR":
+			c.Battery = true
+		case id == "MIRR" && size > 0:
+			// > $00 - Horizontal Mirroring (Hard Wired)
+			// > $01 - Vertical Mirroring (Hard Wired)
+			// > $02 - Mirror All Pages From $2000 (Hard Wired)
+			// > $03 - Mirror All Pages From $2400 (Hard Wired)
+			// > $04 - Four Screens of VRAM (Hard Wired)
+			// > $05 - Mirroring Controlled By Mapper Hardware
+			switch chunk[0] {
+			case 0x00:
+				mirror = MirroringHorizontal
+			case 0x01:
+				mirror = MirroringVertical
+			case 0x02:
+				mirror = MirroringSingleScreenLow
+			case 0x03:
+				mirror = MirroringSingleScreenHigh
+			case 0x04:
+				mirror = MirroringFourScreen
+			}
+		case id == "TVCI" && size > 0:
+			switch chunk[0] {
+			case 0x01:
+				c.Region = RegionPAL
+			case 0x02:
+				c.Region = RegionMulti
+			}
+		case strings.HasPrefix(id, "PRG") || strings.HasPrefix(id, "CHR"):
+			// PRG0-PRGF and CHR0-CHRF are concatenated in the order of the number
+			var n int
+			if _, err := fmt.Sscanf(id[3:], "%X", &n); err != nil {
+				continue
+			}
+			if id[:3] == "PRG" {
+				prgs[n] = chunk
+			} else {
+				chrs[n] = chunk
+			}
+		}
+	}
+
+	if board == "" {
+		return nil, errors.New("invalid unif file: no MAPR chunk")
+	}
+	mapper, ok := UNIFBoardMapper(board)
+	if !ok {
+		return nil, fmt.Errorf("unsupported unif board: %s", board)
+	}
+	c.Mapper = mapper
+	c.Mirror = mirror
+	// UNIF doesn't tell the PRG RAM size, provide 8 KiB as well as iNES
+	c.PRGRAMSize = 0x2000
+	c.PRG = bytes.Join(prgs[:], nil)
+	c.CHR = bytes.Join(chrs[:], nil)
+	c.chrROMSize = len(c.CHR)
+	if len(c.PRG) == 0 {
+		return nil, errors.New("invalid unif file: no PRG chunk")
+	}
+	if c.chrROMSize == 0 {
+		c.CHR = make([]byte, 8192)
+	}
+	if e := lookupGameDB(c.PRG, c.CHR[:c.chrROMSize]); e != nil {
+		c.applyGameDBEntry(e)
+	}
+	return c, nil
+}
@@ -0,0 +1,113 @@
+package nes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUNIF(chunks ...[]byte) []byte {
+	h := make([]byte, unifHeaderSize)
+	copy(h, unifMagic)
+	h[4] = 7
+	return append(h, bytes.Join(chunks, nil)...)
+}
+
+func unifChunk(id string, data []byte) []byte {
+	b := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	return append(b, data...)
+}
+
+func Test_NewCassette_UNIF(t *testing.T) {
+	t.Parallel()
+	prg0 := bytes.Repeat([]byte{0x01}, 0x4000)
+	prg1 := bytes.Repeat([]byte{0x02}, 0x4000)
+	chr0 := bytes.Repeat([]byte{0x03}, 0x2000)
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *Cassette
+		wantErr bool
+	}{
+		{"1", newTestUNIF(
+			unifChunk("MAPR", []byte("NES-CNROM\x00")),
+			unifChunk("NAME", []byte("game\x00")),
+			unifChunk("MIRR", []byte{0x01}),
+			unifChunk("PRG1", prg1),
+			unifChunk("PRG0", prg0),
+			unifChunk("CHR0", chr0),
+		), &Cassette{
+			PRG:        append(append([]byte{}, prg0...), prg1...),
+			CHR:        chr0,
+			Mapper:     3,
+			Mirror:     MirroringVertical,
+			Title:      "game",
+			PRGRAMSize: 0x2000,
+			chrROMSize: 0x2000,
+		}, false},
+		{"2", newTestUNIF(
+			unifChunk("MAPR", []byte("UNL-UNROM-512-32\x00")),
+			unifChunk("MIRR", []byte{0x05}),
+			unifChunk("BATR", []byte{0x01}),
+			unifChunk("TVCI", []byte{0x01}),
+			unifChunk("PRG0", prg0),
+		), &Cassette{
+			PRG:        prg0,
+			CHR:        make([]byte, 0x2000),
+			Mapper:     30,
+			Mirror:     MirroringHorizontal,
+			Battery:    true,
+			PRGRAMSize: 0x2000,
+			Region:     RegionPAL,
+		}, false},
+		{"3", newTestUNIF(unifChunk("MAPR", []byte("NES-UNKNOWN\x00")), unifChunk("PRG0", prg0)), nil, true},
+		{"4", newTestUNIF(unifChunk("PRG0", prg0)), nil, true},
+		{"5", newTestUNIF(unifChunk("MAPR", []byte("NES-NROM-128\x00")), unifChunk("PRG0", prg0)[:0x100]), nil, true},
+		{"6", newTestUNIF(
+			unifChunk("MAPR", []byte("BMC-GK-192\x00")),
+			unifChunk("MIRR", []byte{0x05}),
+			unifChunk("PRG0", prg0),
+			unifChunk("CHR0", chr0),
+		), &Cassette{
+			PRG:        prg0,
+			CHR:        chr0,
+			Mapper:     58,
+			Mirror:     MirroringHorizontal,
+			PRGRAMSize: 0x2000,
+			chrROMSize: 0x2000,
+		}, false},
+		// the boards of the mappers not implemented
+		{"7", newTestUNIF(unifChunk("MAPR", []byte("NES-SLROM\x00")), unifChunk("PRG0", prg0)), nil, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := NewCassette(bytes.NewReader(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_UNIFBoards(t *testing.T) {
+	t.Parallel()
+	// every board is supported by the mapper
+	for board, mapper := range unifBoards {
+		c := &Cassette{
+			PRG:        make([]byte, 0x8000),
+			CHR:        make([]byte, 0x2000),
+			Mapper:     mapper,
+			PRGRAMSize: 0x2000,
+		}
+		_, err := newMapper(c)
+		assert.NoError(t, err, board)
+	}
+}
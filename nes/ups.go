@@ -0,0 +1,53 @@
+package nes
+
+import (
+	"bytes"
+	"errors"
+)
+
+// UPS patch format
+// "UPS1", input size, output size, hunks of (relative offset, XOR data terminated by 0), input CRC32, output CRC32, patch CRC32
+// The sizes and offsets are the same variable-length numbers as BPS.
+var upsHeader = []byte("UPS1")
+
+// ApplyUPS returns the data patched by the UPS patch
+func ApplyUPS(source, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, upsHeader) || len(patch) < len(upsHeader)+12 {
+		return nil, errors.New("ups: invalid header")
+	}
+	r := &patchReader{data: patch, pos: len(upsHeader), end: len(patch) - 12}
+	inputSize := r.readNumber()
+	outputSize := r.readNumber()
+	if r.err != nil {
+		return nil, errors.New("ups: " + r.err.Error())
+	}
+	if inputSize != len(source) {
+		return nil, errors.New("ups: the patch is not for this rom")
+	}
+
+	target := make([]byte, outputSize)
+	copy(target, source)
+	pos := 0
+	for r.pos < r.end && r.err == nil {
+		pos += r.readNumber()
+		for r.err == nil {
+			x := r.readByte()
+			if x == 0 {
+				// the terminator also skips a byte
+				pos++
+				break
+			}
+			if pos < len(target) {
+				target[pos] ^= x
+			}
+			pos++
+		}
+	}
+	if r.err != nil {
+		return nil, errors.New("ups: " + r.err.Error())
+	}
+	if err := checkPatchCRC(source, target, patch); err != nil {
+		return nil, errors.New("ups: " + err.Error())
+	}
+	return target, nil
+}
@@ -0,0 +1,164 @@
+package nes
+
+// https://www.nesdev.org/wiki/VRC6_audio
+type vrc6Pulse struct {
+	mode    bool
+	duty    byte
+	volume  byte
+	period  uint16
+	enabled bool
+	timer   uint16
+	step    byte
+}
+
+func (p *vrc6Pulse) write(reg uint16, val byte) {
+	switch reg {
+	case 0:
+		// > 7  bit  0
+		// > ---- ----
+		// > MDDD VVVV
+		p.mode = (val & 0x80) == 0x80
+		p.duty = (val >> 4) & 0x07
+		p.volume = val & 0x0F
+	case 1:
+		p.period = (p.period & 0x0F00) | uint16(val)
+	case 2:
+		p.period = (p.period & 0x00FF) | uint16(val&0x0F)<<8
+		p.enabled = (val & 0x80) == 0x80
+		// Clearing the enable bit resets the duty cycle
+		if !p.enabled {
+			p.step = 15
+		}
+	}
+}
+
+func (p *vrc6Pulse) tick(shift uint) {
+	if !p.enabled {
+		return
+	}
+	if p.timer == 0 {
+		p.timer = p.period >> shift
+		if p.step == 0 {
+			p.step = 15
+		} else {
+			p.step--
+		}
+	} else {
+		p.timer--
+	}
+}
+
+func (p *vrc6Pulse) output() byte {
+	if !p.enabled {
+		return 0
+	}
+	if p.mode || p.step <= p.duty {
+		return p.volume
+	}
+	return 0
+}
+
+type vrc6Saw struct {
+	rate        byte
+	period      uint16
+	enabled     bool
+	timer       uint16
+	step        byte
+	accumulator byte
+}
+
+func (s *vrc6Saw) write(reg uint16, val byte) {
+	switch reg {
+	case 0:
+		s.rate = val & 0x3F
+	case 1:
+		s.period = (s.period & 0x0F00) | uint16(val)
+	case 2:
+		s.period = (s.period & 0x00FF) | uint16(val&0x0F)<<8
+		s.enabled = (val & 0x80) == 0x80
+		if !s.enabled {
+			s.step = 0
+			s.accumulator = 0
+		}
+	}
+}
+
+func (s *vrc6Saw) tick(shift uint) {
+	if !s.enabled {
+		return
+	}
+	if s.timer != 0 {
+		s.timer--
+		return
+	}
+	s.timer = s.period >> shift
+	// The accumulator is added on every other clock, and reset on the 14th clock
+	s.step++
+	if s.step == 14 {
+		s.step = 0
+		s.accumulator = 0
+	} else if (s.step & 0x01) == 0 {
+		s.accumulator += s.rate
+	}
+}
+
+func (s *vrc6Saw) output() byte {
+	// The high 5 bits of the accumulator are output
+	return s.accumulator >> 3
+}
+
+type vrc6Audio struct {
+	pulse1 vrc6Pulse
+	pulse2 vrc6Pulse
+	saw    vrc6Saw
+	halt   bool
+	shift  uint
+}
+
+func newVRC6Audio() *vrc6Audio {
+	a := &vrc6Audio{}
+	a.pulse1.step = 15
+	a.pulse2.step = 15
+	return a
+}
+
+// write takes the VRC6a address, $9000-$9003, $A000-$A002 and $B000-$B002
+func (a *vrc6Audio) write(addr uint16, val byte) {
+	reg := addr & 0x0003
+	switch addr & 0xF000 {
+	case 0x9000:
+		if reg == 3 {
+			// $9003: frequency control
+			// bit 0: halt, bit 1: 16x frequency, bit 2: 256x frequency (takes priority)
+			a.halt = (val & 0x01) == 0x01
+			switch {
+			case (val & 0x04) == 0x04:
+				a.shift = 8
+			case (val & 0x02) == 0x02:
+				a.shift = 4
+			default:
+				a.shift = 0
+			}
+			return
+		}
+		a.pulse1.write(reg, val)
+	case 0xA000:
+		a.pulse2.write(reg, val)
+	case 0xB000:
+		a.saw.write(reg, val)
+	}
+}
+
+func (a *vrc6Audio) tick() {
+	if a.halt {
+		return
+	}
+	a.pulse1.tick(a.shift)
+	a.pulse2.tick(a.shift)
+	a.saw.tick(a.shift)
+}
+
+// sample returns the output in 0..1
+func (a *vrc6Audio) sample() float32 {
+	return float32(a.pulse1.output()+a.pulse2.output()+a.saw.output()) / 61
+}
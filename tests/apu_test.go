@@ -1,11 +1,7 @@
 package e2e_test
 
 import (
-	"os"
 	"testing"
-
-	"github.com/ichirin2501/rgnes/nes"
-	"github.com/stretchr/testify/assert"
 )
 
 func Test_APU_OUT_6000(t *testing.T) {
@@ -46,43 +42,66 @@ func Test_APU_OUT_6000(t *testing.T) {
 			"apu_test/rom_singles/8-dmc_rates.nes",
 			"../nes-test-roms/apu_test/rom_singles/8-dmc_rates.nes",
 		},
+		{
+			"apu_reset/4015_cleared.nes",
+			"../nes-test-roms/apu_reset/4015_cleared.nes",
+		},
+		{
+			"apu_reset/4017_timing.nes",
+			"../nes-test-roms/apu_reset/4017_timing.nes",
+		},
+		{
+			"apu_reset/4017_written.nes",
+			"../nes-test-roms/apu_reset/4017_written.nes",
+		},
+		{
+			"apu_reset/irq_flag_cleared.nes",
+			"../nes-test-roms/apu_reset/irq_flag_cleared.nes",
+		},
+		{
+			"apu_reset/len_ctrs_enabled.nes",
+			"../nes-test-roms/apu_reset/len_ctrs_enabled.nes",
+		},
+		{
+			"apu_reset/works_immediately.nes",
+			"../nes-test-roms/apu_reset/works_immediately.nes",
+		},
 	}
 
 	for _, tt := range tests {
-		tt := tt
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			f, err := os.Open(tt.rompath)
-			if err != nil {
-				t.Fatal(err)
-			}
-			defer f.Close()
-			mapper, err := nes.NewMapper(f)
-			if err != nil {
-				t.Fatal(err)
-			}
+		for _, core := range cpuCores {
+			tt, core := tt, core
+			t.Run(tt.name+"/"+core.name, func(t *testing.T) {
+				t.Parallel()
+				runOUT6000(t, tt.rompath, core.options...)
+			})
+		}
+	}
+}
 
-			n := nes.New(mapper, &fakeRenderer{}, &fakePlayer{})
-			n.PowerUp()
+func Test_APU_ResultF8(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		rompath string
+	}{
+		{
+			"blargg_apu_2005.07.30/10.len_halt_timing.nes",
+			"../nes-test-roms/blargg_apu_2005.07.30/10.len_halt_timing.nes",
+		},
+		{
+			"blargg_apu_2005.07.30/11.len_reload_timing.nes",
+			"../nes-test-roms/blargg_apu_2005.07.30/11.len_reload_timing.nes",
+		},
+	}
 
-			ready := false
-			done := false
-			for {
-				n.Step()
-				got := n.PeekMemory(0x6000)
-				switch got {
-				case 0x80:
-					ready = true
-				default:
-					if ready {
-						assert.Equal(t, uint8(0x00), got)
-						done = true
-					}
-				}
-				if done {
-					break
-				}
-			}
-		})
+	for _, tt := range tests {
+		for _, core := range cpuCores {
+			tt, core := tt, core
+			t.Run(tt.name+"/"+core.name, func(t *testing.T) {
+				t.Parallel()
+				runResultF8(t, tt.rompath, core.options...)
+			})
+		}
 	}
 }
@@ -1,6 +1,7 @@
 package e2e_test
 
 import (
+	"bytes"
 	"fmt"
 	"image/color"
 	"os"
@@ -20,6 +21,68 @@ type fakePlayer struct{}
 func (f *fakePlayer) Sample(v float32)    {}
 func (f *fakePlayer) SampleRate() float64 { return 44100 }
 
+// cpuCores runs the test roms on both CPU cores, so that they are cross-checked
+var cpuCores = []struct {
+	name    string
+	options []nes.Option
+}{
+	{"instruction", nil},
+	{"cycle", []nes.Option{nes.WithCycleStepCPU()}},
+}
+
+// out6000Timeout is the limit of the emulated time to wait for the result, that is 60 seconds in CPU cycles
+const out6000Timeout = 60 * nes.CPUClockFrequency
+
+// runOUT6000 runs the blargg's test rom until it writes the result code to $6000, and checks the test passed.
+// The text of the rom is shown when it failed.
+func runOUT6000(t *testing.T, rompath string, options ...nes.Option) {
+	t.Helper()
+	f, err := os.Open(rompath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mapper, err := nes.NewMapper(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := nes.New(mapper, &fakeRenderer{}, &fakePlayer{}, options...)
+	n.PowerUp()
+	var out bytes.Buffer
+	got := nes.RunBlarggTest(n, &out, out6000Timeout)
+	assert.Equal(t, nes.BlarggPassed, got, out.String())
+}
+
+// resultF8Steps is the limit of the steps to wait for the result, that is about 10 seconds
+const resultF8Steps = 6000000
+
+// runResultF8 runs the blargg's test rom of 2005 until it writes the result code to $F8, and checks the test passed.
+// The result code is 1 if passed, and the other non-zero values tell the failed test.
+func runResultF8(t *testing.T, rompath string, options ...nes.Option) {
+	t.Helper()
+	f, err := os.Open(rompath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mapper, err := nes.NewMapper(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := nes.New(mapper, &fakeRenderer{}, &fakePlayer{}, options...)
+	n.PowerUp()
+	for i := 0; i < resultF8Steps; i++ {
+		n.Step()
+		if got := n.PeekMemory(0x00F8); got != 0 {
+			assert.Equal(t, uint8(0x01), got)
+			return
+		}
+	}
+	t.Fatal("timeout: the rom didn't write the result")
+}
+
 func Test_CPU_OUT_6000(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -102,10 +165,10 @@ func Test_CPU_OUT_6000(t *testing.T) {
 			"instr_misc/rom_singles/03-dummy_reads.nes",
 			"../nes-test-roms/instr_misc/rom_singles/03-dummy_reads.nes",
 		},
-		// {
-		// 	"instr_misc/rom_singles/04-dummy_reads_apu.nes",
-		// 	"../nes-test-roms/instr_misc/rom_singles/04-dummy_reads_apu.nes",
-		// },
+		{
+			"instr_misc/rom_singles/04-dummy_reads_apu.nes",
+			"../nes-test-roms/instr_misc/rom_singles/04-dummy_reads_apu.nes",
+		},
 		{
 			"cpu_dummy_writes/cpu_dummy_writes_ppumem.nes",
 			"../nes-test-roms/cpu_dummy_writes/cpu_dummy_writes_ppumem.nes",
@@ -118,10 +181,10 @@ func Test_CPU_OUT_6000(t *testing.T) {
 			"cpu_exec_space/test_cpu_exec_space_ppuio.nes",
 			"../nes-test-roms/cpu_exec_space/test_cpu_exec_space_ppuio.nes",
 		},
-		// {
-		// 	"cpu_exec_space/test_cpu_exec_space_apu.nes",
-		// 	"../nes-test-roms/cpu_exec_space/test_cpu_exec_space_apu.nes",
-		// },
+		{
+			"cpu_exec_space/test_cpu_exec_space_apu.nes",
+			"../nes-test-roms/cpu_exec_space/test_cpu_exec_space_apu.nes",
+		},
 		{
 			"cpu_interrupts_v2/1-cli_latency.nes",
 			"../nes-test-roms/cpu_interrupts_v2/rom_singles/1-cli_latency.nes",
@@ -138,17 +201,68 @@ func Test_CPU_OUT_6000(t *testing.T) {
 			"cpu_interrupts_v2/4-irq_and_dma.nes",
 			"../nes-test-roms/cpu_interrupts_v2/rom_singles/4-irq_and_dma.nes",
 		},
-		// {
-		// 	"cpu_interrupts_v2/5-branch_delays_irq.nes",
-		// 	"../nes-test-roms/cpu_interrupts_v2/rom_singles/5-branch_delays_irq.nes",
-		// },
+		{
+			"cpu_reset/ram_after_reset.nes",
+			"../nes-test-roms/cpu_reset/ram_after_reset.nes",
+		},
+		{
+			"cpu_reset/registers.nes",
+			"../nes-test-roms/cpu_reset/registers.nes",
+		},
+		{
+			"dmc_dma_during_read4/dma_2007_read.nes",
+			"../nes-test-roms/dmc_dma_during_read4/dma_2007_read.nes",
+		},
+		{
+			"dmc_dma_during_read4/dma_2007_write.nes",
+			"../nes-test-roms/dmc_dma_during_read4/dma_2007_write.nes",
+		},
+		{
+			"dmc_dma_during_read4/dma_4016_read.nes",
+			"../nes-test-roms/dmc_dma_during_read4/dma_4016_read.nes",
+		},
+		{
+			"dmc_dma_during_read4/double_2007_read.nes",
+			"../nes-test-roms/dmc_dma_during_read4/double_2007_read.nes",
+		},
+		{
+			"dmc_dma_during_read4/read_write_2007.nes",
+			"../nes-test-roms/dmc_dma_during_read4/read_write_2007.nes",
+		},
+		{
+			"cpu_interrupts_v2/5-branch_delays_irq.nes",
+			"../nes-test-roms/cpu_interrupts_v2/rom_singles/5-branch_delays_irq.nes",
+		},
+		{
+			"sprdma_and_dmc_dma/sprdma_and_dmc_dma.nes",
+			"../nes-test-roms/sprdma_and_dmc_dma/sprdma_and_dmc_dma.nes",
+		},
+		{
+			"sprdma_and_dmc_dma/sprdma_and_dmc_dma_512.nes",
+			"../nes-test-roms/sprdma_and_dmc_dma/sprdma_and_dmc_dma_512.nes",
+		},
 	}
 
 	for _, tt := range tests {
-		tt := tt
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			f, err := os.Open(tt.rompath)
+		for _, core := range cpuCores {
+			tt, core := tt, core
+			t.Run(tt.name+"/"+core.name, func(t *testing.T) {
+				t.Parallel()
+				runOUT6000(t, tt.rompath, core.options...)
+			})
+		}
+	}
+}
+
+func Test_NESTest(t *testing.T) {
+	// https://www.qmtpro.com/~nes/misc/nestest.txt
+	// > This test program, when run on "automation", (i.e. set your program counter
+	// > to 0c000h) will perform all tests in sequence and shove the results of
+	// > the tests into locations 02h and 03h.
+	for _, core := range cpuCores {
+		core := core
+		t.Run(core.name, func(t *testing.T) {
+			f, err := os.Open("../nes-test-roms/other/nestest.nes")
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -158,60 +272,22 @@ func Test_CPU_OUT_6000(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			n := nes.New(mapper, &fakeRenderer{}, &fakePlayer{})
-			n.PowerUp()
+			n := nes.New(mapper, &fakeRenderer{}, &fakePlayer{}, core.options...)
+			n.SetCPUPC(0xC000)
 
-			ready := false
-			done := false
-			for {
+			assert.Equal(t, byte(0), n.PeekMemory(0x02))
+			assert.Equal(t, byte(0), n.PeekMemory(0x03))
+			for i := 0; i < 8991; i++ {
 				n.Step()
-				got := n.PeekMemory(0x6000)
-				switch got {
-				case 0x80:
-					ready = true
-				default:
-					if ready {
-						assert.Equal(t, uint8(0x00), got)
-						done = true
-					}
+				if n.PeekMemory(0x02) != 0 {
+					t.Fatal(fmt.Sprintf("0x02 is not 0 (0x%02x)", n.PeekMemory(0x02)))
 				}
-				if done {
-					break
+				if n.PeekMemory(0x03) != 0 {
+					t.Fatal(fmt.Sprintf("0x03 is not 0 (0x%02x)", n.PeekMemory(0x03)))
 				}
 			}
+			assert.Equal(t, byte(0), n.PeekMemory(0x02))
+			assert.Equal(t, byte(0), n.PeekMemory(0x03))
 		})
 	}
 }
-
-func Test_NESTest(t *testing.T) {
-	// https://www.qmtpro.com/~nes/misc/nestest.txt
-	// > This test program, when run on "automation", (i.e. set your program counter
-	// > to 0c000h) will perform all tests in sequence and shove the results of
-	// > the tests into locations 02h and 03h.
-	f, err := os.Open("../nes-test-roms/other/nestest.nes")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer f.Close()
-	mapper, err := nes.NewMapper(f)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	n := nes.New(mapper, &fakeRenderer{}, &fakePlayer{})
-	n.SetCPUPC(0xC000)
-
-	assert.Equal(t, byte(0), n.PeekMemory(0x02))
-	assert.Equal(t, byte(0), n.PeekMemory(0x03))
-	for i := 0; i < 8991; i++ {
-		n.Step()
-		if n.PeekMemory(0x02) != 0 {
-			t.Fatal(fmt.Sprintf("0x02 is not 0 (0x%02x)", n.PeekMemory(0x02)))
-		}
-		if n.PeekMemory(0x03) != 0 {
-			t.Fatal(fmt.Sprintf("0x03 is not 0 (0x%02x)", n.PeekMemory(0x03)))
-		}
-	}
-	assert.Equal(t, byte(0), n.PeekMemory(0x02))
-	assert.Equal(t, byte(0), n.PeekMemory(0x03))
-}
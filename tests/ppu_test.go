@@ -1,11 +1,7 @@
 package e2e_test
 
 import (
-	"os"
 	"testing"
-
-	"github.com/ichirin2501/rgnes/nes"
-	"github.com/stretchr/testify/assert"
 )
 
 func Test_PPU_OUT_6000(t *testing.T) {
@@ -58,10 +54,10 @@ func Test_PPU_OUT_6000(t *testing.T) {
 			"ppu_vbl_nmi/rom_singles/09-even_odd_frames.nes",
 			"../nes-test-roms/ppu_vbl_nmi/rom_singles/09-even_odd_frames.nes",
 		},
-		// {
-		// 	"ppu_vbl_nmi/rom_singles/10-even_odd_timing.nes",
-		// 	"../nes-test-roms/ppu_vbl_nmi/rom_singles/10-even_odd_timing.nes",
-		// },
+		{
+			"ppu_vbl_nmi/rom_singles/10-even_odd_timing.nes",
+			"../nes-test-roms/ppu_vbl_nmi/rom_singles/10-even_odd_timing.nes",
+		},
 		{
 			"ppu_read_buffer/test_ppu_read_buffer.nes",
 			"../nes-test-roms/ppu_read_buffer/test_ppu_read_buffer.nes",
@@ -73,40 +69,51 @@ func Test_PPU_OUT_6000(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		tt := tt
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			f, err := os.Open(tt.rompath)
-			if err != nil {
-				t.Fatal(err)
-			}
-			defer f.Close()
-			mapper, err := nes.NewMapper(f)
-			if err != nil {
-				t.Fatal(err)
-			}
+		for _, core := range cpuCores {
+			tt, core := tt, core
+			t.Run(tt.name+"/"+core.name, func(t *testing.T) {
+				t.Parallel()
+				runOUT6000(t, tt.rompath, core.options...)
+			})
+		}
+	}
+}
 
-			n := nes.New(mapper, &fakeRenderer{}, &fakePlayer{})
-			n.PowerUp()
+func Test_PPU_ResultF8(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		rompath string
+	}{
+		{
+			"sprite_overflow_tests/1.Basics.nes",
+			"../nes-test-roms/sprite_overflow_tests/1.Basics.nes",
+		},
+		{
+			"sprite_overflow_tests/2.Details.nes",
+			"../nes-test-roms/sprite_overflow_tests/2.Details.nes",
+		},
+		{
+			"sprite_overflow_tests/3.Timing.nes",
+			"../nes-test-roms/sprite_overflow_tests/3.Timing.nes",
+		},
+		{
+			"sprite_overflow_tests/4.Obscure.nes",
+			"../nes-test-roms/sprite_overflow_tests/4.Obscure.nes",
+		},
+		{
+			"sprite_overflow_tests/5.Emulator.nes",
+			"../nes-test-roms/sprite_overflow_tests/5.Emulator.nes",
+		},
+	}
 
-			ready := false
-			done := false
-			for {
-				n.Step()
-				got := n.PeekMemory(0x6000)
-				switch got {
-				case 0x80:
-					ready = true
-				default:
-					if ready {
-						assert.Equal(t, uint8(0x00), got)
-						done = true
-					}
-				}
-				if done {
-					break
-				}
-			}
-		})
+	for _, tt := range tests {
+		for _, core := range cpuCores {
+			tt, core := tt, core
+			t.Run(tt.name+"/"+core.name, func(t *testing.T) {
+				t.Parallel()
+				runResultF8(t, tt.rompath, core.options...)
+			})
+		}
 	}
 }
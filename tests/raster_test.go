@@ -0,0 +1,145 @@
+package e2e_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ichirin2501/rgnes/nes"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "record the frame hashes of the raster tests as the references")
+
+// frameRenderer keeps the frame, and counts the frames
+type frameRenderer struct {
+	img    *image.RGBA
+	frames int
+}
+
+func newFrameRenderer() *frameRenderer {
+	return &frameRenderer{
+		img: image.NewRGBA(image.Rect(0, 0, nes.ScreenWidth, nes.ScreenHeight)),
+	}
+}
+
+func (r *frameRenderer) Render(x, y int, c color.Color) {
+	r.img.Set(x, y, c)
+}
+
+func (r *frameRenderer) Refresh() {
+	r.frames++
+}
+
+func (r *frameRenderer) hash() string {
+	sum := sha256.Sum256(r.img.Pix)
+	return hex.EncodeToString(sum[:])
+}
+
+// runRaster runs the rom until the frame, and returns the renderer holding the frame
+func runRaster(t *testing.T, rompath string, frame int, options ...nes.Option) *frameRenderer {
+	t.Helper()
+	f, err := os.Open(rompath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mapper, err := nes.NewMapper(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newFrameRenderer()
+	n := nes.New(mapper, r, &fakePlayer{}, options...)
+	n.PowerUp()
+	// Refresh is called at the start of vblank, the image holds the whole frame then
+	for r.frames < frame {
+		n.Step()
+	}
+	return r
+}
+
+// Test_PPU_Raster renders the frames of the raster effect demos, and compares them with the recorded hashes.
+// Run `go test -run Test_PPU_Raster -update` to record the hashes and the frames as png,
+// and commit them after checking the frames against the hardware or a reference emulator.
+func Test_PPU_Raster(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		rompath string
+		frame   int
+	}{
+		{
+			"scanline/scanline.nes",
+			"../nes-test-roms/scanline/scanline.nes",
+			60,
+		},
+		{
+			"nmi_sync/demo_ntsc.nes",
+			"../nes-test-roms/nmi_sync/demo_ntsc.nes",
+			120,
+		},
+		{
+			"full_palette/full_palette.nes",
+			"../nes-test-roms/full_palette/full_palette.nes",
+			60,
+		},
+		{
+			"scrolltest/scroll.nes",
+			"../nes-test-roms/scrolltest/scroll.nes",
+			60,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		refpath := filepath.Join("testdata", "raster", strings.TrimSuffix(strings.ReplaceAll(tt.name, "/", "_"), ".nes")+".sha256")
+		if *update {
+			t.Run(tt.name+"/update", func(t *testing.T) {
+				r := runRaster(t, tt.rompath, tt.frame)
+				if err := os.MkdirAll(filepath.Dir(refpath), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(refpath, []byte(r.hash()+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				f, err := os.Create(strings.TrimSuffix(refpath, ".sha256") + ".png")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				if err := png.Encode(f, r.img); err != nil {
+					t.Fatal(err)
+				}
+			})
+			continue
+		}
+		for _, core := range cpuCores {
+			core := core
+			t.Run(tt.name+"/"+core.name, func(t *testing.T) {
+				t.Parallel()
+				want, err := os.ReadFile(refpath)
+				if err != nil {
+					t.Fatalf("%v: record the reference with -update", err)
+				}
+				r := runRaster(t, tt.rompath, tt.frame, core.options...)
+				got := r.hash()
+				if !assert.Equal(t, strings.TrimSpace(string(want)), got) {
+					// keep the frame to compare by eye
+					if f, err := os.CreateTemp("", "rgnes-raster-*.png"); err == nil {
+						_ = png.Encode(f, r.img)
+						f.Close()
+						t.Logf("frame: %s", f.Name())
+					}
+				}
+			})
+		}
+	}
+}